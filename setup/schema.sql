@@ -4,6 +4,12 @@ CREATE EXTENSION IF NOT EXISTS postgis;
 -- Enable UUID Extension
 CREATE EXTENSION IF NOT EXISTS "pgcrypto";
 
+-- Trigram similarity for typo-tolerant search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- UK English text search configuration used by /search
+CREATE TEXT SEARCH CONFIGURATION uk_english (COPY = pg_catalog.english);
+
 -- Users Table
 CREATE TABLE users (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -12,7 +18,9 @@ CREATE TABLE users (
     email VARCHAR(255) UNIQUE NOT NULL,
     password_hash TEXT NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    is_deleted BOOLEAN NOT NULL DEFAULT FALSE
+    is_deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Admins curate the toy catalogue
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 -- User Bios Table
@@ -23,9 +31,45 @@ CREATE TABLE user_bios (
     bio_description TEXT,
     profile_image TEXT,
     show_real_name BOOLEAN NOT NULL DEFAULT TRUE,
-    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    -- Whether the user's collection is shown on their public profile
+    collection_public BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('uk_english', COALESCE(display_name, '')), 'A') ||
+        setweight(to_tsvector('uk_english', COALESCE(store_name, '')), 'A') ||
+        setweight(to_tsvector('uk_english', COALESCE(bio_description, '')), 'C')
+    ) STORED
+);
+
+CREATE INDEX idx_user_bios_search ON user_bios USING GIN (search_vector);
+CREATE INDEX idx_user_bios_display_name_trgm ON user_bios USING GIN (display_name gin_trgm_ops);
+CREATE INDEX idx_user_bios_store_name_trgm ON user_bios USING GIN (store_name gin_trgm_ops);
+
+-- User Blocks Table: the blocked user's markers, reviews, messages and profile are hidden
+-- from the blocker, and neither can message, follow, RSVP to or trade with the other
+CREATE TABLE user_blocks (
+    blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
 );
 
+CREATE INDEX idx_user_blocks_blocked ON user_blocks (blocked_id);
+
+-- User Mutes Table: the muted user is left out of every list the muter sees, from markers
+-- and reviews to the feed, and the muter isn't notified of their messages or follows.
+-- Unlike a block, their profile and messages stay visible and they can still interact.
+CREATE TABLE user_mutes (
+    muter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    muted_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id <> muted_id)
+);
+
+CREATE INDEX idx_user_mutes_muted ON user_mutes (muted_id);
+
 -- User Markers Table
 CREATE TABLE user_markers (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -39,10 +83,606 @@ CREATE TABLE user_markers (
         'East Midlands', 'South West', 'South East', 'London', 'East of England'
     )),
     marker_type TEXT NOT NULL CHECK (marker_type IN ('Shop', 'Collector', 'Event', 'Trade Meetup')),
+    -- Set by trigger_fuzz_marker_location when omitted; Collector markers default to a coarse precision
+    location_precision TEXT NOT NULL CHECK (location_precision IN ('exact', 'street', 'neighbourhood', 'town')),
+    -- Publicly visible point and its accuracy radius, derived from latitude/longitude by trigger
+    public_latitude DOUBLE PRECISION NOT NULL,
+    public_longitude DOUBLE PRECISION NOT NULL,
+    accuracy_m DOUBLE PRECISION NOT NULL,
+    visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'members', 'followers', 'private')),
+    -- Shops only: whether England and Wales bank holidays close the shop
+    closed_on_bank_holidays BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Review aggregates, maintained by trigger_marker_review_stats
+    rating_avg NUMERIC(3, 2),
+    rating_count INTEGER NOT NULL DEFAULT 0,
+    -- Maintained by trigger_marker_favourite_count
+    favourite_count INTEGER NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP DEFAULT NOW()
+    updated_at TIMESTAMP DEFAULT NOW(),
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('uk_english', COALESCE(name, '')), 'A') ||
+        setweight(to_tsvector('uk_english', COALESCE(description, '')), 'B')
+    ) STORED
+);
+
+CREATE INDEX idx_user_markers_search ON user_markers USING GIN (search_vector);
+CREATE INDEX idx_user_markers_name_trgm ON user_markers USING GIN (name gin_trgm_ops);
+CREATE INDEX idx_user_markers_visibility ON user_markers (visibility);
+
+-- Marker Events Table (schedules for 'Event' and 'Trade Meetup' markers)
+CREATE TABLE marker_events (
+    marker_id UUID PRIMARY KEY REFERENCES user_markers(id) ON DELETE CASCADE,
+    -- Wall-clock times in the event's own time zone
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    timezone TEXT NOT NULL DEFAULT 'Europe/London',
+    rrule TEXT,
+    exdates TIMESTAMP[] NOT NULL DEFAULT '{}',
+    -- End of the final occurrence; NULL for open-ended recurrences
+    series_ends_at TIMESTAMPTZ,
+    -- Maximum number of 'going' RSVPs; NULL for unlimited
+    capacity INTEGER CHECK (capacity > 0),
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX idx_marker_events_series_ends_at ON marker_events (series_ends_at);
+
+-- Shop Opening Hours Table (weekly, Europe/London wall-clock times)
+CREATE TABLE shop_opening_hours (
+    marker_id UUID NOT NULL REFERENCES user_markers(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 1 AND 7), -- ISO weekday, 1 = Monday
+    opens_at TIME NOT NULL,
+    closes_at TIME NOT NULL,
+    PRIMARY KEY (marker_id, weekday, opens_at),
+    CHECK (closes_at > opens_at)
+);
+
+-- Shop Opening Exceptions Table; a NULL opens_at means closed all day
+CREATE TABLE shop_opening_exceptions (
+    marker_id UUID NOT NULL REFERENCES user_markers(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    opens_at TIME,
+    closes_at TIME,
+    note TEXT,
+    PRIMARY KEY (marker_id, date),
+    CHECK ((opens_at IS NULL AND closes_at IS NULL) OR closes_at > opens_at)
+);
+
+-- England and Wales bank holidays, kept up to date by the API at startup
+CREATE TABLE bank_holidays (
+    date DATE PRIMARY KEY,
+    name TEXT NOT NULL
+);
+
+-- Marker Reviews Table (Shop and Trade Meetup markers), one per reviewer per marker
+CREATE TABLE marker_reviews (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    marker_id UUID NOT NULL REFERENCES user_markers(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    body TEXT NOT NULL DEFAULT '',
+    -- The marker owner's single public reply
+    reply TEXT,
+    replied_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (marker_id, user_id)
+);
+
+CREATE INDEX idx_marker_reviews_user ON marker_reviews (user_id);
+CREATE INDEX idx_user_markers_rating ON user_markers (rating_avg);
+
+-- Marker Favourites Table
+CREATE TABLE marker_favourites (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    marker_id UUID NOT NULL REFERENCES user_markers(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, marker_id)
+);
+
+CREATE INDEX idx_marker_favourites_marker ON marker_favourites (marker_id);
+
+-- Marker Lists Table; shared lists can be read by anyone holding share_token
+CREATE TABLE marker_lists (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    visibility TEXT NOT NULL DEFAULT 'private' CHECK (visibility IN ('private', 'shared')),
+    share_token TEXT UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((visibility = 'shared') = (share_token IS NOT NULL))
+);
+
+CREATE INDEX idx_marker_lists_user ON marker_lists (user_id);
+
+-- Marker List Items Table, ordered by position
+CREATE TABLE marker_list_items (
+    list_id UUID NOT NULL REFERENCES marker_lists(id) ON DELETE CASCADE,
+    marker_id UUID NOT NULL REFERENCES user_markers(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    note TEXT,
+    added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (list_id, marker_id)
+);
+
+CREATE INDEX idx_marker_list_items_position ON marker_list_items (list_id, position);
+
+-- Catalogue Franchises Table (Star Wars, Transformers, ...)
+CREATE TABLE catalogue_franchises (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Catalogue Manufacturers Table (Kenner, Palitoy, Hasbro, ...)
+CREATE TABLE catalogue_manufacturers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(100) UNIQUE NOT NULL,
+    country VARCHAR(100),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Catalogue Product Lines Table, each within one franchise
+CREATE TABLE catalogue_lines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    franchise_id UUID NOT NULL REFERENCES catalogue_franchises(id) ON DELETE RESTRICT,
+    name VARCHAR(150) NOT NULL,
+    slug VARCHAR(150) NOT NULL,
+    start_year SMALLINT,
+    end_year SMALLINT,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (franchise_id, slug),
+    CHECK (end_year IS NULL OR start_year IS NULL OR end_year >= start_year)
 );
 
+-- Catalogue Items Table. The same figure can appear once per variant and release year.
+CREATE TABLE catalogue_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    line_id UUID NOT NULL REFERENCES catalogue_lines(id) ON DELETE RESTRICT,
+    manufacturer_id UUID REFERENCES catalogue_manufacturers(id) ON DELETE SET NULL,
+    name VARCHAR(200) NOT NULL,
+    year SMALLINT CHECK (year BETWEEN 1850 AND 2100),
+    variant VARCHAR(150) NOT NULL DEFAULT '',
+    sku VARCHAR(100),
+    barcode VARCHAR(20) UNIQUE,
+    images TEXT[] NOT NULL DEFAULT '{}',
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('uk_english', COALESCE(name, '')), 'A') ||
+        setweight(to_tsvector('uk_english', COALESCE(variant, '')), 'B') ||
+        setweight(to_tsvector('simple', COALESCE(sku, '')), 'B') ||
+        setweight(to_tsvector('uk_english', COALESCE(description, '')), 'C')
+    ) STORED
+);
+
+CREATE UNIQUE INDEX idx_catalogue_items_natural_key ON catalogue_items (line_id, lower(name), variant, COALESCE(year, 0));
+CREATE INDEX idx_catalogue_items_manufacturer ON catalogue_items (manufacturer_id);
+CREATE INDEX idx_catalogue_items_search ON catalogue_items USING GIN (search_vector);
+CREATE INDEX idx_catalogue_items_name_trgm ON catalogue_items USING GIN (name gin_trgm_ops);
+CREATE INDEX idx_catalogue_items_sku ON catalogue_items (lower(sku));
+
+-- Unknown Barcodes Table: scanned codes that matched nothing in the catalogue, queued for
+-- curators to link to an item or dismiss
+CREATE TABLE unknown_barcodes (
+    -- EAN-13 form; UPC codes are stored with a leading zero
+    barcode VARCHAR(13) PRIMARY KEY,
+    format TEXT NOT NULL CHECK (format IN ('EAN-13', 'EAN-8', 'UPC-A', 'UPC-E')),
+    scan_count INTEGER NOT NULL DEFAULT 1,
+    first_scanned_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'resolved', 'dismissed')),
+    catalogue_item_id UUID REFERENCES catalogue_items(id) ON DELETE SET NULL,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    first_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_unknown_barcodes_pending ON unknown_barcodes (scan_count DESC) WHERE status = 'pending';
+
+-- Collection Items Table: what each user owns, either a catalogue item or a free-form entry
+CREATE TABLE collection_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    catalogue_item_id UUID REFERENCES catalogue_items(id) ON DELETE SET NULL,
+    -- Free-form details. Entries from the catalogue keep a copy, so they survive the item being removed.
+    name VARCHAR(200) NOT NULL,
+    franchise VARCHAR(100),
+    line VARCHAR(150),
+    year SMALLINT CHECK (year BETWEEN 1850 AND 2100),
+    -- C-scale grade, 10 being mint
+    condition_grade SMALLINT CHECK (condition_grade BETWEEN 1 AND 10),
+    packaging TEXT CHECK (packaging IN ('loose', 'MOC', 'MIB')),
+    quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    -- What was paid for the entry as a whole
+    purchase_price_pence INTEGER CHECK (purchase_price_pence >= 0),
+    purchase_date DATE,
+    notes TEXT,
+    photos TEXT[] NOT NULL DEFAULT '{}',
+    -- Offered for trade to other collectors while the collection is public
+    tradeable BOOLEAN NOT NULL DEFAULT FALSE,
+    asking_price_pence INTEGER CHECK (asking_price_pence >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_collection_items_user ON collection_items (user_id);
+CREATE INDEX idx_collection_items_catalogue_item ON collection_items (catalogue_item_id);
+
+-- Listings Table: stock advertised for sale by shops and collectors. A listing goes live
+-- when it becomes active and expires automatically at expires_at.
+CREATE TABLE listings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    catalogue_item_id UUID REFERENCES catalogue_items(id) ON DELETE SET NULL,
+    -- Pickup location; must be one of the seller's markers
+    marker_id UUID REFERENCES user_markers(id) ON DELETE SET NULL,
+    title VARCHAR(200) NOT NULL,
+    description TEXT,
+    -- Used when the listing isn't linked to the catalogue
+    franchise VARCHAR(100),
+    -- Price of each unit
+    price_pence INTEGER NOT NULL CHECK (price_pence >= 0),
+    condition_grade SMALLINT CHECK (condition_grade BETWEEN 1 AND 10),
+    packaging TEXT CHECK (packaging IN ('loose', 'MOC', 'MIB')),
+    photos TEXT[] NOT NULL DEFAULT '{}',
+    quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    status TEXT NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'active', 'reserved', 'sold', 'expired')),
+    published_at TIMESTAMP,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (status = 'draft' OR expires_at IS NOT NULL)
+);
+
+CREATE INDEX idx_listings_seller ON listings (seller_id, created_at DESC);
+CREATE INDEX idx_listings_active ON listings (expires_at) WHERE status = 'active';
+CREATE INDEX idx_listings_catalogue_item ON listings (catalogue_item_id);
+
+-- Wishlist Items Table: catalogue items a user wants, and the offers they would accept
+CREATE TABLE wishlist_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    catalogue_item_id UUID NOT NULL REFERENCES catalogue_items(id) ON DELETE CASCADE,
+    max_price_pence INTEGER CHECK (max_price_pence >= 0),
+    min_condition SMALLINT CHECK (min_condition BETWEEN 1 AND 10),
+    -- Acceptable packaging; empty accepts any
+    packagings TEXT[] NOT NULL DEFAULT '{}' CHECK (packagings <@ ARRAY['loose', 'MOC', 'MIB']),
+    notes TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, catalogue_item_id)
+);
+
+CREATE INDEX idx_wishlist_items_catalogue_item ON wishlist_items (catalogue_item_id);
+
+-- Wishlist Offers View: catalogue items other users are offering, from every source wishlists match against
+CREATE VIEW wishlist_offers AS
+SELECT 'collection'::text AS source, co.id AS source_id, co.user_id AS seller_id, co.catalogue_item_id,
+    co.condition_grade, co.packaging, co.asking_price_pence AS price_pence
+FROM collection_items co
+JOIN users u ON co.user_id = u.id
+JOIN user_bios ub ON co.user_id = ub.user_id
+WHERE co.tradeable AND ub.collection_public AND co.catalogue_item_id IS NOT NULL AND u.is_deleted = FALSE
+UNION ALL
+SELECT 'listing'::text, l.id, l.seller_id, l.catalogue_item_id, l.condition_grade, l.packaging, l.price_pence
+FROM listings l
+JOIN users u ON l.seller_id = u.id
+WHERE l.status = 'active' AND l.expires_at > NOW() AND l.catalogue_item_id IS NOT NULL AND u.is_deleted = FALSE;
+
+-- Wishlist Offer Matches View: offers satisfying each wishlist entry. Offers without an
+-- asking price are open to offers, so they match any price limit.
+CREATE VIEW wishlist_offer_matches AS
+SELECT wi.id AS wishlist_item_id, wi.user_id, wo.catalogue_item_id, wo.source, wo.source_id, wo.seller_id,
+    wo.condition_grade, wo.packaging, wo.price_pence
+FROM wishlist_items wi
+JOIN users bu ON wi.user_id = bu.id
+JOIN wishlist_offers wo ON wo.catalogue_item_id = wi.catalogue_item_id
+WHERE bu.is_deleted = FALSE
+  AND wo.seller_id <> wi.user_id
+  AND (wi.max_price_pence IS NULL OR wo.price_pence IS NULL OR wo.price_pence <= wi.max_price_pence)
+  AND (wi.min_condition IS NULL OR wo.condition_grade >= wi.min_condition)
+  AND (cardinality(wi.packagings) = 0 OR wo.packaging = ANY(wi.packagings))
+  -- Nothing is offered between users a block stands between, nor by sellers the buyer muted
+  AND NOT EXISTS (
+      SELECT 1 FROM user_blocks b
+      WHERE (b.blocker_id = wi.user_id AND b.blocked_id = wo.seller_id)
+         OR (b.blocker_id = wo.seller_id AND b.blocked_id = wi.user_id))
+  AND NOT EXISTS (
+      SELECT 1 FROM user_mutes m WHERE m.muter_id = wi.user_id AND m.muted_id = wo.seller_id);
+
+-- Wishlist Matches Table: offers the wishlist owner has already been alerted to
+CREATE TABLE wishlist_matches (
+    wishlist_item_id UUID NOT NULL REFERENCES wishlist_items(id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    source_id UUID NOT NULL,
+    notified_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (wishlist_item_id, source, source_id)
+);
+
+-- Trade Offers Table. A counter-offer replaces its parent, which is left 'countered'.
+CREATE TABLE trade_offers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    parent_offer_id UUID REFERENCES trade_offers(id),
+    proposer_id UUID NOT NULL REFERENCES users(id),
+    recipient_id UUID NOT NULL REFERENCES users(id),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN (
+        'pending', 'countered', 'accepted', 'declined', 'withdrawn', 'completed'
+    )),
+    -- Cash on top of the items; positive is paid by the proposer, negative by the recipient
+    cash_pence INTEGER NOT NULL DEFAULT 0,
+    message TEXT,
+    -- Each party confirms an accepted trade has been handed over; it completes once both have
+    proposer_confirmed_at TIMESTAMP,
+    recipient_confirmed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (proposer_id <> recipient_id)
+);
+
+CREATE INDEX idx_trade_offers_proposer ON trade_offers (proposer_id, created_at DESC);
+CREATE INDEX idx_trade_offers_recipient ON trade_offers (recipient_id, created_at DESC);
+
+-- Trade Offer Items Table. Details are copied from the collection so the offer still
+-- reads correctly after the entry changes hands or is removed.
+CREATE TABLE trade_offer_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    offer_id UUID NOT NULL REFERENCES trade_offers(id),
+    collection_item_id UUID REFERENCES collection_items(id) ON DELETE SET NULL,
+    -- Who gives the item up: the proposer or the recipient of the offer
+    owner_id UUID NOT NULL REFERENCES users(id),
+    name VARCHAR(200) NOT NULL,
+    condition_grade SMALLINT,
+    packaging TEXT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    UNIQUE (offer_id, collection_item_id)
+);
+
+CREATE INDEX idx_trade_offer_items_collection_item ON trade_offer_items (collection_item_id);
+
+-- Trade Offer History Table: an append-only record of every change of state
+CREATE TABLE trade_offer_history (
+    id BIGSERIAL PRIMARY KEY,
+    offer_id UUID NOT NULL REFERENCES trade_offers(id),
+    actor_id UUID NOT NULL REFERENCES users(id),
+    action TEXT NOT NULL CHECK (action IN ('proposed', 'countered', 'accepted', 'confirmed', 'declined', 'withdrawn', 'completed')),
+    from_status TEXT,
+    to_status TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_trade_offer_history_offer ON trade_offer_history (offer_id, id);
+
+-- Price Observations Table: what catalogue items have sold for, recorded when a listing
+-- sells or a trade settled in cash completes
+CREATE TABLE price_observations (
+    id BIGSERIAL PRIMARY KEY,
+    catalogue_item_id UUID NOT NULL REFERENCES catalogue_items(id) ON DELETE CASCADE,
+    source TEXT NOT NULL CHECK (source IN ('listing', 'trade')),
+    source_id UUID NOT NULL,
+    -- Price of a single unit
+    price_pence INTEGER NOT NULL CHECK (price_pence >= 0),
+    condition_grade SMALLINT CHECK (condition_grade BETWEEN 1 AND 10),
+    packaging TEXT,
+    observed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (source, source_id)
+);
+
+CREATE INDEX idx_price_observations_catalogue_item ON price_observations (catalogue_item_id, observed_at);
+
+-- Swap Proposals Table: trade cycles found by the swap matcher. A proposal goes ahead
+-- only once every participant has accepted it.
+CREATE TABLE swap_proposals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    -- The sorted collection item IDs, so the same cycle is never proposed twice
+    cycle_key TEXT NOT NULL UNIQUE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'expired', 'completed')),
+    total_distance_m DOUBLE PRECISION NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_swap_proposals_pending ON swap_proposals (expires_at) WHERE status = 'pending';
+
+-- Swap Proposal Legs Table: the giver passes the item to the receiver
+CREATE TABLE swap_proposal_legs (
+    proposal_id UUID NOT NULL REFERENCES swap_proposals(id) ON DELETE CASCADE,
+    position SMALLINT NOT NULL,
+    giver_id UUID NOT NULL REFERENCES users(id),
+    receiver_id UUID NOT NULL REFERENCES users(id),
+    collection_item_id UUID REFERENCES collection_items(id) ON DELETE SET NULL,
+    wishlist_item_id UUID REFERENCES wishlist_items(id) ON DELETE SET NULL,
+    name VARCHAR(200) NOT NULL,
+    -- Closest distance between the two users' markers; NULL when either has none
+    distance_m DOUBLE PRECISION,
+    PRIMARY KEY (proposal_id, position)
+);
+
+CREATE INDEX idx_swap_proposal_legs_collection_item ON swap_proposal_legs (collection_item_id);
+
+-- Swap Proposal Participants Table
+CREATE TABLE swap_proposal_participants (
+    proposal_id UUID NOT NULL REFERENCES swap_proposals(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    response TEXT NOT NULL DEFAULT 'pending' CHECK (response IN ('pending', 'accepted', 'declined')),
+    responded_at TIMESTAMP,
+    PRIMARY KEY (proposal_id, user_id)
+);
+
+CREATE INDEX idx_swap_proposal_participants_user ON swap_proposal_participants (user_id);
+
+-- Event RSVPs Table
+CREATE TABLE event_rsvps (
+    marker_id UUID NOT NULL REFERENCES user_markers(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Start of the occurrence responded to; each date of a series has its own RSVPs
+    occurrence_starts_at TIMESTAMPTZ NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('going', 'interested', 'not_going', 'waitlisted')),
+    -- Queue position for waitlisted RSVPs; earliest is promoted first
+    waitlisted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (marker_id, occurrence_starts_at, user_id)
+);
+
+CREATE INDEX idx_event_rsvps_user ON event_rsvps (user_id);
+CREATE INDEX idx_event_rsvps_waitlist ON event_rsvps (marker_id, occurrence_starts_at, waitlisted_at) WHERE status = 'waitlisted';
+
+-- Reminders already sent, one per attendee per occurrence
+CREATE TABLE event_reminders_sent (
+    marker_id UUID NOT NULL REFERENCES user_markers(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    occurrence_starts_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (marker_id, user_id, occurrence_starts_at)
+);
+
+-- Notifications Table
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    link TEXT,
+    read_at TIMESTAMP,
+    -- Whether it is shown in the notification centre, per the user's preferences
+    in_app BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Email delivery: pending is waiting to be sent, sending has been claimed by a run, and
+    -- failed has run out of attempts. NULL when the user doesn't want it by email.
+    email_status TEXT CHECK (email_status IN ('pending', 'sending', 'sent', 'failed')),
+    -- Whether it goes out with the next daily digest rather than on its own
+    email_digest BOOLEAN NOT NULL DEFAULT FALSE,
+    email_attempts SMALLINT NOT NULL DEFAULT 0,
+    -- While pending, not retried before this; while sending, when the claim lapses
+    email_retry_at TIMESTAMP,
+    emailed_at TIMESTAMP,
+    -- Web Push delivery: pending is sent to the user's browsers straight away. NULL when
+    -- the user doesn't want it pushed or has no push subscriptions.
+    push_status TEXT CHECK (push_status IN ('pending', 'sent', 'failed')),
+    pushed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_notifications_user_created ON notifications (user_id, created_at DESC);
+CREATE INDEX idx_notifications_unread ON notifications (user_id) WHERE read_at IS NULL AND in_app;
+CREATE INDEX idx_notifications_email ON notifications (email_digest, created_at) WHERE email_status IN ('pending', 'sending');
+CREATE INDEX idx_notifications_push ON notifications (created_at) WHERE push_status = 'pending';
+
+-- Notification Preferences Table: how each type of notification reaches a user on each
+-- channel. Types and channels without a row use the defaults in the notify package.
+CREATE TABLE notification_preferences (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    channel TEXT NOT NULL CHECK (channel IN ('in_app', 'email', 'push')),
+    delivery TEXT NOT NULL CHECK (delivery IN ('instant', 'daily', 'off')),
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, type, channel),
+    -- Only email is gathered into a daily digest
+    CHECK (delivery <> 'daily' OR channel = 'email')
+);
+
+-- VAPID Keys Table: the single key pair that identifies this API to push services.
+-- Browsers bind their subscriptions to its public key, so it is generated once and kept.
+CREATE TABLE vapid_keys (
+    id SMALLINT PRIMARY KEY CHECK (id = 1),
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Push Subscriptions Table: one row per browser or device a user has enabled push on.
+-- Subscriptions the push service reports gone are deleted.
+CREATE TABLE push_subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL UNIQUE,
+    p256dh TEXT NOT NULL,
+    auth TEXT NOT NULL,
+    user_agent TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX idx_push_subscriptions_user ON push_subscriptions (user_id);
+
+-- User Follows Table
+CREATE TABLE user_follows (
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX idx_user_follows_followee ON user_follows (followee_id);
+
+-- Activity Events Table: what users do, written in the same transaction as the change and
+-- shown in their followers' feeds while the subject is still there and visible
+CREATE TABLE activity_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK (type IN (
+        'marker_created', 'event_created', 'listing_published', 'collection_added', 'review_posted'
+    )),
+    -- The marker, listing, collection entry or review the event is about
+    subject_id UUID NOT NULL,
+    -- Details as they were at the time, e.g. the marker's name
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_activity_events_actor ON activity_events (actor_id, created_at DESC);
+
+-- Conversations Table: direct messages between two or more users, optionally about a
+-- marker or listing
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    marker_id UUID REFERENCES user_markers(id) ON DELETE SET NULL,
+    listing_id UUID REFERENCES listings(id) ON DELETE SET NULL,
+    last_message_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Conversation Participants Table. Muting silences notifications of new messages, and an
+-- archived conversation comes back to the inbox when someone writes in it.
+CREATE TABLE conversation_participants (
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Read receipts: every message up to this moment has been read
+    last_read_at TIMESTAMP,
+    muted BOOLEAN NOT NULL DEFAULT FALSE,
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX idx_conversation_participants_user ON conversation_participants (user_id);
+
+-- Messages Table. The sender is kept NULL rather than losing the message if their
+-- account row is ever removed.
+CREATE TABLE messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL CHECK (length(body) BETWEEN 1 AND 5000),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_messages_conversation_created ON messages (conversation_id, created_at DESC);
+
 -- Trigger to Auto-Update updated_at in user_bios
 CREATE OR REPLACE FUNCTION update_user_bio_timestamp()
 RETURNS TRIGGER AS $$
@@ -56,3 +696,290 @@ CREATE TRIGGER trigger_update_user_bio
 BEFORE UPDATE ON user_bios
 FOR EACH ROW
 EXECUTE FUNCTION update_user_bio_timestamp();
+
+-- Trigger to derive the public marker location from its precision setting.
+-- The exact point is snapped to a grid cell sized by precision, then placed at a
+-- position within that cell seeded only by the marker id and cell, so the public
+-- point is stable and reveals nothing finer than the cell.
+CREATE OR REPLACE FUNCTION fuzz_marker_location()
+RETURNS TRIGGER AS $$
+DECLARE
+    cell_m DOUBLE PRECISION;
+    lat_step DOUBLE PRECISION;
+    lng_step DOUBLE PRECISION;
+    cell_lat DOUBLE PRECISION;
+    cell_lng DOUBLE PRECISION;
+    seed TEXT;
+BEGIN
+    IF NEW.location_precision IS NULL THEN
+        NEW.location_precision := CASE WHEN NEW.marker_type = 'Collector' THEN 'neighbourhood' ELSE 'exact' END;
+    END IF;
+
+    cell_m := CASE NEW.location_precision
+        WHEN 'street' THEN 100
+        WHEN 'neighbourhood' THEN 750
+        WHEN 'town' THEN 5000
+        ELSE 0
+    END;
+
+    IF cell_m = 0 THEN
+        NEW.public_latitude := NEW.latitude;
+        NEW.public_longitude := NEW.longitude;
+        NEW.accuracy_m := 0;
+        RETURN NEW;
+    END IF;
+
+    lat_step := cell_m / 111320.0;
+    cell_lat := floor(NEW.latitude / lat_step) * lat_step;
+    lng_step := cell_m / (111320.0 * cos(radians(cell_lat + lat_step / 2)));
+    cell_lng := floor(NEW.longitude / lng_step) * lng_step;
+
+    seed := md5(NEW.id::text || ':' || cell_lat::text || ':' || cell_lng::text);
+    NEW.public_latitude := cell_lat + lat_step * ((('x' || substr(seed, 1, 8))::bit(32)::int::bigint + 2147483648) / 4294967296.0);
+    NEW.public_longitude := cell_lng + lng_step * ((('x' || substr(seed, 9, 8))::bit(32)::int::bigint + 2147483648) / 4294967296.0);
+    NEW.accuracy_m := cell_m * sqrt(2);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_fuzz_marker_location
+BEFORE INSERT OR UPDATE OF latitude, longitude, location_precision ON user_markers
+FOR EACH ROW
+EXECUTE FUNCTION fuzz_marker_location();
+
+-- Opening hours for a shop on a local date: an exception replaces the weekly hours,
+-- and bank holidays close shops that opt in
+CREATE OR REPLACE FUNCTION shop_hours_on(p_marker_id UUID, p_date DATE)
+RETURNS TABLE (opens_at TIME, closes_at TIME) AS $$
+    SELECT e.opens_at, e.closes_at
+    FROM shop_opening_exceptions e
+    WHERE e.marker_id = p_marker_id AND e.date = p_date AND e.opens_at IS NOT NULL
+    UNION ALL
+    SELECT h.opens_at, h.closes_at
+    FROM shop_opening_hours h
+    JOIN user_markers um ON um.id = h.marker_id
+    WHERE h.marker_id = p_marker_id
+      AND h.weekday = EXTRACT(ISODOW FROM p_date)
+      AND NOT EXISTS (SELECT 1 FROM shop_opening_exceptions e WHERE e.marker_id = p_marker_id AND e.date = p_date)
+      AND NOT (um.closed_on_bank_holidays AND EXISTS (SELECT 1 FROM bank_holidays b WHERE b.date = p_date))
+$$ LANGUAGE sql STABLE;
+
+-- Whether a shop is open at the given instant, evaluated in Europe/London time
+CREATE OR REPLACE FUNCTION shop_is_open(p_marker_id UUID, p_at TIMESTAMPTZ)
+RETURNS BOOLEAN AS $$
+    SELECT EXISTS (
+        SELECT 1
+        FROM shop_hours_on(p_marker_id, (p_at AT TIME ZONE 'Europe/London')::date) h
+        WHERE (p_at AT TIME ZONE 'Europe/London')::time >= h.opens_at
+          AND (p_at AT TIME ZONE 'Europe/London')::time < h.closes_at
+    )
+$$ LANGUAGE sql STABLE;
+
+-- When the shop's current opening period ends, or NULL if it is closed
+CREATE OR REPLACE FUNCTION shop_closing_time(p_marker_id UUID, p_at TIMESTAMPTZ)
+RETURNS TIMESTAMPTZ AS $$
+    SELECT MIN(((p_at AT TIME ZONE 'Europe/London')::date + h.closes_at) AT TIME ZONE 'Europe/London')
+    FROM shop_hours_on(p_marker_id, (p_at AT TIME ZONE 'Europe/London')::date) h
+    WHERE (p_at AT TIME ZONE 'Europe/London')::time >= h.opens_at
+      AND (p_at AT TIME ZONE 'Europe/London')::time < h.closes_at
+$$ LANGUAGE sql STABLE;
+
+-- The next time the shop opens after the given instant, looking up to two weeks ahead
+CREATE OR REPLACE FUNCTION shop_next_opening(p_marker_id UUID, p_at TIMESTAMPTZ)
+RETURNS TIMESTAMPTZ AS $$
+    SELECT MIN((d::date + h.opens_at) AT TIME ZONE 'Europe/London')
+    FROM generate_series(
+        (p_at AT TIME ZONE 'Europe/London')::date,
+        (p_at AT TIME ZONE 'Europe/London')::date + 14,
+        INTERVAL '1 day'
+    ) d
+    CROSS JOIN LATERAL shop_hours_on(p_marker_id, d::date) h
+    WHERE (d::date + h.opens_at) AT TIME ZONE 'Europe/London' > p_at
+$$ LANGUAGE sql STABLE;
+
+-- Keep user_markers.rating_avg and rating_count in step with marker_reviews
+CREATE OR REPLACE FUNCTION marker_review_stats()
+RETURNS TRIGGER AS $$
+DECLARE
+    target UUID;
+BEGIN
+    FOREACH target IN ARRAY ARRAY[
+        CASE WHEN TG_OP <> 'INSERT' THEN OLD.marker_id END,
+        CASE WHEN TG_OP <> 'DELETE' THEN NEW.marker_id END
+    ] LOOP
+        CONTINUE WHEN target IS NULL;
+        UPDATE user_markers SET
+            rating_avg = (SELECT ROUND(AVG(rating), 2) FROM marker_reviews WHERE marker_id = target),
+            rating_count = (SELECT COUNT(*) FROM marker_reviews WHERE marker_id = target)
+        WHERE id = target;
+    END LOOP;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_marker_review_stats
+AFTER INSERT OR DELETE OR UPDATE OF rating ON marker_reviews
+FOR EACH ROW
+EXECUTE FUNCTION marker_review_stats();
+
+-- Keep user_markers.favourite_count in step with marker_favourites
+CREATE OR REPLACE FUNCTION marker_favourite_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE user_markers SET favourite_count = favourite_count + 1 WHERE id = NEW.marker_id;
+    ELSE
+        UPDATE user_markers SET favourite_count = favourite_count - 1 WHERE id = OLD.marker_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_marker_favourite_count
+AFTER INSERT OR DELETE ON marker_favourites
+FOR EACH ROW
+EXECUTE FUNCTION marker_favourite_count();
+
+-- Trade offer history is append-only
+CREATE OR REPLACE FUNCTION prevent_trade_history_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'trade_offer_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_trade_history_append_only
+BEFORE UPDATE OR DELETE ON trade_offer_history
+FOR EACH ROW
+EXECUTE FUNCTION prevent_trade_history_changes();
+
+CREATE TRIGGER trigger_trade_history_no_truncate
+BEFORE TRUNCATE ON trade_offer_history
+FOR EACH STATEMENT
+EXECUTE FUNCTION prevent_trade_history_changes();
+
+-- Real-time updates: changes are announced on the 'realtime' channel, which every API
+-- instance listens on and fans out to its streaming clients. pg_notify is only delivered
+-- on commit, so nothing rolled back is ever announced.
+CREATE OR REPLACE FUNCTION notify_marker_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    m user_markers;
+    previous JSONB := '{}';
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        m := OLD;
+    ELSE
+        m := NEW;
+    END IF;
+    -- Clients that could see the marker where it was, or before its visibility changed,
+    -- need to hear that it has gone from their view
+    IF TG_OP = 'UPDATE' THEN
+        previous := jsonb_build_object(
+            'previous_latitude', OLD.public_latitude,
+            'previous_longitude', OLD.public_longitude,
+            'previous_visibility', OLD.visibility
+        );
+    END IF;
+    PERFORM pg_notify('realtime', jsonb_build_object(
+        'type', CASE TG_OP
+            WHEN 'INSERT' THEN 'marker_created'
+            WHEN 'UPDATE' THEN 'marker_updated'
+            ELSE 'marker_deleted'
+        END,
+        'marker', jsonb_build_object(
+            'id', m.id,
+            'owner_id', m.user_id,
+            'name', m.name,
+            'marker_type', m.marker_type,
+            'region', m.region,
+            'visibility', m.visibility,
+            'latitude', m.public_latitude,
+            'longitude', m.public_longitude
+        ) || previous
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_notify_marker_insert_delete
+AFTER INSERT OR DELETE ON user_markers
+FOR EACH ROW
+EXECUTE FUNCTION notify_marker_change();
+
+CREATE TRIGGER trigger_notify_marker_update
+AFTER UPDATE OF name, description, latitude, longitude, region, marker_type, location_precision, visibility ON user_markers
+FOR EACH ROW
+WHEN ((OLD.name, OLD.description, OLD.region, OLD.marker_type, OLD.visibility, OLD.public_latitude, OLD.public_longitude)
+    IS DISTINCT FROM (NEW.name, NEW.description, NEW.region, NEW.marker_type, NEW.visibility, NEW.public_latitude, NEW.public_longitude))
+EXECUTE FUNCTION notify_marker_change();
+
+CREATE OR REPLACE FUNCTION notify_new_message()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('realtime', jsonb_build_object(
+        'type', 'message',
+        'conversation_id', NEW.conversation_id,
+        'message_id', NEW.id,
+        'user_ids', (SELECT jsonb_agg(user_id) FROM conversation_participants WHERE conversation_id = NEW.conversation_id)
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_notify_new_message
+AFTER INSERT ON messages
+FOR EACH ROW
+EXECUTE FUNCTION notify_new_message();
+
+CREATE OR REPLACE FUNCTION notify_new_notification()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('realtime', jsonb_build_object(
+        'type', 'notification',
+        'user_ids', jsonb_build_array(NEW.user_id),
+        'notification', jsonb_build_object('id', NEW.id, 'type', NEW.type, 'title', NEW.title, 'link', NEW.link)
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_notify_new_notification
+AFTER INSERT ON notifications
+FOR EACH ROW
+WHEN (NEW.in_app)
+EXECUTE FUNCTION notify_new_notification();
+
+-- Blocks, mutes and follows change which markers a user's streams may show; instances reload them
+CREATE OR REPLACE FUNCTION notify_relations_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    r JSONB;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        r := to_jsonb(OLD);
+    ELSE
+        r := to_jsonb(NEW);
+    END IF;
+    PERFORM pg_notify('realtime', jsonb_build_object(
+        'type', 'relations_changed',
+        'user_ids', jsonb_build_array(COALESCE(r->>'blocker_id', r->>'muter_id', r->>'follower_id'))
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_notify_block_change
+AFTER INSERT OR DELETE ON user_blocks
+FOR EACH ROW
+EXECUTE FUNCTION notify_relations_change();
+
+CREATE TRIGGER trigger_notify_mute_change
+AFTER INSERT OR DELETE ON user_mutes
+FOR EACH ROW
+EXECUTE FUNCTION notify_relations_change();
+
+CREATE TRIGGER trigger_notify_follow_change
+AFTER INSERT OR DELETE ON user_follows
+FOR EACH ROW
+EXECUTE FUNCTION notify_relations_change();
@@ -0,0 +1,50 @@
+// Package activity records what users do, to make up the feeds of their followers
+package activity
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// Activity types
+const (
+	TypeMarkerCreated    = "marker_created"
+	TypeEventCreated     = "event_created"
+	TypeListingPublished = "listing_published"
+	TypeCollectionAdded  = "collection_added"
+	TypeReviewPosted     = "review_posted"
+)
+
+// Execer is satisfied by both *sql.DB and *sql.Tx
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Event is something a user did. SubjectID is the marker, listing, collection entry or
+// review it concerns, and Details are shown in the feed as they were at the time.
+type Event struct {
+	ActorID   uuid.UUID
+	Type      string
+	SubjectID string
+	Details   map[string]interface{}
+}
+
+// Record writes an event. Call it in the same transaction as the change it describes,
+// so a feed never shows something that was rolled back.
+func Record(ex Execer, e Event) error {
+	details, err := json.Marshal(e.Details)
+	if err != nil {
+		return err
+	}
+	if e.Details == nil {
+		details = []byte("{}")
+	}
+
+	_, err = ex.Exec(`
+		INSERT INTO activity_events (actor_id, type, subject_id, details)
+		VALUES ($1, $2, $3, $4)
+	`, e.ActorID, e.Type, e.SubjectID, string(details))
+	return err
+}
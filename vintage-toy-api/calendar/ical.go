@@ -0,0 +1,188 @@
+package calendar
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const prodID = "-//Blast From The Past//Vintage Toy Map//EN"
+
+// timezoneYears is how far beyond the last event, or now for open-ended series, the
+// VTIMEZONE transitions run
+const timezoneYears = 10
+
+// Event is a single VEVENT in an iCalendar feed
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Latitude    float64
+	Longitude   float64
+	Schedule    Schedule
+	Updated     time.Time
+}
+
+// WriteFeed writes a VCALENDAR containing the given events.
+// Recurring events are published with their RRULE rather than expanded, in local time
+// with a VTIMEZONE for each zone used, so they keep their wall-clock time across DST.
+func WriteFeed(w io.Writer, name string, events []Event) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:" + prodID,
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + escapeText(name),
+	}
+
+	// The span each zone's transitions must cover, in order of first use
+	type span struct {
+		loc      *time.Location
+		from, to time.Time
+	}
+	var zones []*span
+	byName := map[string]*span{}
+	horizon := time.Now()
+	for _, e := range events {
+		loc := e.Schedule.Start.Location()
+		z, ok := byName[loc.String()]
+		if !ok {
+			z = &span{loc: loc, from: e.Schedule.Start, to: horizon}
+			byName[loc.String()] = z
+			zones = append(zones, z)
+		}
+		if e.Schedule.Start.Before(z.from) {
+			z.from = e.Schedule.Start
+		}
+		if end, ok := e.Schedule.LastEnd(); ok && end.After(z.to) {
+			z.to = end
+		}
+	}
+	for _, z := range zones {
+		lines = append(lines, vtimezone(z.loc, z.from, z.to.AddDate(timezoneYears, 0, 0))...)
+	}
+
+	for _, e := range events {
+		tzid := e.Schedule.Start.Location().String()
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+e.UID,
+			"DTSTAMP:"+e.Updated.UTC().Format("20060102T150405Z"),
+			fmt.Sprintf("DTSTART;TZID=%s:%s", tzid, e.Schedule.Start.Format("20060102T150405")),
+			fmt.Sprintf("DTEND;TZID=%s:%s", tzid, e.Schedule.End.Format("20060102T150405")),
+			"SUMMARY:"+escapeText(e.Summary),
+		)
+		if e.Schedule.Rule != nil {
+			lines = append(lines, "RRULE:"+e.Schedule.Rule.String())
+		}
+		if len(e.Schedule.ExDates) > 0 {
+			dates := make([]string, 0, len(e.Schedule.ExDates))
+			for _, ex := range e.Schedule.ExDates {
+				dates = append(dates, ex.In(e.Schedule.Start.Location()).Format("20060102T150405"))
+			}
+			lines = append(lines, fmt.Sprintf("EXDATE;TZID=%s:%s", tzid, strings.Join(dates, ",")))
+		}
+		if e.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+escapeText(e.Description))
+		}
+		if e.Location != "" {
+			lines = append(lines, "LOCATION:"+escapeText(e.Location))
+		}
+		lines = append(lines,
+			fmt.Sprintf("GEO:%.6f;%.6f", e.Latitude, e.Longitude),
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, fold(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vtimezone describes a zone with one observance per offset change between from and to,
+// starting with the one in effect at from
+func vtimezone(loc *time.Location, from, to time.Time) []string {
+	lines := []string{"BEGIN:VTIMEZONE", "TZID:" + loc.String()}
+
+	t := from.In(loc)
+	name, offset := t.Zone()
+	start, _ := t.ZoneBounds()
+	if start.IsZero() {
+		start = time.Unix(0, 0)
+	}
+	lines = append(lines, observance(t.IsDST(), name, start, offset, offset)...)
+
+	for {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || end.After(to) {
+			break
+		}
+		next := end.In(loc)
+		nextName, nextOffset := next.Zone()
+		lines = append(lines, observance(next.IsDST(), nextName, end, offset, nextOffset)...)
+		t, offset = next, nextOffset
+	}
+	return append(lines, "END:VTIMEZONE")
+}
+
+// observance is a STANDARD or DAYLIGHT component taking effect at the instant at, whose
+// DTSTART is given in the local time of the offset it replaces
+func observance(dst bool, name string, at time.Time, fromOffset, toOffset int) []string {
+	kind := "STANDARD"
+	if dst {
+		kind = "DAYLIGHT"
+	}
+	return []string{
+		"BEGIN:" + kind,
+		"DTSTART:" + at.In(time.FixedZone("", fromOffset)).Format("20060102T150405"),
+		"TZOFFSETFROM:" + formatOffset(fromOffset),
+		"TZOFFSETTO:" + formatOffset(toOffset),
+		"TZNAME:" + escapeText(name),
+		"END:" + kind,
+	}
+}
+
+// formatOffset formats seconds east of UTC as an RFC 5545 UTC-OFFSET
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	out := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		out += fmt.Sprintf("%02d", seconds%60)
+	}
+	return out
+}
+
+// escapeText escapes a TEXT value per RFC 5545 section 3.3.11
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// fold splits a content line into 75-octet chunks without breaking UTF-8 sequences
+func fold(line string) string {
+	if len(line) <= 75 {
+		return line
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,463 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPeriods bounds how many recurrence periods are walked for a single expansion
+const maxPeriods = 5000
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// WeekdayNum is a BYDAY entry such as "SA" (N = 0), "1SA" or "-1SU"
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// Rule is the supported subset of an RFC 5545 RRULE: FREQ, INTERVAL, COUNT,
+// UNTIL, BYDAY, BYMONTHDAY and BYMONTH
+type Rule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// ParseRule parses an RRULE value, with or without the "RRULE:" prefix
+func ParseRule(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, errors.New("empty rule")
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			switch value = strings.ToUpper(value); value {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				rule.Freq = value
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, errors.New("INTERVAL must be a positive integer")
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, errors.New("COUNT must be a positive integer")
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wn, err := parseWeekdayNum(d)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wn)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY %q", d)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, m := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(m)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("invalid BYMONTH %q", m)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			// Weeks always start on Monday here, which is the RFC 5545 default
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot both be set")
+	}
+	if rule.Freq == "WEEKLY" && len(rule.ByMonthDay) > 0 {
+		return nil, errors.New("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	for _, wn := range rule.ByDay {
+		if wn.N == 0 {
+			continue
+		}
+		switch {
+		case rule.Freq == "DAILY" || rule.Freq == "WEEKLY":
+			return nil, fmt.Errorf("numbered BYDAY cannot be used with FREQ=%s", rule.Freq)
+		case rule.Freq == "YEARLY" && len(rule.ByMonth) == 0:
+			// Numbering within the whole year isn't supported
+			return nil, errors.New("numbered BYDAY with FREQ=YEARLY needs BYMONTH")
+		}
+	}
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+
+	day, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+
+	wn := WeekdayNum{Day: day}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+		}
+		wn.N = n
+	}
+	return wn, nil
+}
+
+// String formats the rule back into RRULE syntax
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wn := range r.ByDay {
+			day := strings.ToUpper(wn.Day.String()[:2])
+			if wn.N != 0 {
+				day = strconv.Itoa(wn.N) + day
+			}
+			days = append(days, day)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, d := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(d))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, 0, len(r.ByMonth))
+		for _, m := range r.ByMonth {
+			months = append(months, strconv.Itoa(int(m)))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrence is a single instance of a scheduled event
+type Occurrence struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Schedule is an event's first occurrence, in its own time zone, plus an optional recurrence
+type Schedule struct {
+	Start   time.Time
+	End     time.Time
+	Rule    *Rule
+	ExDates []time.Time
+}
+
+// Between returns the occurrences that overlap [from, to), in start order
+func (s Schedule) Between(from, to time.Time) []Occurrence {
+	var out []Occurrence
+	s.each(func(o Occurrence) bool {
+		if !o.Start.Before(to) {
+			return false
+		}
+		if o.End.After(from) {
+			out = append(out, o)
+		}
+		return true
+	})
+	return out
+}
+
+// LastEnd returns when the final occurrence ends. ok is false for open-ended series.
+func (s Schedule) LastEnd() (end time.Time, ok bool) {
+	if s.Rule != nil && s.Rule.Count == 0 && s.Rule.Until == nil {
+		return time.Time{}, false
+	}
+
+	end = s.End
+	s.each(func(o Occurrence) bool {
+		end = o.End
+		return true
+	})
+	return end, true
+}
+
+// each walks occurrences in order until fn returns false or the series ends
+func (s Schedule) each(fn func(Occurrence) bool) {
+	duration := s.End.Sub(s.Start)
+
+	excluded := make(map[int64]bool, len(s.ExDates))
+	for _, ex := range s.ExDates {
+		excluded[ex.Unix()] = true
+	}
+
+	emit := func(start time.Time) bool {
+		if excluded[start.Unix()] {
+			return true
+		}
+		return fn(Occurrence{Start: start, End: start.Add(duration)})
+	}
+
+	if s.Rule == nil {
+		emit(s.Start)
+		return
+	}
+
+	count := 0
+	for period := 0; period < maxPeriods; period++ {
+		for _, start := range s.candidates(period) {
+			if start.Before(s.Start) {
+				continue
+			}
+			if s.Rule.Until != nil && start.After(*s.Rule.Until) {
+				return
+			}
+			// COUNT includes excluded dates, as in RFC 5545
+			count++
+			if s.Rule.Count > 0 && count > s.Rule.Count {
+				return
+			}
+			if !emit(start) {
+				return
+			}
+		}
+	}
+}
+
+// candidates returns the sorted start times generated by the given recurrence period.
+// BYMONTH limits DAILY, WEEKLY and MONTHLY periods and expands YEARLY ones; BYDAY and
+// BYMONTHDAY limit DAILY periods and expand the others, as in RFC 5545.
+func (s Schedule) candidates(period int) []time.Time {
+	loc := s.Start.Location()
+	hour, min, sec := s.Start.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, min, sec, 0, loc)
+	}
+
+	var out []time.Time
+	step := period * s.Rule.Interval
+
+	switch s.Rule.Freq {
+	case "DAILY":
+		day := s.Start.AddDate(0, 0, step)
+		if s.inMonths(day.Month()) && s.onDay(day.Year(), day.Month(), day.Day()) {
+			out = append(out, at(day.Year(), day.Month(), day.Day()))
+		}
+
+	case "WEEKLY":
+		// Weeks run Monday to Sunday
+		offset := (int(s.Start.Weekday()) + 6) % 7
+		monday := s.Start.AddDate(0, 0, step*7-offset)
+		days := s.Rule.ByDay
+		if len(days) == 0 {
+			days = []WeekdayNum{{Day: s.Start.Weekday()}}
+		}
+		for _, wn := range days {
+			day := monday.AddDate(0, 0, (int(wn.Day)+6)%7)
+			if s.inMonths(day.Month()) {
+				out = append(out, at(day.Year(), day.Month(), day.Day()))
+			}
+		}
+
+	case "MONTHLY":
+		first := time.Date(s.Start.Year(), s.Start.Month()+time.Month(step), 1, 0, 0, 0, 0, loc)
+		if s.inMonths(first.Month()) {
+			out = s.inMonth(first.Year(), first.Month(), at)
+		}
+
+	case "YEARLY":
+		year := s.Start.Year() + step
+		months := s.Rule.ByMonth
+		if len(months) == 0 && (len(s.Rule.ByDay) > 0 || len(s.Rule.ByMonthDay) > 0) {
+			for m := time.January; m <= time.December; m++ {
+				months = append(months, m)
+			}
+		} else if len(months) == 0 {
+			months = []time.Month{s.Start.Month()}
+		}
+		for _, m := range months {
+			out = append(out, s.inMonth(year, m, at)...)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	// Overlapping parts such as BYMONTHDAY=31,-1 can name the same day twice
+	unique := out[:0]
+	for i, t := range out {
+		if i == 0 || !t.Equal(out[i-1]) {
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
+
+// inMonths reports whether BYMONTH, if set, includes the month
+func (s Schedule) inMonths(month time.Month) bool {
+	if len(s.Rule.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range s.Rule.ByMonth {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+// onDay reports whether a day passes BYMONTHDAY and BYDAY, where set. Numbered BYDAY
+// entries count within the month.
+func (s Schedule) onDay(year int, month time.Month, day int) bool {
+	daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	if len(s.Rule.ByMonthDay) > 0 {
+		matched := false
+		for _, d := range s.Rule.ByMonthDay {
+			matched = matched || d == day || daysInMonth+d+1 == day
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(s.Rule.ByDay) > 0 {
+		weekday := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday()
+		matched := false
+		for _, wn := range s.Rule.ByDay {
+			matched = matched || (wn.Day == weekday &&
+				(wn.N == 0 || wn.N == (day-1)/7+1 || -wn.N == (daysInMonth-day)/7+1))
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// inMonth expands BYDAY/BYMONTHDAY within one month, defaulting to the start's day of
+// month. When both are set, only days matching both are kept.
+func (s Schedule) inMonth(year int, month time.Month, at func(int, time.Month, int) time.Time) []time.Time {
+	daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	var out []time.Time
+
+	if len(s.Rule.ByMonthDay) == 0 && len(s.Rule.ByDay) == 0 {
+		if s.Start.Day() <= daysInMonth {
+			out = append(out, at(year, month, s.Start.Day()))
+		}
+		return out
+	}
+	for d := 1; d <= daysInMonth; d++ {
+		if s.onDay(year, month, d) {
+			out = append(out, at(year, month, d))
+		}
+	}
+	return out
+}
+
+// LocalTimeLayout is the wall-clock format stored for event times, which are paired with a time zone
+const LocalTimeLayout = "2006-01-02T15:04:05"
+
+// ParseLocal parses a wall-clock time, with or without seconds, in the given zone
+func ParseLocal(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{LocalTimeLayout, "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected YYYY-MM-DDTHH:MM", value)
+}
+
+// NewSchedule builds a schedule from wall-clock times in the named time zone
+func NewSchedule(startsAt, endsAt, timezone string, rrule *string, exdates []string) (Schedule, error) {
+	var s Schedule
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return s, err
+	}
+	if s.Start, err = ParseLocal(startsAt, loc); err != nil {
+		return s, err
+	}
+	if s.End, err = ParseLocal(endsAt, loc); err != nil {
+		return s, err
+	}
+	if rrule != nil {
+		if s.Rule, err = ParseRule(*rrule); err != nil {
+			return s, err
+		}
+	}
+	for _, ex := range exdates {
+		t, err := ParseLocal(ex, loc)
+		if err != nil {
+			return s, err
+		}
+		s.ExDates = append(s.ExDates, t)
+	}
+	return s, nil
+}
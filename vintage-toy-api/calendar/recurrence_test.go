@@ -0,0 +1,95 @@
+package calendar
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScheduleOccurrences(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		start string
+		rule  string
+		want  []string
+	}{
+		{"daily", "2025-03-01", "FREQ=DAILY;COUNT=3",
+			[]string{"2025-03-01 10:00 GMT", "2025-03-02 10:00 GMT", "2025-03-03 10:00 GMT"}},
+		{"daily on weekends", "2025-03-03", "FREQ=DAILY;BYDAY=SA,SU;COUNT=4",
+			[]string{"2025-03-08 10:00 GMT", "2025-03-09 10:00 GMT", "2025-03-15 10:00 GMT", "2025-03-16 10:00 GMT"}},
+		{"daily in January", "2025-01-30", "FREQ=DAILY;BYMONTH=1;COUNT=3",
+			[]string{"2025-01-30 10:00 GMT", "2025-01-31 10:00 GMT", "2026-01-01 10:00 GMT"}},
+		{"weekly on two days", "2025-03-04", "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=4",
+			[]string{"2025-03-04 10:00 GMT", "2025-03-06 10:00 GMT", "2025-03-11 10:00 GMT", "2025-03-13 10:00 GMT"}},
+		{"weekly in December", "2025-11-20", "FREQ=WEEKLY;BYMONTH=12;COUNT=3",
+			[]string{"2025-12-04 10:00 GMT", "2025-12-11 10:00 GMT", "2025-12-18 10:00 GMT"}},
+		{"weekly across the clocks changing", "2025-03-22", "FREQ=WEEKLY;COUNT=3",
+			[]string{"2025-03-22 10:00 GMT", "2025-03-29 10:00 GMT", "2025-04-05 10:00 BST"}},
+		{"monthly on the first Saturday", "2025-03-01", "FREQ=MONTHLY;BYDAY=1SA;COUNT=3",
+			[]string{"2025-03-01 10:00 GMT", "2025-04-05 10:00 BST", "2025-05-03 10:00 BST"}},
+		{"monthly on the last Sunday", "2025-03-01", "FREQ=MONTHLY;BYDAY=-1SU;COUNT=2",
+			[]string{"2025-03-30 10:00 BST", "2025-04-27 10:00 BST"}},
+		{"monthly on Friday the 13th", "2025-01-01", "FREQ=MONTHLY;BYMONTHDAY=13;BYDAY=FR;COUNT=2",
+			[]string{"2025-06-13 10:00 BST", "2026-02-13 10:00 GMT"}},
+		{"monthly in some months", "2025-01-15", "FREQ=MONTHLY;BYMONTH=6,12;COUNT=3",
+			[]string{"2025-06-15 10:00 BST", "2025-12-15 10:00 GMT", "2026-06-15 10:00 BST"}},
+		{"monthly on the last day, named twice", "2025-01-01", "FREQ=MONTHLY;BYMONTHDAY=31,-1;COUNT=2",
+			[]string{"2025-01-31 10:00 GMT", "2025-02-28 10:00 GMT"}},
+		{"yearly on the fourth Thursday of November", "2025-01-01", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=2",
+			[]string{"2025-11-27 10:00 GMT", "2026-11-26 10:00 GMT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			s, err := NewSchedule(tt.start+"T10:00", tt.start+"T12:00", "Europe/London", &rule, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from := time.Date(2025, 1, 1, 0, 0, 0, 0, london)
+			var got []string
+			for _, o := range s.Between(from, from.AddDate(3, 0, 0)) {
+				got = append(got, o.Start.Format("2006-01-02 15:04 MST"))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRuleRejects(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"COUNT=3",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=2;UNTIL=20250101",
+		"FREQ=DAILY;BYDAY=1SA",
+		"FREQ=WEEKLY;BYDAY=-1SU",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=YEARLY;BYDAY=1SA",
+		"FREQ=MONTHLY;BYDAY=6SA",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTH=13",
+	} {
+		if _, err := ParseRule(rule); err == nil {
+			t.Errorf("ParseRule(%q) succeeded, want an error", rule)
+		}
+	}
+}
+
+func TestRuleRoundTrip(t *testing.T) {
+	const s = "FREQ=MONTHLY;INTERVAL=2;COUNT=5;BYDAY=1SA,-1SU;BYMONTHDAY=1,-1;BYMONTH=6,12"
+	rule, err := ParseRule("RRULE:" + s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rule.String(); got != s {
+		t.Errorf("String() = %q, want %q", got, s)
+	}
+}
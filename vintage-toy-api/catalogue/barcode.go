@@ -0,0 +1,133 @@
+package catalogue
+
+import (
+	"errors"
+	"strings"
+)
+
+// Barcode formats recognised by NormaliseBarcode
+const (
+	FormatEAN13 = "EAN-13"
+	FormatEAN8  = "EAN-8"
+	FormatUPCA  = "UPC-A"
+	FormatUPCE  = "UPC-E"
+)
+
+// ErrInvalidBarcode is returned for codes that aren't a valid EAN-13, EAN-8, UPC-A or UPC-E
+var ErrInvalidBarcode = errors.New("invalid barcode, expected EAN-13, EAN-8, UPC-A or UPC-E")
+
+// Barcode is a scanned code in its canonical 13-digit EAN-13 form. UPC-A codes are
+// EAN-13 codes with a leading zero, UPC-E codes are compressed UPC-A codes, and EAN-8
+// codes are padded with leading zeros as GTINs are.
+type Barcode struct {
+	EAN13  string
+	Format string
+	// Scanned is the code as read, without spaces or hyphens
+	Scanned string
+}
+
+// UPCA returns the 12-digit UPC-A form of a code starting with zero, or "" otherwise
+func (b Barcode) UPCA() string {
+	if strings.HasPrefix(b.EAN13, "0") {
+		return b.EAN13[1:]
+	}
+	return ""
+}
+
+// Forms lists every way the code may have been recorded: as EAN-13, as UPC-A and as scanned
+func (b Barcode) Forms() []string {
+	forms := []string{b.EAN13}
+	if upca := b.UPCA(); upca != "" {
+		forms = append(forms, upca)
+	}
+	if b.Scanned != b.EAN13 && b.Scanned != b.UPCA() {
+		forms = append(forms, b.Scanned)
+	}
+	return forms
+}
+
+// checkDigit returns the GS1 check digit for the digits of data: from the right, every
+// other digit starting with the last is weighted 3, the rest 1
+func checkDigit(data string) byte {
+	sum := 0
+	for i := len(data) - 1; i >= 0; i-- {
+		d := int(data[i] - '0')
+		if (len(data)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// expandUPCE turns the number system digit and six data digits of a UPC-E code into the
+// eleven data digits of the UPC-A code it stands for
+func expandUPCE(system byte, d string) string {
+	switch d[5] {
+	case '0', '1', '2':
+		return string(system) + d[0:2] + d[5:6] + "0000" + d[2:5]
+	case '3':
+		return string(system) + d[0:3] + "00000" + d[3:5]
+	case '4':
+		return string(system) + d[0:4] + "00000" + d[4:5]
+	default:
+		return string(system) + d[0:5] + "0000" + d[5:6]
+	}
+}
+
+// NormaliseBarcode validates a scanned EAN-13 (13 digits), UPC-A (12 digits), EAN-8 or UPC-E
+// code (8 digits, or the 6 digits printed between UPC-E guard bars) and returns its EAN-13
+// form. An 8-digit code is read as EAN-8 when its check digit fits, and as UPC-E otherwise;
+// a 6-digit code carries no check digit, so it can't be verified. Spaces and hyphens are
+// ignored.
+func NormaliseBarcode(code string) (Barcode, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(code))
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return Barcode{}, ErrInvalidBarcode
+		}
+	}
+
+	switch len(digits) {
+	case 13:
+		if checkDigit(digits[:12]) != digits[12] {
+			return Barcode{}, ErrInvalidBarcode
+		}
+		format := FormatEAN13
+		if digits[0] == '0' {
+			format = FormatUPCA
+		}
+		return Barcode{EAN13: digits, Format: format, Scanned: digits}, nil
+	case 12:
+		if checkDigit(digits[:11]) != digits[11] {
+			return Barcode{}, ErrInvalidBarcode
+		}
+		return Barcode{EAN13: "0" + digits, Format: FormatUPCA, Scanned: digits}, nil
+	case 8:
+		if checkDigit(digits[:7]) == digits[7] {
+			return Barcode{EAN13: "00000" + digits, Format: FormatEAN8, Scanned: digits}, nil
+		}
+		fallthrough
+	case 6:
+		system, data := byte('0'), digits
+		if len(digits) == 8 {
+			system, data = digits[0], digits[1:7]
+		}
+		if system != '0' && system != '1' {
+			return Barcode{}, ErrInvalidBarcode
+		}
+		upca := expandUPCE(system, data)
+		check := checkDigit(upca)
+		if len(digits) == 8 && check != digits[7] {
+			return Barcode{}, ErrInvalidBarcode
+		}
+		return Barcode{EAN13: "0" + upca + string(check), Format: FormatUPCE, Scanned: digits}, nil
+	default:
+		return Barcode{}, ErrInvalidBarcode
+	}
+}
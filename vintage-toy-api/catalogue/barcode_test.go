@@ -0,0 +1,87 @@
+package catalogue
+
+import "testing"
+
+func TestCheckDigit(t *testing.T) {
+	tests := []struct {
+		data string
+		want byte
+	}{
+		{"400638133393", '1'},
+		{"03600029145", '2'},
+		{"9638507", '4'},
+		{"0425261", '0'},
+		{"04210000526", '4'},
+	}
+	for _, tt := range tests {
+		if got := checkDigit(tt.data); got != tt.want {
+			t.Errorf("checkDigit(%q) = %c, want %c", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestExpandUPCE(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"last digit 0 to 2", "425261", "04210000526"},
+		{"last digit 3", "123453", "01230000045"},
+		{"last digit 4", "123454", "01234000005"},
+		{"last digit 5 to 9", "123457", "01234500007"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandUPCE('0', tt.data); got != tt.want {
+				t.Errorf("expandUPCE(%q) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormaliseBarcode(t *testing.T) {
+	tests := []struct {
+		code   string
+		ean13  string
+		format string
+	}{
+		{"4006381333931", "4006381333931", FormatEAN13},
+		{"400-6381 333931", "4006381333931", FormatEAN13},
+		{"036000291452", "0036000291452", FormatUPCA},
+		{"0036000291452", "0036000291452", FormatUPCA},
+		{"96385074", "0000096385074", FormatEAN8},
+		// Valid as both EAN-8 and UPC-E; EAN-8 wins
+		{"04252610", "0000004252610", FormatEAN8},
+		{"04252614", "0042100005264", FormatUPCE},
+		{"425261", "0042100005264", FormatUPCE},
+	}
+	for _, tt := range tests {
+		b, err := NormaliseBarcode(tt.code)
+		if err != nil {
+			t.Errorf("NormaliseBarcode(%q) error: %v", tt.code, err)
+			continue
+		}
+		if b.EAN13 != tt.ean13 || b.Format != tt.format {
+			t.Errorf("NormaliseBarcode(%q) = %s %s, want %s %s", tt.code, b.EAN13, b.Format, tt.ean13, tt.format)
+		}
+	}
+}
+
+func TestNormaliseBarcodeRejects(t *testing.T) {
+	for _, code := range []string{
+		"",
+		"abc",
+		"12345",
+		"4006381333932",
+		"036000291453",
+		// Neither check digit fits
+		"04252615",
+		// Not EAN-8, and UPC-E only has number systems 0 and 1
+		"24252615",
+	} {
+		if _, err := NormaliseBarcode(code); err != ErrInvalidBarcode {
+			t.Errorf("NormaliseBarcode(%q) error = %v, want ErrInvalidBarcode", code, err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package catalogue
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+
+	"github.com/lib/pq"
+)
+
+// MaxImportRecords caps how many records a single bulk load may contain
+const MaxImportRecords = 5000
+
+// ErrInvalidImport is wrapped by every error caused by the records themselves rather than the database
+var ErrInvalidImport = errors.New("invalid import")
+
+// csvColumns are the headers understood by ParseCSV. Only franchise, line and name are required.
+var csvColumns = []string{"franchise", "line", "manufacturer", "name", "year", "variant", "sku", "barcode", "images", "description"}
+
+// ParseJSON reads a JSON array of import records
+func ParseJSON(r io.Reader) ([]models.CatalogueImportRecord, error) {
+	var records []models.CatalogueImportRecord
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return records, nil
+}
+
+// ParseCSV reads import records from CSV with a header row. Columns may appear in any
+// order, and multiple image URLs in one cell are separated by "|".
+func ParseCSV(r io.Reader) ([]models.CatalogueImportRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing CSV header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, required := range []string{"franchise", "line", "name"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing the %q column", required)
+		}
+	}
+	for h := range index {
+		if !contains(csvColumns, h) {
+			return nil, fmt.Errorf("unknown CSV column %q", h)
+		}
+	}
+
+	var records []models.CatalogueImportRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		get := func(col string) string {
+			if i, ok := index[col]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		rec := models.CatalogueImportRecord{
+			Franchise:    get("franchise"),
+			Line:         get("line"),
+			Manufacturer: get("manufacturer"),
+			Name:         get("name"),
+			Variant:      get("variant"),
+			SKU:          get("sku"),
+			Barcode:      get("barcode"),
+			Description:  get("description"),
+		}
+		if y := get("year"); y != "" {
+			year, err := strconv.Atoi(y)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid year %q", line, y)
+			}
+			rec.Year = &year
+		}
+		if images := get("images"); images != "" {
+			for _, img := range strings.Split(images, "|") {
+				if img = strings.TrimSpace(img); img != "" {
+					rec.Images = append(rec.Images, img)
+				}
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Import validates and upserts records in one transaction, so a bad row loads nothing.
+// Barcodes are stored in their EAN-13 form.
+// Items are matched first by barcode, then by line, name, variant and year.
+func Import(db *sql.DB, records []models.CatalogueImportRecord) (models.CatalogueImportResult, error) {
+	var result models.CatalogueImportResult
+	if len(records) == 0 {
+		return result, fmt.Errorf("%w: no records to import", ErrInvalidImport)
+	}
+	if len(records) > MaxImportRecords {
+		return result, fmt.Errorf("%w: at most %d records can be imported at once", ErrInvalidImport, MaxImportRecords)
+	}
+	// Barcodes are stored in EAN-13 form, without touching the caller's records
+	records = append([]models.CatalogueImportRecord(nil), records...)
+	for i, rec := range records {
+		if err := models.Validate.Struct(rec); err != nil {
+			return result, fmt.Errorf("%w: record %d: %v", ErrInvalidImport, i+1, err)
+		}
+		if Slugify(rec.Franchise) == "" || Slugify(rec.Line) == "" {
+			return result, fmt.Errorf("%w: record %d: franchise and line need at least one letter or digit", ErrInvalidImport, i+1)
+		}
+		if strings.TrimSpace(rec.Barcode) == "" {
+			records[i].Barcode = ""
+			continue
+		}
+		b, err := NormaliseBarcode(rec.Barcode)
+		if err != nil {
+			return result, fmt.Errorf("%w: record %d: %v", ErrInvalidImport, i+1, err)
+		}
+		records[i].Barcode = b.EAN13
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	franchises := map[string]string{}
+	lines := map[string]string{}
+	manufacturers := map[string]string{}
+
+	for i, rec := range records {
+		franchiseSlug := Slugify(rec.Franchise)
+		franchiseID, ok := franchises[franchiseSlug]
+		if !ok {
+			franchiseID, err = upsertReturningID(tx, `
+				INSERT INTO catalogue_franchises (name, slug) VALUES ($1, $2)
+				ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+				RETURNING id
+			`, rec.Franchise, franchiseSlug)
+			if err != nil {
+				return result, fmt.Errorf("record %d: %w", i+1, err)
+			}
+			franchises[franchiseSlug] = franchiseID
+		}
+
+		lineSlug := Slugify(rec.Line)
+		lineKey := franchiseID + "/" + lineSlug
+		lineID, ok := lines[lineKey]
+		if !ok {
+			lineID, err = upsertReturningID(tx, `
+				INSERT INTO catalogue_lines (franchise_id, name, slug) VALUES ($1, $2, $3)
+				ON CONFLICT (franchise_id, slug) DO UPDATE SET slug = EXCLUDED.slug
+				RETURNING id
+			`, franchiseID, rec.Line, lineSlug)
+			if err != nil {
+				return result, fmt.Errorf("record %d: %w", i+1, err)
+			}
+			lines[lineKey] = lineID
+		}
+
+		var manufacturerID *string
+		if manufacturerSlug := Slugify(rec.Manufacturer); manufacturerSlug != "" {
+			id, ok := manufacturers[manufacturerSlug]
+			if !ok {
+				id, err = upsertReturningID(tx, `
+					INSERT INTO catalogue_manufacturers (name, slug) VALUES ($1, $2)
+					ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+					RETURNING id
+				`, rec.Manufacturer, manufacturerSlug)
+				if err != nil {
+					return result, fmt.Errorf("record %d: %w", i+1, err)
+				}
+				manufacturers[manufacturerSlug] = id
+			}
+			manufacturerID = &id
+		}
+
+		created, err := upsertItem(tx, lineID, manufacturerID, rec)
+		if err != nil {
+			return result, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+	}
+
+	return result, tx.Commit()
+}
+
+func upsertReturningID(tx *sql.Tx, query string, args ...interface{}) (string, error) {
+	var id string
+	err := tx.QueryRow(query, args...).Scan(&id)
+	return id, err
+}
+
+// upsertItem inserts or updates one item, reporting whether it was new
+func upsertItem(tx *sql.Tx, lineID string, manufacturerID *string, rec models.CatalogueImportRecord) (bool, error) {
+	var existingID string
+	err := tx.QueryRow(`
+		SELECT id FROM catalogue_items
+		WHERE (barcode IS NOT NULL AND barcode = NULLIF($1, ''))
+		   OR (line_id = $2 AND lower(name) = lower($3) AND variant = $4 AND COALESCE(year, 0) = COALESCE($5, 0))
+		ORDER BY (barcode = NULLIF($1, '')) DESC NULLS LAST
+		LIMIT 1
+	`, rec.Barcode, lineID, rec.Name, rec.Variant, rec.Year).Scan(&existingID)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+
+	images := rec.Images
+	if images == nil {
+		images = []string{}
+	}
+
+	if err == sql.ErrNoRows {
+		_, err = tx.Exec(`
+			INSERT INTO catalogue_items (line_id, manufacturer_id, name, year, variant, sku, barcode, images, description)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, NULLIF($9, ''))
+		`, lineID, manufacturerID, rec.Name, rec.Year, rec.Variant, rec.SKU, rec.Barcode, pq.Array(images), rec.Description)
+		return true, err
+	}
+
+	// Empty fields in the import leave the stored values alone
+	_, err = tx.Exec(`
+		UPDATE catalogue_items
+		SET line_id = $1,
+			manufacturer_id = COALESCE($2, manufacturer_id),
+			name = $3,
+			year = COALESCE($4, year),
+			variant = $5,
+			sku = COALESCE(NULLIF($6, ''), sku),
+			barcode = COALESCE(NULLIF($7, ''), barcode),
+			images = CASE WHEN cardinality($8::text[]) > 0 THEN $8 ELSE images END,
+			description = COALESCE(NULLIF($9, ''), description),
+			updated_at = NOW()
+		WHERE id = $10
+	`, lineID, manufacturerID, rec.Name, rec.Year, rec.Variant, rec.SKU, rec.Barcode, pq.Array(images), rec.Description, existingID)
+	return false, err
+}
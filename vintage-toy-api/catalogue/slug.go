@@ -0,0 +1,32 @@
+package catalogue
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Slugify turns a name into a lowercase, hyphen-separated URL segment,
+// e.g. "Masters of the Universe" becomes "masters-of-the-universe"
+func Slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFKD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Drop accents left behind by decomposition
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(unicode.ToLower(r))
+		case r == '\'' || r == '’':
+			// "Hasbro's" reads better as "hasbros" than "hasbro-s"
+		default:
+			hyphen = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,48 @@
+// Command catalogue-import seeds the toy catalogue from a CSV or JSON file:
+//
+//	go run ./cmd/catalogue-import catalogue.csv
+//
+// It uses the same database settings as the API server.
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/catalogue"
+	"github.com/Joseph_Bartram8/vintage-toy-api/db"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: catalogue-import <file.csv|file.json>")
+	}
+	path := os.Args[1]
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal("❌ Could not open import file:", err)
+	}
+	defer f.Close()
+
+	var records []models.CatalogueImportRecord
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		records, err = catalogue.ParseCSV(f)
+	} else {
+		records, err = catalogue.ParseJSON(f)
+	}
+	if err != nil {
+		log.Fatal("❌ Could not parse import file:", err)
+	}
+
+	db.ConnectDB()
+
+	result, err := catalogue.Import(db.DB, records)
+	if err != nil {
+		log.Fatal("❌ Import failed:", err)
+	}
+	log.Printf("✅ Imported %d records: %d created, %d updated", len(records), result.Created, result.Updated)
+}
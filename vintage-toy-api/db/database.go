@@ -12,6 +12,10 @@ import (
 
 var DB *sql.DB
 
+// URL is the connection string DB was opened with, for connections of its own such as
+// the realtime listener
+var URL string
+
 func ConnectDB() {
 	// Check if running locally (Render sets `RENDER` environment variable)
 	_, isRender := os.LookupEnv("RENDER")
@@ -39,6 +43,8 @@ func ConnectDB() {
 		)
 	}
 
+	URL = dbURL
+
 	// Connect to PostgreSQL
 	var err error
 	DB, err = sql.Open("postgres", dbURL)
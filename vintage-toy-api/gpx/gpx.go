@@ -0,0 +1,51 @@
+package gpx
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Waypoint is a single named point
+type Waypoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Name string  `xml:"name,omitempty"`
+	Desc string  `xml:"desc,omitempty"`
+}
+
+// Route is an ordered list of points to follow
+type Route struct {
+	Name   string     `xml:"name,omitempty"`
+	Points []Waypoint `xml:"rtept"`
+}
+
+// Document is a GPX 1.1 file
+type Document struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Xmlns     string     `xml:"xmlns,attr"`
+	Name      string     `xml:"metadata>name,omitempty"`
+	Waypoints []Waypoint `xml:"wpt"`
+	Routes    []Route    `xml:"rte"`
+}
+
+// New returns an empty GPX 1.1 document with the given name
+func New(name string) *Document {
+	return &Document{
+		Version: "1.1",
+		Creator: "Blast From The Past",
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		Name:    name,
+	}
+}
+
+// Write encodes the document as indented XML
+func (d *Document) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(d)
+}
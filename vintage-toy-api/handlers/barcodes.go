@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/catalogue"
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// maxBarcodeMatches caps how many catalogue items a barcode lookup returns
+const maxBarcodeMatches = 20
+
+// lookupBarcode finds the catalogue items whose barcode or manufacturer SKU is any form of
+// the scanned code, barcode matches first
+func lookupBarcode(db *sql.DB, b catalogue.Barcode) (models.BarcodeLookup, error) {
+	lookup := models.BarcodeLookup{Barcode: b.EAN13, Format: b.Format, Items: []models.CatalogueItem{}}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %s
+		%s
+		WHERE ci.barcode = ANY($1) OR lower(ci.sku) = ANY($1)
+		ORDER BY (ci.barcode = ANY($1)) DESC, ci.name, ci.id
+		LIMIT %d
+	`, catalogueItemColumns, catalogueItemFrom, maxBarcodeMatches), pq.Array(b.Forms()))
+	if err != nil {
+		return lookup, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanCatalogueItem(rows)
+		if err != nil {
+			return lookup, err
+		}
+		lookup.Items = append(lookup.Items, item)
+	}
+	return lookup, rows.Err()
+}
+
+// queueUnknownBarcode adds a barcode nobody could find to the curators' queue, or counts
+// another scan of one already there. A dismissed barcode stays dismissed.
+func queueUnknownBarcode(db *sql.DB, b catalogue.Barcode, userID uuid.UUID) error {
+	_, err := db.Exec(`
+		INSERT INTO unknown_barcodes (barcode, format, first_scanned_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (barcode) DO UPDATE
+		SET scan_count = unknown_barcodes.scan_count + 1,
+			last_seen_at = NOW(),
+			status = CASE WHEN unknown_barcodes.status = 'resolved' THEN 'pending' ELSE unknown_barcodes.status END
+	`, b.EAN13, b.Format, userID)
+	return err
+}
+
+// LookupBarcodeHandler validates and normalises a scanned EAN-13, UPC-A or UPC-E code from
+// ?barcode= and lists the catalogue items it belongs to
+func LookupBarcodeHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := catalogue.NormaliseBarcode(r.URL.Query().Get("barcode"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		lookup, err := lookupBarcode(db, b)
+		if err != nil {
+			log.Println("Barcode lookup error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lookup)
+	}
+}
+
+// ScanCollectionItemHandler adds the catalogue item with a scanned barcode to the
+// authenticated user's collection in one call. A barcode matching several items is
+// answered with 409 and the candidates, to be retried with catalogue_item_id. One that
+// matches nothing is queued for curators and answered with 202.
+func ScanCollectionItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req models.ScanCollectionItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		b, err := catalogue.NormaliseBarcode(req.Barcode)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		lookup, err := lookupBarcode(db, b)
+		if err != nil {
+			log.Println("Barcode lookup error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(lookup.Items) == 0 {
+			if err := queueUnknownBarcode(db, b, userID); err != nil {
+				log.Println("Queue unknown barcode error:", err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": "Barcode not in the catalogue yet; it has been sent to the curators",
+				"barcode": b.EAN13,
+			})
+			return
+		}
+
+		var match *models.CatalogueItem
+		for i, item := range lookup.Items {
+			if len(lookup.Items) == 1 || (req.CatalogueItemID != nil && item.ID == *req.CatalogueItemID) {
+				match = &lookup.Items[i]
+				break
+			}
+		}
+		if match == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(lookup)
+			return
+		}
+
+		add := req.CreateCollectionItemRequest
+		add.CatalogueItemID, add.Name = &match.ID, nil
+		id, status, err := addCollectionItem(db, userID, add)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "catalogue_item": match})
+	}
+}
+
+// GetUnknownBarcodesHandler lists the curators' queue of barcodes missing from the catalogue,
+// most scanned first. Supports ?status=pending|resolved|dismissed (default pending),
+// ?sort=scans|last_seen, ?limit= and ?cursor=
+func GetUnknownBarcodesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts: map[string]string{
+				"scans":     "ub.scan_count",
+				"last_seen": "ub.last_seen_at",
+			},
+			DefaultSort: "-scans",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = "pending"
+		} else if status != "pending" && status != "resolved" && status != "dismissed" {
+			http.Error(w, "Invalid status", http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{status}
+		conditions := []string{"ub.status = $1"}
+		if clause, cursorArgs := page.Where("ub.barcode", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT ub.barcode, ub.format, ub.scan_count, ub.status, ub.catalogue_item_id, ub.resolved_at,
+				ub.first_seen_at, ub.last_seen_at, %s
+			FROM unknown_barcodes ub
+			WHERE %s
+			%s
+		`, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("ub.barcode")), args...)
+		if err != nil {
+			log.Println("Unknown barcodes query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var barcodes []models.UnknownBarcode
+		var keys [][2]string
+		for rows.Next() {
+			var b models.UnknownBarcode
+			var sortKey string
+			err := rows.Scan(&b.Barcode, &b.Format, &b.ScanCount, &b.Status, &b.CatalogueItemID, &b.ResolvedAt,
+				&b.FirstSeenAt, &b.LastSeenAt, &sortKey)
+			if err != nil {
+				http.Error(w, "Error scanning barcodes", http.StatusInternalServerError)
+				return
+			}
+			barcodes = append(barcodes, b)
+			keys = append(keys, [2]string{sortKey, b.Barcode})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(barcodes, keys, page))
+	}
+}
+
+// ResolveUnknownBarcodeHandler gives a queued barcode to the catalogue item it belongs to,
+// so the next scan finds it. The item must not already have a different barcode.
+func ResolveUnknownBarcodeHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		barcode := chi.URLParam(r, "barcode")
+
+		var req models.ResolveBarcodeRequest
+		if !decodeCatalogueRequest(w, r, &req) {
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var status string
+		err = tx.QueryRow("SELECT status FROM unknown_barcodes WHERE barcode = $1 FOR UPDATE", barcode).Scan(&status)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Barcode not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if status != "pending" {
+			http.Error(w, "Barcode is already "+status, http.StatusConflict)
+			return
+		}
+
+		var current sql.NullString
+		err = tx.QueryRow("SELECT barcode FROM catalogue_items WHERE id = $1 FOR UPDATE", req.CatalogueItemID).Scan(&current)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Item not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if current.Valid && current.String != barcode {
+			http.Error(w, "Item already has barcode "+current.String, http.StatusConflict)
+			return
+		}
+
+		_, err = tx.Exec("UPDATE catalogue_items SET barcode = $1, updated_at = NOW() WHERE id = $2", barcode, req.CatalogueItemID)
+		if err != nil {
+			catalogueWriteError(w, err, "Resolve barcode")
+			return
+		}
+		_, err = tx.Exec(`
+			UPDATE unknown_barcodes
+			SET status = 'resolved', catalogue_item_id = $1, resolved_by = $2, resolved_at = NOW()
+			WHERE barcode = $3
+		`, req.CatalogueItemID, userID, barcode)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Barcode added to the catalogue item"})
+	}
+}
+
+// DismissUnknownBarcodeHandler removes a barcode from the curators' queue without adding it
+// to the catalogue, e.g. when it isn't a toy. Later scans are counted without reopening it.
+func DismissUnknownBarcodeHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+
+		result, err := db.Exec(`
+			UPDATE unknown_barcodes
+			SET status = 'dismissed', resolved_by = $1, resolved_at = NOW()
+			WHERE barcode = $2 AND status = 'pending'
+		`, userID, chi.URLParam(r, "barcode"))
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Barcode not found in the queue", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Barcode dismissed"})
+	}
+}
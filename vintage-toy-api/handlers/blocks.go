@@ -0,0 +1,465 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// errBlocked is returned when a block stands between the caller and the user they are
+// trying to reach
+var errBlocked = errors.New("You cannot interact with a user who has blocked you or whom you have blocked")
+
+// notHiddenFrom returns a condition on a user ID column that leaves out users the viewer
+// bound to $viewerArg has blocked or muted. Anonymous viewers see everyone.
+func notHiddenFrom(viewerArg int, userColumn string) string {
+	return fmt.Sprintf(`NOT EXISTS (
+		SELECT 1 FROM user_blocks WHERE blocker_id = $%[1]d::uuid AND blocked_id = %[2]s
+		UNION ALL
+		SELECT 1 FROM user_mutes WHERE muter_id = $%[1]d::uuid AND muted_id = %[2]s)`, viewerArg, userColumn)
+}
+
+// hiddenUserIDs returns the users a viewer has blocked or muted, for filtering outside SQL
+func hiddenUserIDs(db *sql.DB, viewerID uuid.UUID) (map[string]bool, error) {
+	rows, err := db.Query(`
+		SELECT blocked_id FROM user_blocks WHERE blocker_id = $1
+		UNION
+		SELECT muted_id FROM user_mutes WHERE muter_id = $1
+	`, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hidden := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		hidden[id] = true
+	}
+	return hidden, rows.Err()
+}
+
+// notBlockedBy returns a condition on a user ID column that leaves out users the viewer
+// bound to $viewerArg has blocked, for what a mute leaves alone: profiles and messages
+func notBlockedBy(viewerArg int, userColumn string) string {
+	return fmt.Sprintf(`NOT EXISTS (
+		SELECT 1 FROM user_blocks WHERE blocker_id = $%d::uuid AND blocked_id = %s)`, viewerArg, userColumn)
+}
+
+// blockedBetween reports whether a block stands, either way, between userID and any of others
+func blockedBetween(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, userID uuid.UUID, others ...string) (bool, error) {
+	var blocked bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = $1 AND blocked_id = ANY($2::uuid[]))
+				OR (blocked_id = $1 AND blocker_id = ANY($2::uuid[]))
+		)
+	`, userID, pq.Array(others)).Scan(&blocked)
+	return blocked, err
+}
+
+// profileVisible reports whether a user exists and the viewer, if any, hasn't blocked them
+func profileVisible(db *sql.DB, userID uuid.UUID, viewerID interface{}) (bool, error) {
+	var visible bool
+	err := db.QueryRow(fmt.Sprintf(`
+		SELECT EXISTS (SELECT 1 FROM users u WHERE u.id = $1 AND u.is_deleted = FALSE AND %s)
+	`, notBlockedBy(2, "u.id")), userID, viewerID).Scan(&visible)
+	return visible, err
+}
+
+// withdrawRSVPs cancels the RSVPs either of two users has made to the other's events,
+// promoting from the waitlists where places are freed
+func withdrawRSVPs(tx *sql.Tx, a, b uuid.UUID) error {
+	// Lock the events first, in a fixed order, as promoteWaitlist requires
+	rows, err := tx.Query(`
+		SELECT um.id FROM user_markers um
+		WHERE EXISTS (
+			SELECT 1 FROM event_rsvps er
+			WHERE er.marker_id = um.id
+				AND ((um.user_id = $1 AND er.user_id = $2) OR (um.user_id = $2 AND er.user_id = $1)))
+		ORDER BY um.id
+		FOR UPDATE OF um
+	`, a, b)
+	if err != nil {
+		return err
+	}
+	var markerIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		markerIDs = append(markerIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, markerID := range markerIDs {
+		rows, err := tx.Query(`
+			WITH withdrawn AS (
+				DELETE FROM event_rsvps
+				WHERE marker_id = $1 AND user_id IN ($2, $3)
+				RETURNING occurrence_starts_at, status
+			)
+			SELECT DISTINCT occurrence_starts_at FROM withdrawn
+			WHERE status = 'going'
+			ORDER BY occurrence_starts_at
+		`, markerID, a, b)
+		if err != nil {
+			return err
+		}
+		var freed []time.Time
+		for rows.Next() {
+			var occurrence time.Time
+			if err := rows.Scan(&occurrence); err != nil {
+				rows.Close()
+				return err
+			}
+			freed = append(freed, occurrence)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, occurrence := range freed {
+			if err := promoteWaitlist(tx, markerID, occurrence); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cancelDealings ends the open trade offers and swap proposals between a blocker and the
+// user they blocked. Offers are withdrawn or declined in the blocker's name, depending on
+// which side of the offer they are on; swaps both take part in are declined.
+func cancelDealings(tx *sql.Tx, blockerID, blockedID uuid.UUID) error {
+	rows, err := tx.Query(`
+		WITH cancelled AS (
+			SELECT id, status FROM trade_offers
+			WHERE status IN ('pending', 'accepted')
+				AND ((proposer_id = $1 AND recipient_id = $2) OR (proposer_id = $2 AND recipient_id = $1))
+			FOR UPDATE
+		)
+		UPDATE trade_offers t
+		SET status = CASE WHEN t.proposer_id = $1 THEN 'withdrawn' ELSE 'declined' END, updated_at = NOW()
+		FROM cancelled c
+		WHERE t.id = c.id
+		RETURNING t.id, c.status, t.status
+	`, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+	type change struct{ id, from, to string }
+	var changes []change
+	for rows.Next() {
+		var c change
+		if err := rows.Scan(&c.id, &c.from, &c.to); err != nil {
+			rows.Close()
+			return err
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if err := recordTradeEvent(tx, c.id, blockerID, c.to, &c.from, c.to); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		WITH cancelled AS (
+			UPDATE swap_proposals sp SET status = 'declined', updated_at = NOW()
+			WHERE sp.status IN ('pending', 'accepted')
+				AND EXISTS (SELECT 1 FROM swap_proposal_participants WHERE proposal_id = sp.id AND user_id = $1)
+				AND EXISTS (SELECT 1 FROM swap_proposal_participants WHERE proposal_id = sp.id AND user_id = $2)
+			RETURNING sp.id
+		)
+		UPDATE swap_proposal_participants SET response = 'declined', responded_at = NOW()
+		WHERE user_id = $1 AND proposal_id IN (SELECT id FROM cancelled)
+	`, blockerID, blockedID)
+	return err
+}
+
+// targetUser reads the {id} of a block or mute, which must be another, existing user
+func targetUser(w http.ResponseWriter, r *http.Request, db *sql.DB, userID uuid.UUID) (uuid.UUID, bool) {
+	targetID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	if targetID == userID {
+		http.Error(w, "You cannot do that to yourself", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND is_deleted = FALSE)", targetID).Scan(&exists)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return uuid.Nil, false
+	}
+	if !exists {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return uuid.Nil, false
+	}
+	return targetID, true
+}
+
+// BlockUserHandler blocks a user for the authenticated user. Any follows between them are
+// removed, as are RSVPs either has made to the other's events, and their open trade offers
+// and swap proposals are called off. Blocking someone already blocked is not an error.
+func BlockUserHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		blockedID, ok := targetUser(w, r, db, userID)
+		if !ok {
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(`
+			INSERT INTO user_blocks (blocker_id, blocked_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, userID, blockedID)
+		if err != nil {
+			log.Printf("Block user error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = tx.Exec(`
+			DELETE FROM user_follows
+			WHERE (follower_id = $1 AND followee_id = $2) OR (follower_id = $2 AND followee_id = $1)
+		`, userID, blockedID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := withdrawRSVPs(tx, userID, blockedID); err != nil {
+			log.Printf("Block RSVP withdrawal error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := cancelDealings(tx, userID, blockedID); err != nil {
+			log.Printf("Block trade cancellation error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "User blocked"})
+	}
+}
+
+// UnblockUserHandler lifts one of the authenticated user's blocks. Follows removed by the
+// block are not restored.
+func UnblockUserHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		blockedID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2", userID, blockedID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "You have not blocked this user", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "User unblocked"})
+	}
+}
+
+// MuteUserHandler mutes a user for the authenticated user. Muting someone already muted
+// is not an error.
+func MuteUserHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		mutedID, ok := targetUser(w, r, db, userID)
+		if !ok {
+			return
+		}
+
+		_, err := db.Exec(`
+			INSERT INTO user_mutes (muter_id, muted_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, userID, mutedID)
+		if err != nil {
+			log.Printf("Mute user error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "User muted"})
+	}
+}
+
+// UnmuteUserHandler lifts one of the authenticated user's mutes
+func UnmuteUserHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		mutedID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2", userID, mutedID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "You have not muted this user", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "User unmuted"})
+	}
+}
+
+// restrictedListHandler lists the users the authenticated user has blocked ("user_blocks")
+// or muted ("user_mutes"). Supports ?sort=since|name, ?limit= and ?cursor=
+func restrictedListHandler(db *sql.DB, table string) http.HandlerFunc {
+	owner, other := "t.blocker_id", "t.blocked_id"
+	if table == "user_mutes" {
+		owner, other = "t.muter_id", "t.muted_id"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts:       map[string]string{"since": "t.created_at", "name": "ub.display_name"},
+			DefaultSort: "-since",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{userID}
+		conditions := []string{owner + " = $1", "u.is_deleted = FALSE"}
+		if clause, cursorArgs := page.Where("u.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, t.created_at, %s
+			FROM %s t
+			JOIN users u ON %s = u.id
+			JOIN user_bios ub ON u.id = ub.user_id
+			WHERE %s
+			%s
+		`, userSummaryColumns, page.SortValue(), table, other, strings.Join(conditions, " AND "), page.OrderBy("u.id")), args...)
+		if err != nil {
+			log.Println("Restricted users query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var users []models.RestrictedUser
+		var keys [][2]string
+		for rows.Next() {
+			var restricted models.RestrictedUser
+			var sortKey string
+			restricted.User, err = scanUserSummary(rows, &restricted.Since, &sortKey)
+			if err != nil {
+				http.Error(w, "Error scanning users", http.StatusInternalServerError)
+				return
+			}
+			users = append(users, restricted)
+			keys = append(keys, [2]string{sortKey, restricted.User.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(users, keys, page))
+	}
+}
+
+// GetBlockedUsersHandler lists the users the authenticated user has blocked
+func GetBlockedUsersHandler(db *sql.DB) http.HandlerFunc {
+	return restrictedListHandler(db, "user_blocks")
+}
+
+// GetMutedUsersHandler lists the users the authenticated user has muted
+func GetMutedUsersHandler(db *sql.DB) http.HandlerFunc {
+	return restrictedListHandler(db, "user_mutes")
+}
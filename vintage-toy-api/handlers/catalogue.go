@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// catalogueItemColumns returns the columns read by scanCatalogueItem
+const catalogueItemColumns = `
+	ci.id, ci.name, ci.year, ci.variant, ci.sku, ci.barcode, ci.images, ci.description, ci.created_at, ci.updated_at,
+	cl.id, cl.name, cl.slug, cf.id, cf.name, cf.slug, cm.id, cm.name, cm.slug`
+
+// catalogueItemFrom joins the tables read by catalogueItemColumns
+const catalogueItemFrom = `
+	FROM catalogue_items ci
+	JOIN catalogue_lines cl ON ci.line_id = cl.id
+	JOIN catalogue_franchises cf ON cl.franchise_id = cf.id
+	LEFT JOIN catalogue_manufacturers cm ON ci.manufacturer_id = cm.id`
+
+// scanCatalogueItem reads a row selected with catalogueItemColumns, followed by any extra columns
+func scanCatalogueItem(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.CatalogueItem, error) {
+	var item models.CatalogueItem
+	var year sql.NullInt64
+	var manufacturerID, manufacturerName, manufacturerSlug sql.NullString
+
+	dest := []interface{}{
+		&item.ID, &item.Name, &year, &item.Variant, &item.SKU, &item.Barcode, pq.Array(&item.Images), &item.Description,
+		&item.CreatedAt, &item.UpdatedAt,
+		&item.Line.ID, &item.Line.Name, &item.Line.Slug, &item.Franchise.ID, &item.Franchise.Name, &item.Franchise.Slug,
+		&manufacturerID, &manufacturerName, &manufacturerSlug,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return item, err
+	}
+
+	if year.Valid {
+		y := int(year.Int64)
+		item.Year = &y
+	}
+	if manufacturerID.Valid {
+		item.Manufacturer = &models.CatalogueRef{ID: manufacturerID.String, Name: manufacturerName.String, Slug: manufacturerSlug.String}
+	}
+	if item.Images == nil {
+		item.Images = []string{}
+	}
+	return item, nil
+}
+
+// uuidFilter appends an equality condition for an optional UUID query parameter
+func uuidFilter(r *http.Request, param, column string, args *[]interface{}, conditions *[]string) error {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return fmt.Errorf("Invalid %s", param)
+	}
+	*args = append(*args, id)
+	*conditions = append(*conditions, fmt.Sprintf("%s = $%d", column, len(*args)))
+	return nil
+}
+
+// GetFranchisesHandler lists catalogue franchises. Supports ?q=, ?sort=name, ?limit= and ?cursor=
+func GetFranchisesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts:       map[string]string{"name": "cf.name"},
+			DefaultSort: "name",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var args []interface{}
+		conditions := []string{"TRUE"}
+		if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+			args = append(args, q)
+			conditions = append(conditions, fmt.Sprintf("(cf.name ILIKE '%%' || $%[1]d || '%%' OR cf.name %% $%[1]d)", len(args)))
+		}
+		if clause, cursorArgs := page.Where("cf.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT cf.id, cf.name, cf.slug, cf.description, cf.created_at, %s
+			FROM catalogue_franchises cf
+			WHERE %s
+			%s
+		`, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("cf.id")), args...)
+		if err != nil {
+			log.Println("Franchises query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var franchises []models.Franchise
+		var keys [][2]string
+		for rows.Next() {
+			var f models.Franchise
+			var sortKey string
+			if err := rows.Scan(&f.ID, &f.Name, &f.Slug, &f.Description, &f.CreatedAt, &sortKey); err != nil {
+				http.Error(w, "Error scanning franchises", http.StatusInternalServerError)
+				return
+			}
+			franchises = append(franchises, f)
+			keys = append(keys, [2]string{sortKey, f.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(franchises, keys, page))
+	}
+}
+
+// GetManufacturersHandler lists catalogue manufacturers. Supports ?q=, ?sort=name, ?limit= and ?cursor=
+func GetManufacturersHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts:       map[string]string{"name": "cm.name"},
+			DefaultSort: "name",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var args []interface{}
+		conditions := []string{"TRUE"}
+		if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+			args = append(args, q)
+			conditions = append(conditions, fmt.Sprintf("(cm.name ILIKE '%%' || $%[1]d || '%%' OR cm.name %% $%[1]d)", len(args)))
+		}
+		if clause, cursorArgs := page.Where("cm.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT cm.id, cm.name, cm.slug, cm.country, cm.created_at, %s
+			FROM catalogue_manufacturers cm
+			WHERE %s
+			%s
+		`, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("cm.id")), args...)
+		if err != nil {
+			log.Println("Manufacturers query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var manufacturers []models.Manufacturer
+		var keys [][2]string
+		for rows.Next() {
+			var m models.Manufacturer
+			var sortKey string
+			if err := rows.Scan(&m.ID, &m.Name, &m.Slug, &m.Country, &m.CreatedAt, &sortKey); err != nil {
+				http.Error(w, "Error scanning manufacturers", http.StatusInternalServerError)
+				return
+			}
+			manufacturers = append(manufacturers, m)
+			keys = append(keys, [2]string{sortKey, m.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(manufacturers, keys, page))
+	}
+}
+
+// GetProductLinesHandler lists catalogue product lines.
+// Supports ?franchise_id=, ?q=, ?sort=name|start_year, ?limit= and ?cursor=
+func GetProductLinesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts: map[string]string{
+				"name":       "cl.name",
+				"start_year": "COALESCE(cl.start_year, 0)",
+			},
+			DefaultSort: "name",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var args []interface{}
+		conditions := []string{"TRUE"}
+		if err := uuidFilter(r, "franchise_id", "cl.franchise_id", &args, &conditions); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+			args = append(args, q)
+			conditions = append(conditions, fmt.Sprintf("(cl.name ILIKE '%%' || $%[1]d || '%%' OR cl.name %% $%[1]d)", len(args)))
+		}
+		if clause, cursorArgs := page.Where("cl.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT cl.id, cl.name, cl.slug, cl.start_year, cl.end_year, cl.description, cl.created_at,
+				cf.id, cf.name, cf.slug, %s
+			FROM catalogue_lines cl
+			JOIN catalogue_franchises cf ON cl.franchise_id = cf.id
+			WHERE %s
+			%s
+		`, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("cl.id")), args...)
+		if err != nil {
+			log.Println("Product lines query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var lines []models.ProductLine
+		var keys [][2]string
+		for rows.Next() {
+			var l models.ProductLine
+			var startYear, endYear sql.NullInt64
+			var sortKey string
+			err := rows.Scan(&l.ID, &l.Name, &l.Slug, &startYear, &endYear, &l.Description, &l.CreatedAt,
+				&l.Franchise.ID, &l.Franchise.Name, &l.Franchise.Slug, &sortKey)
+			if err != nil {
+				http.Error(w, "Error scanning product lines", http.StatusInternalServerError)
+				return
+			}
+			if startYear.Valid {
+				y := int(startYear.Int64)
+				l.StartYear = &y
+			}
+			if endYear.Valid {
+				y := int(endYear.Int64)
+				l.EndYear = &y
+			}
+			lines = append(lines, l)
+			keys = append(keys, [2]string{sortKey, l.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(lines, keys, page))
+	}
+}
+
+// GetCatalogueItemsHandler browses and searches the catalogue.
+// Supports ?q=, ?franchise_id=, ?line_id=, ?manufacturer_id=, ?year=, ?barcode=,
+// ?sort=name|year|created_at|relevance, ?limit= and ?cursor=
+func GetCatalogueItemsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		opts := pagination.Options{
+			Sorts: map[string]string{
+				"name":       "ci.name",
+				"year":       "COALESCE(ci.year, 0)",
+				"created_at": "ci.created_at",
+			},
+			DefaultSort: "name",
+		}
+
+		var args []interface{}
+		conditions := []string{"TRUE"}
+
+		// Relevance ranking is only meaningful, and only offered, when searching
+		if term := strings.TrimSpace(q.Get("q")); term != "" {
+			args = append(args, term)
+			conditions = append(conditions, fmt.Sprintf(
+				"(ci.search_vector @@ websearch_to_tsquery('uk_english', $%[1]d) OR ci.name %% $%[1]d OR ci.sku = $%[1]d)", len(args)))
+			opts.Sorts["relevance"] = fmt.Sprintf(
+				"(ts_rank_cd(ci.search_vector, websearch_to_tsquery('uk_english', $%[1]d)) + similarity(ci.name, $%[1]d))::float8", len(args))
+			opts.DefaultSort = "-relevance"
+		}
+
+		for _, filter := range [][2]string{
+			{"franchise_id", "cl.franchise_id"},
+			{"line_id", "ci.line_id"},
+			{"manufacturer_id", "ci.manufacturer_id"},
+		} {
+			if err := uuidFilter(r, filter[0], filter[1], &args, &conditions); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if year := q.Get("year"); year != "" {
+			y, err := strconv.Atoi(year)
+			if err != nil {
+				http.Error(w, "Invalid year", http.StatusBadRequest)
+				return
+			}
+			args = append(args, y)
+			conditions = append(conditions, fmt.Sprintf("ci.year = $%d", len(args)))
+		}
+		if barcode := q.Get("barcode"); barcode != "" {
+			args = append(args, barcode)
+			conditions = append(conditions, fmt.Sprintf("ci.barcode = $%d", len(args)))
+		}
+
+		page, err := pagination.FromRequest(r, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if clause, cursorArgs := page.Where("ci.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, %s
+			%s
+			WHERE %s
+			%s
+		`, catalogueItemColumns, page.SortValue(), catalogueItemFrom, strings.Join(conditions, " AND "), page.OrderBy("ci.id")), args...)
+		if err != nil {
+			log.Println("Catalogue items query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var items []models.CatalogueItem
+		var keys [][2]string
+		for rows.Next() {
+			var sortKey string
+			item, err := scanCatalogueItem(rows, &sortKey)
+			if err != nil {
+				log.Println("Row scan error:", err)
+				http.Error(w, "Error scanning catalogue items", http.StatusInternalServerError)
+				return
+			}
+			items = append(items, item)
+			keys = append(keys, [2]string{sortKey, item.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(items, keys, page))
+	}
+}
+
+// GetCatalogueItemHandler returns a single catalogue item
+func GetCatalogueItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid item ID", http.StatusBadRequest)
+			return
+		}
+
+		item, err := scanCatalogueItem(db.QueryRow(fmt.Sprintf(`
+			SELECT %s
+			%s
+			WHERE ci.id = $1
+		`, catalogueItemColumns, catalogueItemFrom), itemID))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Item not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("Catalogue item query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(item)
+	}
+}
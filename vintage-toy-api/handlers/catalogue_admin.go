@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/catalogue"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// maxCatalogueImportBytes caps the size of a bulk catalogue upload
+const maxCatalogueImportBytes = 10 << 20
+
+// catalogueConstraintErrors are the messages shown for violations of the catalogue's
+// unique and check constraints, by constraint name
+var catalogueConstraintErrors = map[string]string{
+	"catalogue_franchises_slug_key":         "A franchise with that slug already exists",
+	"catalogue_manufacturers_slug_key":      "A manufacturer with that slug already exists",
+	"catalogue_lines_franchise_id_slug_key": "A product line with that slug already exists in the franchise",
+	"catalogue_lines_check":                 "Invalid input: end_year must not be before start_year",
+	"catalogue_items_barcode_key":           "An item with that barcode already exists",
+	"idx_catalogue_items_natural_key":       "An item with that name, variant and year already exists in the line",
+	"catalogue_items_year_check":            "Invalid input: year must be between 1850 and 2100",
+}
+
+// catalogueWriteError maps constraint violations from catalogue writes to client errors.
+// Anything else, including constraints without a message, is logged rather than shown.
+func catalogueWriteError(w http.ResponseWriter, err error, action string) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		log.Printf("%s error: %v", action, err)
+		http.Error(w, "Error saving catalogue entry", http.StatusInternalServerError)
+		return
+	}
+
+	message, known := catalogueConstraintErrors[pqErr.Constraint]
+	switch pqErr.Code.Name() {
+	case "unique_violation":
+		if !known {
+			log.Printf("%s error: %v", action, err)
+			message = "A catalogue entry with those details already exists"
+		}
+		http.Error(w, message, http.StatusConflict)
+	case "foreign_key_violation":
+		http.Error(w, "Referenced catalogue entry not found, or entry is still in use", http.StatusConflict)
+	case "check_violation":
+		if !known {
+			log.Printf("%s error: %v", action, err)
+			message = "Invalid input"
+		}
+		http.Error(w, message, http.StatusBadRequest)
+	default:
+		log.Printf("%s error: %v", action, err)
+		http.Error(w, "Error saving catalogue entry", http.StatusInternalServerError)
+	}
+}
+
+// decodeCatalogueRequest decodes and validates an admin request body into req
+func decodeCatalogueRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return false
+	}
+	if err := models.Validate.Struct(req); err != nil {
+		http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// normaliseItemBarcode replaces a barcode in a request with its EAN-13 form, so lookups
+// find it however it is scanned. A blank barcode is left empty.
+func normaliseItemBarcode(w http.ResponseWriter, barcode *string) bool {
+	if barcode == nil {
+		return true
+	}
+	if strings.TrimSpace(*barcode) == "" {
+		*barcode = ""
+		return true
+	}
+	b, err := catalogue.NormaliseBarcode(*barcode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	*barcode = b.EAN13
+	return true
+}
+
+// CreateFranchiseHandler adds a catalogue franchise
+func CreateFranchiseHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.CreateFranchiseRequest
+		if !decodeCatalogueRequest(w, r, &req) {
+			return
+		}
+
+		slug := catalogue.Slugify(req.Name)
+		if slug == "" {
+			http.Error(w, "Name needs at least one letter or digit", http.StatusBadRequest)
+			return
+		}
+
+		var id string
+		err := db.QueryRow("INSERT INTO catalogue_franchises (name, slug, description) VALUES ($1, $2, $3) RETURNING id",
+			req.Name, slug, req.Description).Scan(&id)
+		if err != nil {
+			catalogueWriteError(w, err, "Create franchise")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "slug": slug})
+	}
+}
+
+// UpdateFranchiseHandler edits a catalogue franchise; renaming it also changes its slug
+func UpdateFranchiseHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid franchise ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.UpdateFranchiseRequest
+		if !decodeCatalogueRequest(w, r, &req) {
+			return
+		}
+
+		var slug *string
+		if req.Name != nil {
+			s := catalogue.Slugify(*req.Name)
+			if s == "" {
+				http.Error(w, "Name needs at least one letter or digit", http.StatusBadRequest)
+				return
+			}
+			slug = &s
+		}
+
+		result, err := db.Exec(`
+			UPDATE catalogue_franchises
+			SET name = COALESCE($1, name),
+				slug = COALESCE($2, slug),
+				description = COALESCE($3, description),
+				updated_at = NOW()
+			WHERE id = $4
+		`, req.Name, slug, req.Description, id)
+		if err != nil {
+			catalogueWriteError(w, err, "Update franchise")
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Franchise not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Franchise updated successfully"})
+	}
+}
+
+// DeleteFranchiseHandler removes a catalogue franchise that has no product lines
+func DeleteFranchiseHandler(db *sql.DB) http.HandlerFunc {
+	return deleteCatalogueEntry(db, "catalogue_franchises", "Franchise")
+}
+
+// CreateManufacturerHandler adds a catalogue manufacturer
+func CreateManufacturerHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.CreateManufacturerRequest
+		if !decodeCatalogueRequest(w, r, &req) {
+			return
+		}
+
+		slug := catalogue.Slugify(req.Name)
+		if slug == "" {
+			http.Error(w, "Name needs at least one letter or digit", http.StatusBadRequest)
+			return
+		}
+
+		var id string
+		err := db.QueryRow("INSERT INTO catalogue_manufacturers (name, slug, country) VALUES ($1, $2, $3) RETURNING id",
+			req.Name, slug, req.Country).Scan(&id)
+		if err != nil {
+			catalogueWriteError(w, err, "Create manufacturer")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "slug": slug})
+	}
+}
+
+// UpdateManufacturerHandler edits a catalogue manufacturer; renaming it also changes its slug
+func UpdateManufacturerHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid manufacturer ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.UpdateManufacturerRequest
+		if !decodeCatalogueRequest(w, r, &req) {
+			return
+		}
+
+		var slug *string
+		if req.Name != nil {
+			s := catalogue.Slugify(*req.Name)
+			if s == "" {
+				http.Error(w, "Name needs at least one letter or digit", http.StatusBadRequest)
+				return
+			}
+			slug = &s
+		}
+
+		result, err := db.Exec(`
+			UPDATE catalogue_manufacturers
+			SET name = COALESCE($1, name),
+				slug = COALESCE($2, slug),
+				country = COALESCE($3, country),
+				updated_at = NOW()
+			WHERE id = $4
+		`, req.Name, slug, req.Country, id)
+		if err != nil {
+			catalogueWriteError(w, err, "Update manufacturer")
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Manufacturer not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Manufacturer updated successfully"})
+	}
+}
+
+// DeleteManufacturerHandler removes a catalogue manufacturer; its items are kept without one
+func DeleteManufacturerHandler(db *sql.DB) http.HandlerFunc {
+	return deleteCatalogueEntry(db, "catalogue_manufacturers", "Manufacturer")
+}
+
+// CreateProductLineHandler adds a product line to a franchise
+func CreateProductLineHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.CreateLineRequest
+		if !decodeCatalogueRequest(w, r, &req) {
+			return
+		}
+
+		slug := catalogue.Slugify(req.Name)
+		if slug == "" {
+			http.Error(w, "Name needs at least one letter or digit", http.StatusBadRequest)
+			return
+		}
+
+		var id string
+		err := db.QueryRow(`
+			INSERT INTO catalogue_lines (franchise_id, name, slug, start_year, end_year, description)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, req.FranchiseID, req.Name, slug, req.StartYear, req.EndYear, req.Description).Scan(&id)
+		if err != nil {
+			catalogueWriteError(w, err, "Create product line")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "slug": slug})
+	}
+}
+
+// UpdateProductLineHandler edits a product line; renaming it also changes its slug
+func UpdateProductLineHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid product line ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.UpdateLineRequest
+		if !decodeCatalogueRequest(w, r, &req) {
+			return
+		}
+
+		var slug *string
+		if req.Name != nil {
+			s := catalogue.Slugify(*req.Name)
+			if s == "" {
+				http.Error(w, "Name needs at least one letter or digit", http.StatusBadRequest)
+				return
+			}
+			slug = &s
+		}
+
+		result, err := db.Exec(`
+			UPDATE catalogue_lines
+			SET name = COALESCE($1, name),
+				slug = COALESCE($2, slug),
+				start_year = COALESCE($3, start_year),
+				end_year = COALESCE($4, end_year),
+				description = COALESCE($5, description),
+				updated_at = NOW()
+			WHERE id = $6
+		`, req.Name, slug, req.StartYear, req.EndYear, req.Description, id)
+		if err != nil {
+			catalogueWriteError(w, err, "Update product line")
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Product line not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Product line updated successfully"})
+	}
+}
+
+// DeleteProductLineHandler removes a product line that has no items
+func DeleteProductLineHandler(db *sql.DB) http.HandlerFunc {
+	return deleteCatalogueEntry(db, "catalogue_lines", "Product line")
+}
+
+// CreateCatalogueItemHandler adds a catalogue item
+func CreateCatalogueItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.CreateItemRequest
+		if !decodeCatalogueRequest(w, r, &req) || !normaliseItemBarcode(w, req.Barcode) {
+			return
+		}
+
+		images := req.Images
+		if images == nil {
+			images = []string{}
+		}
+
+		var id string
+		err := db.QueryRow(`
+			INSERT INTO catalogue_items (line_id, manufacturer_id, name, year, variant, sku, barcode, images, description)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, NULLIF($9, ''))
+			RETURNING id
+		`, req.LineID, req.ManufacturerID, req.Name, req.Year, req.Variant, req.SKU, req.Barcode, pq.Array(images),
+			req.Description).Scan(&id)
+		if err != nil {
+			catalogueWriteError(w, err, "Create catalogue item")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	}
+}
+
+// UpdateCatalogueItemHandler edits a catalogue item. Sending images replaces the whole set;
+// sending an empty sku or barcode clears it.
+func UpdateCatalogueItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid item ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.UpdateItemRequest
+		if !decodeCatalogueRequest(w, r, &req) || !normaliseItemBarcode(w, req.Barcode) {
+			return
+		}
+
+		var images interface{}
+		if req.Images != nil {
+			images = pq.Array(req.Images)
+		}
+
+		result, err := db.Exec(`
+			UPDATE catalogue_items
+			SET line_id = COALESCE($1, line_id),
+				manufacturer_id = COALESCE($2, manufacturer_id),
+				name = COALESCE($3, name),
+				year = COALESCE($4, year),
+				variant = COALESCE($5, variant),
+				sku = CASE WHEN $6::text IS NULL THEN sku ELSE NULLIF($6, '') END,
+				barcode = CASE WHEN $7::text IS NULL THEN barcode ELSE NULLIF($7, '') END,
+				images = COALESCE($8, images),
+				description = COALESCE($9, description),
+				updated_at = NOW()
+			WHERE id = $10
+		`, req.LineID, req.ManufacturerID, req.Name, req.Year, req.Variant, req.SKU, req.Barcode, images, req.Description, id)
+		if err != nil {
+			catalogueWriteError(w, err, "Update catalogue item")
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Item not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Item updated successfully"})
+	}
+}
+
+// DeleteCatalogueItemHandler removes a catalogue item
+func DeleteCatalogueItemHandler(db *sql.DB) http.HandlerFunc {
+	return deleteCatalogueEntry(db, "catalogue_items", "Item")
+}
+
+// deleteCatalogueEntry removes the row of table named by the {id} URL parameter
+func deleteCatalogueEntry(db *sql.DB, table, label string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid "+strings.ToLower(label)+" ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM "+table+" WHERE id = $1", id)
+		if err != nil {
+			catalogueWriteError(w, err, "Delete "+strings.ToLower(label))
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, label+" not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": label + " deleted successfully"})
+	}
+}
+
+// ImportCatalogueHandler bulk loads catalogue items from a JSON array or, when the
+// Content-Type is text/csv, a CSV file with a header row
+func ImportCatalogueHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxCatalogueImportBytes)
+
+		var records []models.CatalogueImportRecord
+		var err error
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+			records, err = catalogue.ParseCSV(r.Body)
+		} else {
+			records, err = catalogue.ParseJSON(r.Body)
+		}
+		if err != nil {
+			http.Error(w, "Invalid import file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := catalogue.Import(db, records)
+		if errors.Is(err, catalogue.ErrInvalidImport) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			catalogueWriteError(w, err, "Catalogue import")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
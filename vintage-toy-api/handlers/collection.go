@@ -0,0 +1,686 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/activity"
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Entries linked to the catalogue show its current details; the copy kept on the entry
+// is used for free-form entries and once the catalogue item has been removed
+const (
+	collectionName      = "COALESCE(ci.name, co.name)"
+	collectionFranchise = "COALESCE(cf.name, co.franchise)"
+	collectionLine      = "COALESCE(cl.name, co.line)"
+	collectionYear      = "COALESCE(ci.year, co.year)"
+)
+
+// collectionColumns returns the columns read by scanCollectionItem
+var collectionColumns = fmt.Sprintf(`
+	co.id, co.catalogue_item_id, %s, %s, %s, %s, co.condition_grade, co.packaging, co.quantity,
+	co.purchase_price_pence, to_char(co.purchase_date, 'YYYY-MM-DD'), co.notes, co.photos, co.tradeable,
+	co.asking_price_pence, co.created_at, co.updated_at`,
+	collectionName, collectionFranchise, collectionLine, collectionYear)
+
+// publicCollectionColumns matches collectionColumns but leaves out the purchase price, purchase date
+// and notes, which only the owner sees
+var publicCollectionColumns = fmt.Sprintf(`
+	co.id, co.catalogue_item_id, %s, %s, %s, %s, co.condition_grade, co.packaging, co.quantity,
+	NULL::int, NULL::text, NULL::text, co.photos, co.tradeable,
+	co.asking_price_pence, co.created_at, co.updated_at`,
+	collectionName, collectionFranchise, collectionLine, collectionYear)
+
+// collectionFrom joins the tables read by collectionColumns
+const collectionFrom = `
+	FROM collection_items co
+	LEFT JOIN catalogue_items ci ON co.catalogue_item_id = ci.id
+	LEFT JOIN catalogue_lines cl ON ci.line_id = cl.id
+	LEFT JOIN catalogue_franchises cf ON cl.franchise_id = cf.id`
+
+// collectionSorts are the orderings accepted by the collection list endpoints
+var collectionSorts = map[string]string{
+	"created_at":     "co.created_at",
+	"name":           collectionName,
+	"year":           "COALESCE(" + collectionYear + ", 0)",
+	"condition":      "COALESCE(co.condition_grade, 0)",
+	"purchase_price": "COALESCE(co.purchase_price_pence, 0)",
+	"purchase_date":  "COALESCE(co.purchase_date, DATE '0001-01-01')",
+}
+
+// publicCollectionSorts are the orderings accepted when listing someone else's collection
+var publicCollectionSorts = map[string]string{
+	"created_at": collectionSorts["created_at"],
+	"name":       collectionSorts["name"],
+	"year":       collectionSorts["year"],
+	"condition":  collectionSorts["condition"],
+}
+
+// collectionGroups maps ?group_by= values to the label each entry is counted under
+var collectionGroups = map[string]string{
+	"franchise": "COALESCE(" + collectionFranchise + ", 'Unknown')",
+	"line":      "COALESCE(" + collectionLine + ", 'Unknown')",
+	"decade":    "COALESCE((" + collectionYear + " / 10 * 10)::text || 's', 'Unknown')",
+}
+
+// scanCollectionItem reads a row selected with collectionColumns, followed by any extra columns
+func scanCollectionItem(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.CollectionItem, error) {
+	var item models.CollectionItem
+	var year, grade, price, askingPrice sql.NullInt64
+
+	dest := []interface{}{
+		&item.ID, &item.CatalogueItemID, &item.Name, &item.Franchise, &item.Line, &year, &grade, &item.Packaging,
+		&item.Quantity, &price, &item.PurchaseDate, &item.Notes, pq.Array(&item.Photos), &item.Tradeable, &askingPrice,
+		&item.CreatedAt, &item.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return item, err
+	}
+
+	if year.Valid {
+		y := int(year.Int64)
+		item.Year = &y
+	}
+	if grade.Valid {
+		g := int(grade.Int64)
+		item.ConditionGrade = &g
+	}
+	if price.Valid {
+		p := int(price.Int64)
+		item.PurchasePricePence = &p
+	}
+	if askingPrice.Valid {
+		p := int(askingPrice.Int64)
+		item.AskingPricePence = &p
+	}
+	if item.Photos == nil {
+		item.Photos = []string{}
+	}
+	return item, nil
+}
+
+// collectionFilters builds the conditions for the collection of ownerID from the query string.
+// Supports ?q=, ?franchise=, ?line=, ?franchise_id=, ?line_id=, ?decade=, ?packaging=, ?min_condition= and ?tradeable=true.
+// ?q= searches the owner's notes too unless public is set
+func collectionFilters(r *http.Request, ownerID uuid.UUID, public bool) ([]interface{}, []string, error) {
+	query := r.URL.Query()
+	args := []interface{}{ownerID}
+	conditions := []string{"co.user_id = $1"}
+
+	if q := strings.TrimSpace(query.Get("q")); q != "" {
+		args = append(args, q)
+		if public {
+			conditions = append(conditions, fmt.Sprintf("%s ILIKE '%%' || $%d || '%%'", collectionName, len(args)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("(%s ILIKE '%%' || $%[2]d || '%%' OR co.notes ILIKE '%%' || $%[2]d || '%%')",
+				collectionName, len(args)))
+		}
+	}
+	for _, filter := range [][2]string{{"franchise", collectionFranchise}, {"line", collectionLine}} {
+		if value := strings.TrimSpace(query.Get(filter[0])); value != "" {
+			args = append(args, value)
+			conditions = append(conditions, fmt.Sprintf("LOWER(%s) = LOWER($%d)", filter[1], len(args)))
+		}
+	}
+	for _, filter := range [][2]string{{"franchise_id", "cf.id"}, {"line_id", "cl.id"}} {
+		if err := uuidFilter(r, filter[0], filter[1], &args, &conditions); err != nil {
+			return nil, nil, err
+		}
+	}
+	if decade := query.Get("decade"); decade != "" {
+		v, err := strconv.Atoi(strings.TrimSuffix(decade, "s"))
+		if err != nil || v%10 != 0 {
+			return nil, nil, fmt.Errorf("Invalid decade, expected a year such as 1980")
+		}
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("%s BETWEEN $%[2]d AND $%[2]d + 9", collectionYear, len(args)))
+	}
+	if packaging := query.Get("packaging"); packaging != "" {
+		if !models.Packagings[packaging] {
+			return nil, nil, fmt.Errorf("Invalid packaging, expected loose, MOC or MIB")
+		}
+		args = append(args, packaging)
+		conditions = append(conditions, fmt.Sprintf("co.packaging = $%d", len(args)))
+	}
+	if minCondition := query.Get("min_condition"); minCondition != "" {
+		v, err := strconv.Atoi(minCondition)
+		if err != nil || v < 1 || v > 10 {
+			return nil, nil, fmt.Errorf("Invalid min_condition, expected 1 to 10")
+		}
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("co.condition_grade >= $%d", len(args)))
+	}
+	if query.Get("tradeable") == "true" {
+		conditions = append(conditions, "co.tradeable")
+	}
+	return args, conditions, nil
+}
+
+// queryCollection returns a page of the collection of ownerID, filtered and sorted from the query string.
+// When public is set the purchase details are left out and can't be sorted on
+func queryCollection(db *sql.DB, r *http.Request, ownerID uuid.UUID, public bool) (pagination.Page[models.CollectionItem], int, error) {
+	var result pagination.Page[models.CollectionItem]
+
+	columns, sorts := collectionColumns, collectionSorts
+	if public {
+		columns, sorts = publicCollectionColumns, publicCollectionSorts
+	}
+	page, err := pagination.FromRequest(r, pagination.Options{Sorts: sorts, DefaultSort: "-created_at"})
+	if err != nil {
+		return result, http.StatusBadRequest, err
+	}
+	args, conditions, err := collectionFilters(r, ownerID, public)
+	if err != nil {
+		return result, http.StatusBadRequest, err
+	}
+	if clause, cursorArgs := page.Where("co.id", len(args)+1); clause != "" {
+		conditions = append(conditions, clause)
+		args = append(args, cursorArgs...)
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %s, %s
+		%s
+		WHERE %s
+		%s
+	`, columns, page.SortValue(), collectionFrom, strings.Join(conditions, " AND "), page.OrderBy("co.id")), args...)
+	if err != nil {
+		log.Println("Collection query error:", err)
+		return result, http.StatusInternalServerError, fmt.Errorf("Database error")
+	}
+	defer rows.Close()
+
+	var items []models.CollectionItem
+	var keys [][2]string
+	for rows.Next() {
+		var sortKey string
+		item, err := scanCollectionItem(rows, &sortKey)
+		if err != nil {
+			log.Println("Row scan error:", err)
+			return result, http.StatusInternalServerError, fmt.Errorf("Error scanning collection")
+		}
+		items = append(items, item)
+		keys = append(keys, [2]string{sortKey, item.ID})
+	}
+	return pagination.NewPage(items, keys, page), http.StatusOK, nil
+}
+
+// collectionStats totals the collection of ownerID, grouped by ?group_by=franchise|line|decade.
+// The filters of the list endpoints apply here too. Spending is only totalled when public is not set
+func collectionStats(db *sql.DB, r *http.Request, ownerID uuid.UUID, public bool) (models.CollectionStats, int, error) {
+	stats := models.CollectionStats{GroupBy: r.URL.Query().Get("group_by"), Groups: []models.CollectionStatsGroup{}}
+	if stats.GroupBy == "" {
+		stats.GroupBy = "franchise"
+	}
+	label, ok := collectionGroups[stats.GroupBy]
+	if !ok {
+		return stats, http.StatusBadRequest, fmt.Errorf("Invalid group_by, expected franchise, line or decade")
+	}
+	args, conditions, err := collectionFilters(r, ownerID, public)
+	if err != nil {
+		return stats, http.StatusBadRequest, err
+	}
+	if !public {
+		stats.SpentPence = new(int)
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %s AS label, COUNT(*), SUM(co.quantity), COALESCE(SUM(co.purchase_price_pence), 0)
+		%s
+		WHERE %s
+		GROUP BY 1
+		ORDER BY 3 DESC, 1
+	`, label, collectionFrom, strings.Join(conditions, " AND ")), args...)
+	if err != nil {
+		log.Println("Collection stats query error:", err)
+		return stats, http.StatusInternalServerError, fmt.Errorf("Database error")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var g models.CollectionStatsGroup
+		var spent int
+		if err := rows.Scan(&g.Label, &g.Entries, &g.Quantity, &spent); err != nil {
+			return stats, http.StatusInternalServerError, fmt.Errorf("Error scanning collection stats")
+		}
+		if !public {
+			g.SpentPence = &spent
+			*stats.SpentPence += spent
+		}
+		stats.Groups = append(stats.Groups, g)
+		stats.Entries += g.Entries
+		stats.Quantity += g.Quantity
+	}
+	return stats, http.StatusOK, rows.Err()
+}
+
+// publicCollectionOwner loads the profile summary of a user whose collection is public,
+// unless the viewer has blocked them
+func publicCollectionOwner(db *sql.DB, r *http.Request) (models.PublicUserSummary, uuid.UUID, int, error) {
+	var owner models.PublicUserSummary
+	ownerID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return owner, ownerID, http.StatusBadRequest, fmt.Errorf("Invalid user ID")
+	}
+
+	var storeName, bioDescription, profileImage sql.NullString
+	err = db.QueryRow(fmt.Sprintf(`
+		SELECT ub.display_name, ub.store_name, ub.bio_description, ub.profile_image, ub.collection_public
+		FROM users u
+		JOIN user_bios ub ON u.id = ub.user_id
+		WHERE u.id = $1 AND u.is_deleted = FALSE AND %s
+	`, notBlockedBy(2, "u.id")), ownerID, middleware.ViewerID(r)).Scan(&owner.DisplayName, &storeName, &bioDescription, &profileImage, &owner.CollectionPublic)
+	if err == sql.ErrNoRows || (err == nil && !owner.CollectionPublic) {
+		return owner, ownerID, http.StatusNotFound, fmt.Errorf("Collection not found")
+	} else if err != nil {
+		return owner, ownerID, http.StatusInternalServerError, fmt.Errorf("Database error")
+	}
+
+	owner.ID = ownerID.String()
+	if storeName.Valid {
+		owner.StoreName = &storeName.String
+	}
+	if bioDescription.Valid {
+		owner.BioDescription = &bioDescription.String
+	}
+	if profileImage.Valid {
+		owner.ProfileImage = &profileImage.String
+	}
+	return owner, ownerID, http.StatusOK, nil
+}
+
+// heldItem is the owner and quantity of a collection entry locked for a transfer
+type heldItem struct {
+	owner    uuid.UUID
+	quantity int
+}
+
+// lockCollectionItems locks the given collection entries until the end of the transaction.
+// They're locked in a fixed order so concurrent transfers can't deadlock. Entries that no
+// longer exist are missing from the result.
+func lockCollectionItems(tx *sql.Tx, ids []string) (map[string]heldItem, error) {
+	rows, err := tx.Query(`
+		SELECT id, user_id, quantity
+		FROM collection_items
+		WHERE id = ANY($1::uuid[])
+		ORDER BY id
+		FOR UPDATE
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	held := map[string]heldItem{}
+	for rows.Next() {
+		var id string
+		var h heldItem
+		if err := rows.Scan(&id, &h.owner, &h.quantity); err != nil {
+			return nil, err
+		}
+		held[id] = h
+	}
+	return held, rows.Err()
+}
+
+// giveCollectionItem moves quantity of a locked collection entry to receiver. An entry
+// given up in full changes hands; part of an entry is split off into a new one. Either
+// way the receiver starts with their own purchase details and the item is no longer
+// offered for trade.
+func giveCollectionItem(tx *sql.Tx, itemID string, held heldItem, receiver uuid.UUID, quantity int) error {
+	if quantity == held.quantity {
+		_, err := tx.Exec(`
+			UPDATE collection_items
+			SET user_id = $1, tradeable = FALSE, asking_price_pence = NULL, purchase_price_pence = NULL,
+				purchase_date = CURRENT_DATE, notes = NULL, updated_at = NOW()
+			WHERE id = $2
+		`, receiver, itemID)
+		return err
+	}
+
+	_, err := tx.Exec(`
+		WITH source AS (
+			UPDATE collection_items SET quantity = quantity - $3, updated_at = NOW()
+			WHERE id = $2
+			RETURNING catalogue_item_id, name, franchise, line, year, condition_grade, packaging, photos
+		)
+		INSERT INTO collection_items (user_id, catalogue_item_id, name, franchise, line, year, condition_grade,
+			packaging, quantity, purchase_date, photos)
+		SELECT $1, catalogue_item_id, name, franchise, line, year, condition_grade, packaging, $3, CURRENT_DATE, photos
+		FROM source
+	`, receiver, itemID, quantity)
+	return err
+}
+
+// GetMyCollectionHandler lists the authenticated user's collection.
+// Supports the filters of collectionFilters, ?sort=created_at|name|year|condition|purchase_price|purchase_date,
+// ?limit= and ?cursor=
+func GetMyCollectionHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		result, status, err := queryCollection(db, r, userID, false)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// GetMyCollectionStatsHandler summarises the authenticated user's collection
+func GetMyCollectionStatsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		stats, status, err := collectionStats(db, r, userID, false)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	}
+}
+
+// GetUserCollectionHandler lists another user's collection alongside their profile summary,
+// if they have made it public. Supports the same parameters as GetMyCollectionHandler, except the
+// purchase_price and purchase_date sorts; purchase details and notes are left out
+func GetUserCollectionHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, ownerID, status, err := publicCollectionOwner(db, r)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		result, status, err := queryCollection(db, r, ownerID, true)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.PublicCollection{User: owner, Data: result.Data, NextCursor: result.NextCursor})
+	}
+}
+
+// GetUserCollectionStatsHandler summarises another user's public collection
+func GetUserCollectionStatsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, ownerID, status, err := publicCollectionOwner(db, r)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		stats, status, err := collectionStats(db, r, ownerID, true)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	}
+}
+
+// addCollectionItem adds a catalogue item or a free-form entry to the collection of userID,
+// returning its ID. Offering it for trade alerts anyone whose wishlist it matches.
+func addCollectionItem(db *sql.DB, userID uuid.UUID, req models.CreateCollectionItemRequest) (string, int, error) {
+	name, franchise, line, year := req.Name, req.Franchise, req.Line, req.Year
+	if req.CatalogueItemID != nil {
+		// Keep a copy of the catalogue details in case the item is later removed
+		var catalogueName, franchiseName, lineName string
+		var catalogueYear sql.NullInt64
+		err := db.QueryRow(`
+			SELECT ci.name, cf.name, cl.name, ci.year
+			FROM catalogue_items ci
+			JOIN catalogue_lines cl ON ci.line_id = cl.id
+			JOIN catalogue_franchises cf ON cl.franchise_id = cf.id
+			WHERE ci.id = $1
+		`, *req.CatalogueItemID).Scan(&catalogueName, &franchiseName, &lineName, &catalogueYear)
+		if err == sql.ErrNoRows {
+			return "", http.StatusNotFound, fmt.Errorf("Catalogue item not found")
+		} else if err != nil {
+			return "", http.StatusInternalServerError, fmt.Errorf("Database error")
+		}
+		name, franchise, line = &catalogueName, &franchiseName, &lineName
+		if catalogueYear.Valid {
+			y := int(catalogueYear.Int64)
+			year = &y
+		}
+	}
+
+	quantity := 1
+	if req.Quantity != nil {
+		quantity = *req.Quantity
+	}
+	photos := req.Photos
+	if photos == nil {
+		photos = []string{}
+	}
+
+	tradeable := req.Tradeable != nil && *req.Tradeable
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("Database error")
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.QueryRow(`
+		INSERT INTO collection_items (user_id, catalogue_item_id, name, franchise, line, year, condition_grade,
+			packaging, quantity, purchase_price_pence, purchase_date, notes, photos, tradeable, asking_price_pence)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING id
+	`, userID, req.CatalogueItemID, *name, franchise, line, year, req.ConditionGrade, req.Packaging, quantity,
+		req.PurchasePricePence, req.PurchaseDate, req.Notes, pq.Array(photos), tradeable, req.AskingPricePence).Scan(&id)
+	if err != nil {
+		log.Printf("Add collection item error: %v", err)
+		return "", http.StatusInternalServerError, fmt.Errorf("Error adding to collection")
+	}
+
+	if tradeable {
+		if err := notifyWishlistMatches(tx, userID); err != nil {
+			log.Printf("Wishlist match error: %v", err)
+			return "", http.StatusInternalServerError, fmt.Errorf("Error adding to collection")
+		}
+	}
+
+	err = activity.Record(tx, activity.Event{
+		ActorID:   userID,
+		Type:      activity.TypeCollectionAdded,
+		SubjectID: id,
+		Details:   map[string]interface{}{"name": *name},
+	})
+	if err != nil {
+		log.Printf("Record activity error: %v", err)
+		return "", http.StatusInternalServerError, fmt.Errorf("Error adding to collection")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("Database commit error")
+	}
+	return id, http.StatusCreated, nil
+}
+
+// AddCollectionItemHandler adds a catalogue item or a free-form entry to the authenticated user's collection
+func AddCollectionItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req models.CreateCollectionItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if (req.CatalogueItemID == nil) == (req.Name == nil) {
+			http.Error(w, "Provide either catalogue_item_id or name", http.StatusBadRequest)
+			return
+		}
+
+		id, status, err := addCollectionItem(db, userID, req)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	}
+}
+
+// UpdateCollectionItemHandler edits an entry in the authenticated user's collection.
+// Offering it for trade alerts anyone whose wishlist it matches.
+func UpdateCollectionItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		itemID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid collection item ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.UpdateCollectionItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var photos interface{}
+		if req.Photos != nil {
+			photos = pq.Array(req.Photos)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var tradeable bool
+		err = tx.QueryRow(`
+			UPDATE collection_items
+			SET name = COALESCE($1, name),
+				franchise = COALESCE($2, franchise),
+				line = COALESCE($3, line),
+				year = COALESCE($4, year),
+				condition_grade = COALESCE($5, condition_grade),
+				packaging = COALESCE($6, packaging),
+				quantity = COALESCE($7, quantity),
+				purchase_price_pence = COALESCE($8, purchase_price_pence),
+				purchase_date = COALESCE($9, purchase_date),
+				notes = COALESCE($10, notes),
+				photos = COALESCE($11, photos),
+				tradeable = COALESCE($12, tradeable),
+				asking_price_pence = COALESCE($13, asking_price_pence),
+				updated_at = NOW()
+			WHERE id = $14 AND user_id = $15
+			RETURNING tradeable
+		`, req.Name, req.Franchise, req.Line, req.Year, req.ConditionGrade, req.Packaging, req.Quantity,
+			req.PurchasePricePence, req.PurchaseDate, req.Notes, photos, req.Tradeable, req.AskingPricePence,
+			itemID, userID).Scan(&tradeable)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Collection item not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Update collection item error: %v", err)
+			http.Error(w, "Failed to update collection item", http.StatusInternalServerError)
+			return
+		}
+
+		// A lower price or a better grade can satisfy wishlists the entry didn't before
+		if tradeable {
+			if err := notifyWishlistMatches(tx, userID); err != nil {
+				log.Printf("Wishlist match error: %v", err)
+				http.Error(w, "Failed to update collection item", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Collection item updated successfully"})
+	}
+}
+
+// DeleteCollectionItemHandler removes an entry from the authenticated user's collection
+func DeleteCollectionItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		itemID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid collection item ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM collection_items WHERE id = $1 AND user_id = $2", itemID, userID)
+		if err != nil {
+			http.Error(w, "Error deleting collection item", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Collection item not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Collection item deleted successfully"})
+	}
+}
@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/calendar"
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultEventTimezone = "Europe/London"
+	defaultEventWindow   = 90 * 24 * time.Hour
+	maxEventWindow       = 366 * 24 * time.Hour
+)
+
+// scheduleFromMarker rebuilds a calendar schedule from the marker's stored event fields
+func scheduleFromMarker(m *models.EventSchedule) (calendar.Schedule, error) {
+	return calendar.NewSchedule(m.StartsAt, m.EndsAt, m.Timezone, m.RRule, m.ExDates)
+}
+
+// SetEventScheduleHandler sets the dates and recurrence of one of the authenticated user's event markers
+func SetEventScheduleHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.SetEventScheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tz := defaultEventTimezone
+		if req.Timezone != nil {
+			tz = *req.Timezone
+		}
+
+		schedule, err := calendar.NewSchedule(req.StartsAt, req.EndsAt, tz, req.RRule, req.ExDates)
+		if err != nil {
+			http.Error(w, "Invalid schedule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !schedule.End.After(schedule.Start) {
+			http.Error(w, "Event must end after it starts", http.StatusBadRequest)
+			return
+		}
+
+		var markerType string
+		err = db.QueryRow("SELECT marker_type FROM user_markers WHERE id = $1 AND user_id = $2", markerID, userID).
+			Scan(&markerType)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Marker not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !models.EventMarkerTypes[markerType] {
+			http.Error(w, "Only Event and Trade Meetup markers can be scheduled", http.StatusBadRequest)
+			return
+		}
+
+		var seriesEndsAt *time.Time
+		if end, ok := schedule.LastEnd(); ok {
+			seriesEndsAt = &end
+		}
+
+		var rrule *string
+		if schedule.Rule != nil {
+			normalized := schedule.Rule.String()
+			rrule = &normalized
+		}
+
+		exdates := make([]string, 0, len(schedule.ExDates))
+		for _, ex := range schedule.ExDates {
+			exdates = append(exdates, ex.Format(calendar.LocalTimeLayout))
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec("SELECT 1 FROM user_markers WHERE id = $1 FOR UPDATE", markerID); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO marker_events (marker_id, starts_at, ends_at, timezone, rrule, exdates, series_ends_at, capacity, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6::timestamp[], $7, $8, NOW())
+			ON CONFLICT (marker_id) DO UPDATE
+			SET starts_at = EXCLUDED.starts_at,
+				ends_at = EXCLUDED.ends_at,
+				timezone = EXCLUDED.timezone,
+				rrule = EXCLUDED.rrule,
+				exdates = EXCLUDED.exdates,
+				series_ends_at = EXCLUDED.series_ends_at,
+				capacity = EXCLUDED.capacity,
+				updated_at = NOW()
+		`, markerID, schedule.Start.Format(calendar.LocalTimeLayout), schedule.End.Format(calendar.LocalTimeLayout),
+			tz, rrule, pq.Array(exdates), seriesEndsAt, req.Capacity)
+		if err != nil {
+			log.Printf("Set event schedule error: %v", err)
+			http.Error(w, "Failed to save schedule", http.StatusInternalServerError)
+			return
+		}
+
+		// A raised or removed capacity makes room for people on the waitlist
+		if err := promoteWaitlists(tx, markerID); err != nil {
+			log.Printf("Waitlist promotion error: %v", err)
+			http.Error(w, "Failed to save schedule", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Commit failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Schedule saved successfully"})
+	}
+}
+
+// DeleteEventScheduleHandler removes the schedule from one of the authenticated user's markers
+func DeleteEventScheduleHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		res, err := db.Exec(`
+			DELETE FROM marker_events me
+			USING user_markers um
+			WHERE me.marker_id = um.id AND um.id = $1 AND um.user_id = $2
+		`, markerID, userID)
+		if err != nil {
+			http.Error(w, "Error deleting schedule", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Schedule deleted successfully"})
+	}
+}
+
+// GetEventsHandler expands event occurrences between ?from= and ?to= (RFC 3339, default the next 90 days).
+// Supports ?region=, ?marker_type=, ?limit= and ?cursor=
+func GetEventsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		from := time.Now()
+		if v := q.Get("from"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid from, expected RFC 3339", http.StatusBadRequest)
+				return
+			}
+			from = t
+		}
+		to := from.Add(defaultEventWindow)
+		if v := q.Get("to"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid to, expected RFC 3339", http.StatusBadRequest)
+				return
+			}
+			to = t
+		}
+		if !to.After(from) || to.Sub(from) > maxEventWindow {
+			http.Error(w, "to must be after from and within a year of it", http.StatusBadRequest)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts:       map[string]string{"starts_at": "starts_at"},
+			DefaultSort: "starts_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if page.Desc {
+			http.Error(w, "Events can only be listed in ascending order", http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{middleware.ViewerID(r), from, to}
+		conditions := []string{
+			"u.is_deleted = FALSE",
+			markerVisibleTo(1),
+			"(me.series_ends_at IS NULL OR me.series_ends_at > $2)",
+			"(me.starts_at AT TIME ZONE me.timezone) < $3",
+		}
+
+		if region := q.Get("region"); region != "" {
+			if !models.Regions[region] {
+				http.Error(w, "Invalid region", http.StatusBadRequest)
+				return
+			}
+			args = append(args, region)
+			conditions = append(conditions, fmt.Sprintf("um.region = $%d", len(args)))
+		}
+		if markerType := q.Get("marker_type"); markerType != "" {
+			if !models.EventMarkerTypes[markerType] {
+				http.Error(w, "Invalid marker type", http.StatusBadRequest)
+				return
+			}
+			args = append(args, markerType)
+			conditions = append(conditions, fmt.Sprintf("um.marker_type = $%d", len(args)))
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s
+			%s
+			WHERE me.marker_id IS NOT NULL AND %s
+		`, markerColumns(1), markerFrom, strings.Join(conditions, " AND ")), args...)
+		if err != nil {
+			log.Println("Events query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var occurrences []models.EventOccurrence
+		for rows.Next() {
+			marker, err := scanMarker(rows)
+			if err != nil {
+				log.Println("Events scan error:", err)
+				http.Error(w, "Database scan error", http.StatusInternalServerError)
+				return
+			}
+
+			schedule, err := scheduleFromMarker(marker.Schedule)
+			if err != nil {
+				log.Printf("Skipping marker %s with invalid schedule: %v", marker.ID, err)
+				continue
+			}
+
+			for _, o := range schedule.Between(from, to) {
+				occurrences = append(occurrences, models.EventOccurrence{StartsAt: o.Start, EndsAt: o.End, Marker: marker})
+			}
+		}
+
+		sort.Slice(occurrences, func(i, j int) bool {
+			if !occurrences[i].StartsAt.Equal(occurrences[j].StartsAt) {
+				return occurrences[i].StartsAt.Before(occurrences[j].StartsAt)
+			}
+			return occurrences[i].Marker.ID < occurrences[j].Marker.ID
+		})
+
+		// Occurrences are expanded in Go, so the keyset cursor is applied here rather than in SQL
+		if page.After != nil {
+			after, err := time.Parse(time.RFC3339Nano, page.After.Value)
+			if err != nil {
+				http.Error(w, pagination.ErrInvalidCursor.Error(), http.StatusBadRequest)
+				return
+			}
+			start := sort.Search(len(occurrences), func(i int) bool {
+				o := occurrences[i]
+				return o.StartsAt.After(after) || (o.StartsAt.Equal(after) && o.Marker.ID > page.After.ID)
+			})
+			occurrences = occurrences[start:]
+		}
+		if len(occurrences) > page.Limit+1 {
+			occurrences = occurrences[:page.Limit+1]
+		}
+
+		keys := make([][2]string, len(occurrences))
+		for i, o := range occurrences {
+			keys[i] = [2]string{o.StartsAt.UTC().Format(time.RFC3339Nano), o.Marker.ID}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(occurrences, keys, page))
+	}
+}
+
+// GetEventFeedHandler publishes public events as a subscribable iCalendar feed.
+// kind selects the URL parameter to filter on: "region", "type" or "user".
+func GetEventFeedHandler(db *sql.DB, kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := chi.URLParam(r, kind)
+
+		var condition, name string
+		switch kind {
+		case "region":
+			if !models.Regions[value] {
+				http.Error(w, "Invalid region", http.StatusNotFound)
+				return
+			}
+			condition, name = "um.region = $1", "Vintage toy events: "+value
+		case "type":
+			if !models.EventMarkerTypes[value] {
+				http.Error(w, "Invalid marker type", http.StatusNotFound)
+				return
+			}
+			condition, name = "um.marker_type = $1", "Vintage toy events: "+value
+		case "user":
+			if _, err := uuid.Parse(value); err != nil {
+				http.Error(w, "Invalid user ID", http.StatusNotFound)
+				return
+			}
+			condition, name = "um.user_id = $1", "Vintage toy events"
+		}
+
+		// Calendar clients subscribe without cookies, so feeds only ever carry public events
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, me.updated_at
+			%s
+			WHERE me.marker_id IS NOT NULL
+			  AND u.is_deleted = FALSE
+			  AND um.visibility = 'public'
+			  AND (me.series_ends_at IS NULL OR me.series_ends_at > NOW() - INTERVAL '30 days')
+			  AND %s
+			ORDER BY me.starts_at
+		`, markerColumns(0), markerFrom, condition), value)
+		if err != nil {
+			log.Println("Event feed query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var events []calendar.Event
+		for rows.Next() {
+			var updated time.Time
+			marker, err := scanMarker(rows, &updated)
+			if err != nil {
+				log.Println("Event feed scan error:", err)
+				http.Error(w, "Database scan error", http.StatusInternalServerError)
+				return
+			}
+
+			schedule, err := scheduleFromMarker(marker.Schedule)
+			if err != nil {
+				log.Printf("Skipping marker %s with invalid schedule: %v", marker.ID, err)
+				continue
+			}
+
+			event := calendar.Event{
+				UID:       marker.ID + "@toy.josephbartram.co.uk",
+				Summary:   marker.Name,
+				Location:  marker.Region,
+				Latitude:  marker.Latitude,
+				Longitude: marker.Longitude,
+				Schedule:  schedule,
+				Updated:   updated,
+			}
+			if marker.Description != nil {
+				event.Description = *marker.Description
+			}
+			if kind == "user" {
+				name = "Vintage toy events: " + marker.User.DisplayName
+			}
+			events = append(events, event)
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		if err := calendar.WriteFeed(w, name, events); err != nil {
+			log.Println("Event feed write error:", err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// FavouriteMarkerHandler adds a marker the caller can see to their favourites
+func FavouriteMarkerHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec(fmt.Sprintf(`
+			INSERT INTO marker_favourites (user_id, marker_id)
+			SELECT $2, um.id
+			FROM user_markers um
+			JOIN users u ON um.user_id = u.id
+			WHERE um.id = $1 AND u.is_deleted = FALSE AND %s
+			ON CONFLICT DO NOTHING
+		`, markerVisibleTo(2)), markerID, userID)
+		if err != nil {
+			log.Printf("Favourite marker error: %v", err)
+			http.Error(w, "Error saving favourite", http.StatusInternalServerError)
+			return
+		}
+
+		// Nothing inserted means either an unknown marker or one that is already a favourite
+		if n, _ := result.RowsAffected(); n == 0 {
+			var exists bool
+			err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM marker_favourites WHERE user_id = $1 AND marker_id = $2)",
+				userID, markerID).Scan(&exists)
+			if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if !exists {
+				http.Error(w, "Marker not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Marker added to favourites"})
+	}
+}
+
+// UnfavouriteMarkerHandler removes a marker from the caller's favourites
+func UnfavouriteMarkerHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM marker_favourites WHERE user_id = $1 AND marker_id = $2", userID, markerID)
+		if err != nil {
+			http.Error(w, "Error removing favourite", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Favourite not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Marker removed from favourites"})
+	}
+}
+
+// GetFavouritesHandler lists the markers the caller has favourited and can still see
+func GetFavouritesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts: map[string]string{
+				"favourited_at": "fav.created_at",
+				"name":          "um.name",
+			},
+			DefaultSort: "-favourited_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{userID}
+		conditions := []string{"fav.user_id = $1", "u.is_deleted = FALSE", markerVisibleTo(1)}
+		if clause, cursorArgs := page.Where("um.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, %s
+			%s
+			JOIN marker_favourites fav ON fav.marker_id = um.id
+			WHERE %s
+			%s
+		`, markerColumns(1), page.SortValue(), markerFrom, strings.Join(conditions, " AND "), page.OrderBy("um.id")), args...)
+		if err != nil {
+			log.Println("Database query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var markers []models.MarkerResponse
+		var keys [][2]string
+		for rows.Next() {
+			var sortKey string
+			marker, err := scanMarker(rows, &sortKey)
+			if err != nil {
+				log.Println("Row scan error:", err)
+				http.Error(w, "Database scan error", http.StatusInternalServerError)
+				return
+			}
+			markers = append(markers, marker)
+			keys = append(keys, [2]string{sortKey, marker.ID})
+		}
+
+		writeMarkers(w, r, pagination.NewPage(markers, keys, page))
+	}
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/notify"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// userSummaryColumns are the profile columns read by scanUserSummary, from users u and user_bios ub
+const userSummaryColumns = "u.id, ub.display_name, ub.store_name, ub.bio_description, ub.profile_image, ub.collection_public"
+
+// scanUserSummary reads the userSummaryColumns at the start of a row, followed by any extra columns
+func scanUserSummary(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.PublicUserSummary, error) {
+	var user models.PublicUserSummary
+	var storeName, bioDescription, profileImage sql.NullString
+
+	dest := []interface{}{&user.ID, &user.DisplayName, &storeName, &bioDescription, &profileImage, &user.CollectionPublic}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return user, err
+	}
+
+	if storeName.Valid {
+		user.StoreName = &storeName.String
+	}
+	if bioDescription.Valid {
+		user.BioDescription = &bioDescription.String
+	}
+	if profileImage.Valid {
+		user.ProfileImage = &profileImage.String
+	}
+	return user, nil
+}
+
+// FollowUserHandler makes the authenticated user follow another. Following someone
+// already followed is not an error.
+func FollowUserHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		followeeID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		if followeeID == userID {
+			http.Error(w, "You cannot follow yourself", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var exists bool
+		err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND is_deleted = FALSE)", followeeID).Scan(&exists)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		if blocked, err := blockedBetween(tx, userID, followeeID.String()); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		} else if blocked {
+			http.Error(w, errBlocked.Error(), http.StatusForbidden)
+			return
+		}
+
+		result, err := tx.Exec(`
+			INSERT INTO user_follows (follower_id, followee_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, userID, followeeID)
+		if err != nil {
+			log.Printf("Follow user error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		// Only a new follow is news to the followee, unless they have muted the follower
+		if n, _ := result.RowsAffected(); n > 0 {
+			var followerName string
+			var muted bool
+			err := tx.QueryRow(`
+				SELECT display_name,
+					EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = $2 AND muted_id = $1)
+				FROM user_bios WHERE user_id = $1
+			`, userID, followeeID).Scan(&followerName, &muted)
+			if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if !muted {
+				err := notify.Send(tx, notify.Notification{
+					UserID: followeeID,
+					Type:   notify.TypeNewFollower,
+					Title:  followerName + " started following you",
+					Link:   "/users/" + userID.String(),
+				})
+				if err != nil {
+					log.Printf("Follower notification error: %v", err)
+					http.Error(w, "Database error", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "User followed"})
+	}
+}
+
+// UnfollowUserHandler stops the authenticated user following another
+func UnfollowUserHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		followeeID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM user_follows WHERE follower_id = $1 AND followee_id = $2", userID, followeeID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "You are not following this user", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "User unfollowed"})
+	}
+}
+
+// followSorts are the orderings accepted by the follower and following lists
+var followSorts = map[string]string{
+	"followed_at": "f.created_at",
+	"name":        "ub.display_name",
+}
+
+// followListHandler lists the users on the other side of {id}'s follows. For "followers"
+// those are the users following {id}, for "following" the users {id} follows. Users the
+// viewer has blocked or muted are left out, and the lists of a blocked user are not found.
+// Supports ?sort=followed_at|name, ?limit= and ?cursor=
+func followListHandler(db *sql.DB, direction string) http.HandlerFunc {
+	self, other := "f.followee_id", "f.follower_id"
+	if direction == "following" {
+		self, other = other, self
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{Sorts: followSorts, DefaultSort: "-followed_at"})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		viewerID := middleware.ViewerID(r)
+		if visible, err := profileVisible(db, userID, viewerID); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		} else if !visible {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		args := []interface{}{userID, viewerID}
+		conditions := []string{self + " = $1", "u.is_deleted = FALSE", notHiddenFrom(2, "u.id")}
+		if clause, cursorArgs := page.Where("u.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, f.created_at, %s
+			FROM user_follows f
+			JOIN users u ON %s = u.id
+			JOIN user_bios ub ON u.id = ub.user_id
+			WHERE %s
+			%s
+		`, userSummaryColumns, page.SortValue(), other, strings.Join(conditions, " AND "), page.OrderBy("u.id")), args...)
+		if err != nil {
+			log.Println("Follow list query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var follows []models.Follow
+		var keys [][2]string
+		for rows.Next() {
+			var follow models.Follow
+			var sortKey string
+			follow.User, err = scanUserSummary(rows, &follow.FollowedAt, &sortKey)
+			if err != nil {
+				http.Error(w, "Error scanning users", http.StatusInternalServerError)
+				return
+			}
+			follows = append(follows, follow)
+			keys = append(keys, [2]string{sortKey, follow.User.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(follows, keys, page))
+	}
+}
+
+// GetFollowersHandler lists the users following a user
+func GetFollowersHandler(db *sql.DB) http.HandlerFunc {
+	return followListHandler(db, "followers")
+}
+
+// GetFollowingHandler lists the users a user follows
+func GetFollowingHandler(db *sql.DB) http.HandlerFunc {
+	return followListHandler(db, "following")
+}
+
+// activityVisibleTo returns a condition limiting activity events to those whose subject
+// still exists and the viewer bound to $viewerArg may see. Markers and reviews follow the
+// marker's visibility, collection entries the owner's collection setting.
+func activityVisibleTo(viewerArg int) string {
+	return fmt.Sprintf(`CASE e.type
+		WHEN 'listing_published' THEN EXISTS (
+			SELECT 1 FROM listings l WHERE l.id = e.subject_id AND l.status <> 'draft')
+		WHEN 'collection_added' THEN ub.collection_public AND EXISTS (
+			SELECT 1 FROM collection_items co WHERE co.id = e.subject_id AND co.user_id = e.actor_id)
+		WHEN 'review_posted' THEN EXISTS (
+			SELECT 1 FROM marker_reviews mr
+			JOIN user_markers um ON mr.marker_id = um.id
+			WHERE mr.id = e.subject_id AND %[1]s)
+		ELSE EXISTS (
+			SELECT 1 FROM user_markers um WHERE um.id = e.subject_id AND %[1]s)
+	END`, markerVisibleTo(viewerArg))
+}
+
+// GetFeedHandler returns a timeline of what the users the authenticated user follows have
+// done: new markers and events, listings going live, collection additions and reviews.
+// Supports ?type=, ?limit= and ?cursor=, newest first
+func GetFeedHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts:       map[string]string{"created_at": "e.created_at"},
+			DefaultSort: "-created_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{userID}
+		conditions := []string{"u.is_deleted = FALSE", activityVisibleTo(1), notHiddenFrom(1, "e.actor_id")}
+		if t := r.URL.Query().Get("type"); t != "" {
+			args = append(args, t)
+			conditions = append(conditions, fmt.Sprintf("e.type = $%d", len(args)))
+		}
+		if clause, cursorArgs := page.Where("e.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, e.id, e.type, e.subject_id, e.details, e.created_at, %s
+			FROM activity_events e
+			JOIN user_follows f ON f.followee_id = e.actor_id AND f.follower_id = $1
+			JOIN users u ON e.actor_id = u.id
+			JOIN user_bios ub ON u.id = ub.user_id
+			WHERE %s
+			%s
+		`, userSummaryColumns, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("e.id")), args...)
+		if err != nil {
+			log.Println("Feed query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var entries []models.ActivityEntry
+		var keys [][2]string
+		for rows.Next() {
+			var entry models.ActivityEntry
+			var details []byte
+			var sortKey string
+			entry.Actor, err = scanUserSummary(rows, &entry.ID, &entry.Type, &entry.SubjectID, &details, &entry.CreatedAt, &sortKey)
+			if err != nil {
+				log.Println("Feed scan error:", err)
+				http.Error(w, "Error scanning feed", http.StatusInternalServerError)
+				return
+			}
+			entry.Details = details
+			entries = append(entries, entry)
+			keys = append(keys, [2]string{sortKey, entry.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(entries, keys, page))
+	}
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// openingTimeLayout is the HH:MM format opening times are sent and returned in
+const openingTimeLayout = "15:04"
+
+// parseOpeningPeriod checks that opens and closes are HH:MM times with closes after opens
+func parseOpeningPeriod(opens, closes string) error {
+	o, err := time.Parse(openingTimeLayout, opens)
+	if err != nil {
+		return fmt.Errorf("invalid opening time %q, expected HH:MM", opens)
+	}
+	c, err := time.Parse(openingTimeLayout, closes)
+	if err != nil {
+		return fmt.Errorf("invalid closing time %q, expected HH:MM", closes)
+	}
+	if !c.After(o) {
+		return fmt.Errorf("closing time %s must be after opening time %s", closes, opens)
+	}
+	return nil
+}
+
+// validateOpeningHours checks every weekly period and exception in the request, and that
+// no two periods on the same weekday overlap
+func validateOpeningHours(req models.SetOpeningHoursRequest) error {
+	for _, p := range req.Weekly {
+		if err := parseOpeningPeriod(p.Opens, p.Closes); err != nil {
+			return err
+		}
+	}
+
+	// HH:MM strings sort in time order
+	periods := append([]models.OpeningPeriod(nil), req.Weekly...)
+	sort.Slice(periods, func(i, j int) bool {
+		if periods[i].Weekday != periods[j].Weekday {
+			return periods[i].Weekday < periods[j].Weekday
+		}
+		return periods[i].Opens < periods[j].Opens
+	})
+	for i := 1; i < len(periods); i++ {
+		prev, p := periods[i-1], periods[i]
+		if p.Weekday == prev.Weekday && p.Opens < prev.Closes {
+			return fmt.Errorf("periods %s-%s and %s-%s on weekday %d overlap", prev.Opens, prev.Closes, p.Opens, p.Closes, p.Weekday)
+		}
+	}
+
+	dates := make(map[string]bool, len(req.Exceptions))
+	for _, e := range req.Exceptions {
+		if _, err := time.Parse("2006-01-02", e.Date); err != nil {
+			return fmt.Errorf("invalid exception date %q, expected YYYY-MM-DD", e.Date)
+		}
+		if dates[e.Date] {
+			return fmt.Errorf("more than one exception on %s", e.Date)
+		}
+		dates[e.Date] = true
+		if e.Closed {
+			continue
+		}
+		if e.Opens == nil || e.Closes == nil {
+			return fmt.Errorf("exception on %s needs opens and closes, or closed", e.Date)
+		}
+		if err := parseOpeningPeriod(*e.Opens, *e.Closes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetOpeningHoursHandler replaces the opening hours of one of the authenticated user's shops.
+// Exceptions dated before today are kept for the record unless the request restates them.
+func SetOpeningHoursHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.SetOpeningHoursRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := validateOpeningHours(req); err != nil {
+			http.Error(w, "Invalid opening hours: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var markerType string
+		err = tx.QueryRow("SELECT marker_type FROM user_markers WHERE id = $1 AND user_id = $2 FOR UPDATE", markerID, userID).
+			Scan(&markerType)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Marker not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if markerType != "Shop" {
+			http.Error(w, "Only Shop markers have opening hours", http.StatusBadRequest)
+			return
+		}
+
+		if req.ClosedOnBankHolidays != nil {
+			if _, err := tx.Exec("UPDATE user_markers SET closed_on_bank_holidays = $1, updated_at = NOW() WHERE id = $2",
+				*req.ClosedOnBankHolidays, markerID); err != nil {
+				http.Error(w, "Failed to save opening hours", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if _, err := tx.Exec("DELETE FROM shop_opening_hours WHERE marker_id = $1", markerID); err != nil {
+			http.Error(w, "Failed to save opening hours", http.StatusInternalServerError)
+			return
+		}
+		_, err = tx.Exec(`
+			DELETE FROM shop_opening_exceptions
+			WHERE marker_id = $1 AND date >= (NOW() AT TIME ZONE 'Europe/London')::date
+		`, markerID)
+		if err != nil {
+			http.Error(w, "Failed to save opening hours", http.StatusInternalServerError)
+			return
+		}
+
+		for _, p := range req.Weekly {
+			_, err := tx.Exec("INSERT INTO shop_opening_hours (marker_id, weekday, opens_at, closes_at) VALUES ($1, $2, $3, $4)",
+				markerID, p.Weekday, p.Opens, p.Closes)
+			if err != nil {
+				log.Printf("Insert opening hours error: %v", err)
+				http.Error(w, "Failed to save opening hours", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		for _, e := range req.Exceptions {
+			var opens, closes *string
+			if !e.Closed {
+				opens, closes = e.Opens, e.Closes
+			}
+			_, err := tx.Exec(`
+				INSERT INTO shop_opening_exceptions (marker_id, date, opens_at, closes_at, note)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (marker_id, date) DO UPDATE
+				SET opens_at = EXCLUDED.opens_at, closes_at = EXCLUDED.closes_at, note = EXCLUDED.note
+			`, markerID, e.Date, opens, closes, e.Note)
+			if err != nil {
+				log.Printf("Insert opening exception error: %v", err)
+				http.Error(w, "Failed to save opening hours", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Opening hours updated"})
+	}
+}
+
+// GetOpeningHoursHandler returns a shop's weekly hours and upcoming exceptions
+func GetOpeningHoursHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		var markerType string
+		var hours models.OpeningHours
+		err = db.QueryRow(fmt.Sprintf(`
+			SELECT um.marker_type, um.closed_on_bank_holidays
+			FROM user_markers um
+			JOIN users u ON um.user_id = u.id
+			WHERE um.id = $1 AND u.is_deleted = FALSE AND %s
+		`, markerVisibleTo(2)), markerID, middleware.ViewerID(r)).Scan(&markerType, &hours.ClosedOnBankHolidays)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Marker not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if markerType != "Shop" {
+			http.Error(w, "Only Shop markers have opening hours", http.StatusBadRequest)
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT weekday, to_char(opens_at, 'HH24:MI'), to_char(closes_at, 'HH24:MI')
+			FROM shop_opening_hours
+			WHERE marker_id = $1
+			ORDER BY weekday, opens_at
+		`, markerID)
+		if err != nil {
+			log.Println("Error fetching opening hours:", err)
+			http.Error(w, "Failed to fetch opening hours", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		hours.Weekly = []models.OpeningPeriod{}
+		for rows.Next() {
+			var p models.OpeningPeriod
+			if err := rows.Scan(&p.Weekday, &p.Opens, &p.Closes); err != nil {
+				http.Error(w, "Failed to scan opening hours", http.StatusInternalServerError)
+				return
+			}
+			hours.Weekly = append(hours.Weekly, p)
+		}
+
+		// Past exceptions are kept for the record but aren't useful to visitors
+		exRows, err := db.Query(`
+			SELECT to_char(date, 'YYYY-MM-DD'), to_char(opens_at, 'HH24:MI'), to_char(closes_at, 'HH24:MI'), note
+			FROM shop_opening_exceptions
+			WHERE marker_id = $1 AND date >= (NOW() AT TIME ZONE 'Europe/London')::date
+			ORDER BY date
+		`, markerID)
+		if err != nil {
+			log.Println("Error fetching opening exceptions:", err)
+			http.Error(w, "Failed to fetch opening hours", http.StatusInternalServerError)
+			return
+		}
+		defer exRows.Close()
+
+		hours.Exceptions = []models.OpeningException{}
+		for exRows.Next() {
+			var e models.OpeningException
+			var opens, closes, note sql.NullString
+			if err := exRows.Scan(&e.Date, &opens, &closes, &note); err != nil {
+				http.Error(w, "Failed to scan opening hours", http.StatusInternalServerError)
+				return
+			}
+			if opens.Valid {
+				e.Opens, e.Closes = &opens.String, &closes.String
+			} else {
+				e.Closed = true
+			}
+			if note.Valid {
+				e.Note = &note.String
+			}
+			hours.Exceptions = append(hours.Exceptions, e)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hours)
+	}
+}
@@ -0,0 +1,636 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/activity"
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+	"github.com/Joseph_Bartram8/vintage-toy-api/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ListingLifetime is how long a listing stays active once it goes live
+var ListingLifetime = 30 * 24 * time.Hour
+
+// listingTransitions maps each listing status to those the seller may move it to. Listings
+// only become expired through the expiry job, and a sold listing stays sold.
+var listingTransitions = map[string]map[string]bool{
+	"draft":    {"active": true},
+	"active":   {"draft": true, "reserved": true, "sold": true},
+	"reserved": {"active": true, "sold": true},
+	"expired":  {"active": true, "draft": true},
+	"sold":     {},
+}
+
+// listingFranchise is the catalogue franchise of linked listings and the seller's own otherwise
+const listingFranchise = "COALESCE(cf.name, l.franchise)"
+
+// listingColumns returns the columns read by scanListing
+var listingColumns = fmt.Sprintf(`
+	l.id, l.seller_id, ub.display_name, ub.store_name, ub.profile_image, ub.collection_public, l.catalogue_item_id,
+	l.title, l.description, %s, l.price_pence, l.condition_grade, l.packaging, l.photos, l.quantity, l.status,
+	um.id, um.name, um.region, um.public_latitude, um.public_longitude, um.accuracy_m,
+	l.published_at, l.expires_at, l.created_at, l.updated_at`, listingFranchise)
+
+// listingFrom joins the tables read by listingColumns. The pickup marker is only joined
+// when the viewer bound to $viewerArg may see it.
+func listingFrom(viewerArg int) string {
+	return fmt.Sprintf(`
+	FROM listings l
+	JOIN users u ON l.seller_id = u.id
+	JOIN user_bios ub ON l.seller_id = ub.user_id
+	LEFT JOIN catalogue_items ci ON l.catalogue_item_id = ci.id
+	LEFT JOIN catalogue_lines cl ON ci.line_id = cl.id
+	LEFT JOIN catalogue_franchises cf ON cl.franchise_id = cf.id
+	LEFT JOIN user_markers um ON l.marker_id = um.id AND %s`, markerVisibleTo(viewerArg))
+}
+
+// listingSorts are the orderings accepted by every listing endpoint
+var listingSorts = map[string]string{
+	"created_at":   "l.created_at",
+	"published_at": "COALESCE(l.published_at, l.created_at)",
+	"price":        "l.price_pence",
+	"condition":    "COALESCE(l.condition_grade, 0)",
+}
+
+// scanListing reads a row selected with listingColumns, followed by any extra columns
+func scanListing(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.Listing, error) {
+	var l models.Listing
+	var storeName, profileImage, markerID, markerName, region sql.NullString
+	var grade sql.NullInt64
+	var lat, lng, accuracy sql.NullFloat64
+	var publishedAt, expiresAt sql.NullTime
+
+	dest := []interface{}{
+		&l.ID, &l.Seller.ID, &l.Seller.DisplayName, &storeName, &profileImage, &l.Seller.CollectionPublic,
+		&l.CatalogueItemID, &l.Title, &l.Description, &l.Franchise, &l.PricePence, &grade, &l.Packaging,
+		pq.Array(&l.Photos), &l.Quantity, &l.Status, &markerID, &markerName, &region, &lat, &lng, &accuracy,
+		&publishedAt, &expiresAt, &l.CreatedAt, &l.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return l, err
+	}
+
+	if storeName.Valid {
+		l.Seller.StoreName = &storeName.String
+	}
+	if profileImage.Valid {
+		l.Seller.ProfileImage = &profileImage.String
+	}
+	if grade.Valid {
+		g := int(grade.Int64)
+		l.ConditionGrade = &g
+	}
+	if markerID.Valid {
+		l.Pickup = &models.ListingPickup{
+			MarkerID:  markerID.String,
+			Name:      markerName.String,
+			Region:    region.String,
+			Latitude:  lat.Float64,
+			Longitude: lng.Float64,
+			AccuracyM: accuracy.Float64,
+		}
+	}
+	if publishedAt.Valid {
+		l.PublishedAt = &publishedAt.Time
+	}
+	if expiresAt.Valid {
+		l.ExpiresAt = &expiresAt.Time
+	}
+	if l.Photos == nil {
+		l.Photos = []string{}
+	}
+	return l, nil
+}
+
+// listingFilters adds the search conditions from the query string to args and conditions.
+// Supports ?q=, ?franchise=, ?franchise_id=, ?catalogue_item_id=, ?seller_id=, ?min_price=,
+// ?max_price=, ?min_condition=, ?packaging= and ?lat=&lng= with ?radius_km=. It returns the
+// distance expression, which is NULL without a point or when the pickup marker is hidden.
+func listingFilters(r *http.Request, args *[]interface{}, conditions *[]string) (string, error) {
+	query := r.URL.Query()
+
+	if q := strings.TrimSpace(query.Get("q")); q != "" {
+		*args = append(*args, q)
+		*conditions = append(*conditions, fmt.Sprintf("(l.title ILIKE '%%' || $%[1]d || '%%' OR l.description ILIKE '%%' || $%[1]d || '%%')",
+			len(*args)))
+	}
+	if franchise := strings.TrimSpace(query.Get("franchise")); franchise != "" {
+		*args = append(*args, franchise)
+		*conditions = append(*conditions, fmt.Sprintf("LOWER(%s) = LOWER($%d)", listingFranchise, len(*args)))
+	}
+	for _, filter := range [][2]string{{"franchise_id", "cf.id"}, {"catalogue_item_id", "l.catalogue_item_id"}, {"seller_id", "l.seller_id"}} {
+		if err := uuidFilter(r, filter[0], filter[1], args, conditions); err != nil {
+			return "", err
+		}
+	}
+	for _, filter := range [][2]string{{"min_price", ">="}, {"max_price", "<="}} {
+		if value := query.Get(filter[0]); value != "" {
+			v, err := strconv.Atoi(value)
+			if err != nil || v < 0 {
+				return "", fmt.Errorf("Invalid %s, expected a price in pence", filter[0])
+			}
+			*args = append(*args, v)
+			*conditions = append(*conditions, fmt.Sprintf("l.price_pence %s $%d", filter[1], len(*args)))
+		}
+	}
+	if minCondition := query.Get("min_condition"); minCondition != "" {
+		v, err := strconv.Atoi(minCondition)
+		if err != nil || v < 1 || v > 10 {
+			return "", fmt.Errorf("Invalid min_condition, expected 1 to 10")
+		}
+		*args = append(*args, v)
+		*conditions = append(*conditions, fmt.Sprintf("l.condition_grade >= $%d", len(*args)))
+	}
+	if packaging := query.Get("packaging"); packaging != "" {
+		if !models.Packagings[packaging] {
+			return "", fmt.Errorf("Invalid packaging, expected loose, MOC or MIB")
+		}
+		*args = append(*args, packaging)
+		*conditions = append(*conditions, fmt.Sprintf("l.packaging = $%d", len(*args)))
+	}
+
+	// Distances are measured to the pickup marker's public location, like every other public output
+	lat, lng, hasPoint, err := utils.ParsePoint(r, "lat", "lng")
+	if err != nil {
+		return "", err
+	}
+	if !hasPoint {
+		return "NULL::float8", nil
+	}
+	*args = append(*args, lat, lng)
+	distanceExpr := utils.DistanceSQL("um.public_latitude", "um.public_longitude", len(*args)-1, len(*args))
+	if rk := query.Get("radius_km"); rk != "" {
+		radiusKm, err := strconv.ParseFloat(rk, 64)
+		if err != nil || radiusKm <= 0 {
+			return "", fmt.Errorf("Invalid radius")
+		}
+		*args = append(*args, radiusKm*1000)
+		*conditions = append(*conditions, fmt.Sprintf("%s <= $%d", distanceExpr, len(*args)))
+	}
+	return distanceExpr, nil
+}
+
+// queryListings returns a page of listings matching conditions and the filters of listingFilters.
+// args[0] must be the viewer. Supports ?sort=created_at|published_at|price|condition, and
+// distance when a point is given.
+func queryListings(db *sql.DB, r *http.Request, args []interface{}, conditions []string, defaultSort string) (pagination.Page[models.Listing], int, error) {
+	var result pagination.Page[models.Listing]
+
+	distanceExpr, err := listingFilters(r, &args, &conditions)
+	if err != nil {
+		return result, http.StatusBadRequest, err
+	}
+
+	opts := pagination.Options{Sorts: map[string]string{}, DefaultSort: defaultSort}
+	for name, expr := range listingSorts {
+		opts.Sorts[name] = expr
+	}
+	if distanceExpr != "NULL::float8" {
+		// Listings without a pickup location the viewer can see come last
+		opts.Sorts["distance"] = "COALESCE(" + distanceExpr + ", 'Infinity'::float8)"
+	}
+
+	page, err := pagination.FromRequest(r, opts)
+	if err != nil {
+		return result, http.StatusBadRequest, err
+	}
+	if clause, cursorArgs := page.Where("l.id", len(args)+1); clause != "" {
+		conditions = append(conditions, clause)
+		args = append(args, cursorArgs...)
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %s, %s, %s
+		%s
+		WHERE %s
+		%s
+	`, listingColumns, distanceExpr, page.SortValue(), listingFrom(1), strings.Join(conditions, " AND "),
+		page.OrderBy("l.id")), args...)
+	if err != nil {
+		log.Println("Listings query error:", err)
+		return result, http.StatusInternalServerError, fmt.Errorf("Database error")
+	}
+	defer rows.Close()
+
+	var listings []models.Listing
+	var keys [][2]string
+	for rows.Next() {
+		var distance sql.NullFloat64
+		var sortKey string
+		l, err := scanListing(rows, &distance, &sortKey)
+		if err != nil {
+			log.Println("Row scan error:", err)
+			return result, http.StatusInternalServerError, fmt.Errorf("Error scanning listings")
+		}
+		if distance.Valid {
+			l.DistanceM = &distance.Float64
+		}
+		listings = append(listings, l)
+		keys = append(keys, [2]string{sortKey, l.ID})
+	}
+	return pagination.NewPage(listings, keys, page), http.StatusOK, nil
+}
+
+// checkListingMarker makes sure a pickup marker belongs to the seller
+func checkListingMarker(tx *sql.Tx, markerID *string, sellerID uuid.UUID) (bool, error) {
+	if markerID == nil {
+		return true, nil
+	}
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM user_markers WHERE id = $1 AND user_id = $2)",
+		*markerID, sellerID).Scan(&exists)
+	return exists, err
+}
+
+// recordListingPublished adds a listing going live to the seller's activity
+func recordListingPublished(tx *sql.Tx, sellerID uuid.UUID, listingID, title string, pricePence int) error {
+	return activity.Record(tx, activity.Event{
+		ActorID:   sellerID,
+		Type:      activity.TypeListingPublished,
+		SubjectID: listingID,
+		Details:   map[string]interface{}{"title": title, "price_pence": pricePence},
+	})
+}
+
+// GetListingsHandler searches active listings. Supports the filters of listingFilters,
+// ?sort=, ?limit= and ?cursor=. Newest listings come first unless another sort is given.
+func GetListingsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		args := []interface{}{middleware.ViewerID(r)}
+		conditions := []string{"l.status = 'active'", "l.expires_at > NOW()", "u.is_deleted = FALSE", notHiddenFrom(1, "l.seller_id")}
+
+		result, status, err := queryListings(db, r, args, conditions, "-published_at")
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// GetListingHandler returns a listing. Drafts are only visible to their seller, and
+// listings are not found by anyone who has blocked the seller.
+func GetListingHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listingID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		row := db.QueryRow(fmt.Sprintf(`
+			SELECT %s
+			%s
+			WHERE l.id = $2 AND u.is_deleted = FALSE AND (l.status <> 'draft' OR l.seller_id = $1::uuid) AND %s
+		`, listingColumns, listingFrom(1), notBlockedBy(1, "l.seller_id")), middleware.ViewerID(r), listingID)
+		listing, err := scanListing(row)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("Listing query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(listing)
+	}
+}
+
+// GetMyListingsHandler lists the authenticated user's listings in every status.
+// Supports ?status= as well as the parameters of GetListingsHandler
+func GetMyListingsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		args := []interface{}{userID}
+		conditions := []string{"l.seller_id = $1"}
+		if status := r.URL.Query().Get("status"); status != "" {
+			if _, ok := listingTransitions[status]; !ok {
+				http.Error(w, "Invalid status", http.StatusBadRequest)
+				return
+			}
+			args = append(args, status)
+			conditions = append(conditions, fmt.Sprintf("l.status = $%d", len(args)))
+		}
+
+		result, status, err := queryListings(db, r, args, conditions, "-created_at")
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// CreateListingHandler lists a catalogue item or a free-form item for sale.
+// Listing it as active alerts anyone whose wishlist it matches.
+func CreateListingHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req models.CreateListingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.CatalogueItemID == nil && req.Title == nil {
+			http.Error(w, "Provide either catalogue_item_id or title", http.StatusBadRequest)
+			return
+		}
+
+		title, franchise := req.Title, req.Franchise
+		if req.CatalogueItemID != nil {
+			var catalogueName, franchiseName string
+			err := db.QueryRow(`
+				SELECT ci.name, cf.name
+				FROM catalogue_items ci
+				JOIN catalogue_lines cl ON ci.line_id = cl.id
+				JOIN catalogue_franchises cf ON cl.franchise_id = cf.id
+				WHERE ci.id = $1
+			`, *req.CatalogueItemID).Scan(&catalogueName, &franchiseName)
+			if err == sql.ErrNoRows {
+				http.Error(w, "Catalogue item not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if title == nil {
+				title = &catalogueName
+			}
+			franchise = &franchiseName
+		}
+
+		quantity := 1
+		if req.Quantity != nil {
+			quantity = *req.Quantity
+		}
+		photos := req.Photos
+		if photos == nil {
+			photos = []string{}
+		}
+
+		status := "draft"
+		var publishedAt, expiresAt *time.Time
+		if req.Status != nil && *req.Status == "active" {
+			now := time.Now()
+			expires := now.Add(ListingLifetime)
+			status, publishedAt, expiresAt = "active", &now, &expires
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if ok, err := checkListingMarker(tx, req.MarkerID, userID); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		} else if !ok {
+			http.Error(w, "Pickup marker not found", http.StatusNotFound)
+			return
+		}
+
+		var id string
+		err = tx.QueryRow(`
+			INSERT INTO listings (seller_id, catalogue_item_id, marker_id, title, description, franchise, price_pence,
+				condition_grade, packaging, photos, quantity, status, published_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			RETURNING id
+		`, userID, req.CatalogueItemID, req.MarkerID, *title, req.Description, franchise, *req.PricePence,
+			req.ConditionGrade, req.Packaging, pq.Array(photos), quantity, status, publishedAt, expiresAt).Scan(&id)
+		if err != nil {
+			log.Printf("Create listing error: %v", err)
+			http.Error(w, "Error creating listing", http.StatusInternalServerError)
+			return
+		}
+
+		if status == "active" {
+			if err := notifyWishlistMatches(tx, userID); err != nil {
+				log.Printf("Wishlist match error: %v", err)
+				http.Error(w, "Error creating listing", http.StatusInternalServerError)
+				return
+			}
+			if err := recordListingPublished(tx, userID, id, *title, *req.PricePence); err != nil {
+				log.Printf("Record activity error: %v", err)
+				http.Error(w, "Error creating listing", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	}
+}
+
+// UpdateListingHandler edits one of the authenticated user's listings and moves it through
+// its lifecycle. Going live, or going live again after expiring, restarts the listing period.
+func UpdateListingHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listingID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.UpdateListingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var current string
+		var currentExpiry sql.NullTime
+		err = tx.QueryRow("SELECT status, expires_at FROM listings WHERE id = $1 AND seller_id = $2 FOR UPDATE",
+			listingID, userID).Scan(&current, &currentExpiry)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		status := current
+		var publishedAt, expiresAt *time.Time
+		if req.Status != nil && *req.Status != current {
+			if !listingTransitions[current][*req.Status] {
+				http.Error(w, fmt.Sprintf("Cannot move a %s listing to %s", current, *req.Status), http.StatusConflict)
+				return
+			}
+			status = *req.Status
+			now := time.Now()
+			if status == "active" && current != "reserved" {
+				expires := now.Add(ListingLifetime)
+				publishedAt, expiresAt = &now, &expires
+			} else if status == "active" && currentExpiry.Time.Before(now) {
+				// A reservation that fell through after the listing was due to expire gets a fresh period
+				expires := now.Add(ListingLifetime)
+				expiresAt = &expires
+			}
+		}
+
+		if ok, err := checkListingMarker(tx, req.MarkerID, userID); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		} else if !ok {
+			http.Error(w, "Pickup marker not found", http.StatusNotFound)
+			return
+		}
+
+		var photos interface{}
+		if req.Photos != nil {
+			photos = pq.Array(req.Photos)
+		}
+
+		var title string
+		var pricePence int
+		err = tx.QueryRow(`
+			UPDATE listings
+			SET title = COALESCE($1, title),
+				description = COALESCE($2, description),
+				franchise = COALESCE($3, franchise),
+				price_pence = COALESCE($4, price_pence),
+				condition_grade = COALESCE($5, condition_grade),
+				packaging = COALESCE($6, packaging),
+				photos = COALESCE($7, photos),
+				quantity = COALESCE($8, quantity),
+				marker_id = COALESCE($9, marker_id),
+				status = $10,
+				published_at = COALESCE($11, published_at),
+				expires_at = COALESCE($12, expires_at),
+				updated_at = NOW()
+			WHERE id = $13
+			RETURNING title, price_pence
+		`, req.Title, req.Description, req.Franchise, req.PricePence, req.ConditionGrade, req.Packaging, photos,
+			req.Quantity, req.MarkerID, status, publishedAt, expiresAt, listingID).Scan(&title, &pricePence)
+		if err != nil {
+			log.Printf("Update listing error: %v", err)
+			http.Error(w, "Failed to update listing", http.StatusInternalServerError)
+			return
+		}
+
+		if status == "sold" && current != "sold" {
+			if err := recordListingSale(tx, listingID); err != nil {
+				log.Printf("Record listing sale error: %v", err)
+				http.Error(w, "Failed to update listing", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// Going live, a lower price or a better grade can satisfy wishlists it didn't before
+		if status == "active" {
+			if err := notifyWishlistMatches(tx, userID); err != nil {
+				log.Printf("Wishlist match error: %v", err)
+				http.Error(w, "Failed to update listing", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// Followers hear about a listing when it is published, not when it comes back from a reservation
+		if publishedAt != nil {
+			if err := recordListingPublished(tx, userID, listingID.String(), title, pricePence); err != nil {
+				log.Printf("Record activity error: %v", err)
+				http.Error(w, "Failed to update listing", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Listing updated successfully"})
+	}
+}
+
+// DeleteListingHandler removes one of the authenticated user's listings
+func DeleteListingHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listingID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM listings WHERE id = $1 AND seller_id = $2", listingID, userID)
+		if err != nil {
+			http.Error(w, "Error deleting listing", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Listing deleted successfully"})
+	}
+}
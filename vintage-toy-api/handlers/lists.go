@@ -0,0 +1,682 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/gpx"
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+	"github.com/Joseph_Bartram8/vintage-toy-api/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// listColumns returns the columns read by scanList
+const listColumns = `
+	ml.id, ml.name, ml.description, ml.visibility, ml.share_token, ml.created_at, ml.updated_at,
+	(SELECT COUNT(*) FROM marker_list_items li WHERE li.list_id = ml.id)`
+
+// scanList reads a row selected with listColumns, followed by any extra columns
+func scanList(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.MarkerList, error) {
+	var list models.MarkerList
+	dest := []interface{}{
+		&list.ID, &list.Name, &list.Description, &list.Visibility, &list.ShareToken, &list.CreatedAt, &list.UpdatedAt,
+		&list.MarkerCount,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	return list, err
+}
+
+// listMarkerIDs returns the IDs of a list's markers in order
+func listMarkerIDs(tx *sql.Tx, listID uuid.UUID) ([]string, error) {
+	rows, err := tx.Query("SELECT marker_id FROM marker_list_items WHERE list_id = $1 ORDER BY position", listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// renumberList stores ids as the list's order, numbering positions from 1
+func renumberList(tx *sql.Tx, listID uuid.UUID, ids []string) error {
+	_, err := tx.Exec(`
+		UPDATE marker_list_items li
+		SET position = o.ord
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS o(marker_id, ord)
+		WHERE li.list_id = $1 AND li.marker_id = o.marker_id
+	`, listID, pq.Array(ids))
+	return err
+}
+
+// lockOwnedList locks one of the user's lists so concurrent edits can't interleave positions
+func lockOwnedList(tx *sql.Tx, listID, userID uuid.UUID) error {
+	var id string
+	return tx.QueryRow("SELECT id FROM marker_lists WHERE id = $1 AND user_id = $2 FOR UPDATE", listID, userID).Scan(&id)
+}
+
+// loadListItems returns a list's markers in order, leaving out any the viewer can no longer see
+func loadListItems(db *sql.DB, listID string, viewerID interface{}) ([]models.MarkerListItem, error) {
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %s, li.position, li.note
+		%s
+		JOIN marker_list_items li ON li.marker_id = um.id
+		WHERE li.list_id = $2 AND u.is_deleted = FALSE AND %s
+		ORDER BY li.position
+	`, markerColumns(1), markerFrom, markerVisibleTo(1)), viewerID, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.MarkerListItem{}
+	for rows.Next() {
+		var item models.MarkerListItem
+		marker, err := scanMarker(rows, &item.Position, &item.Note)
+		if err != nil {
+			return nil, err
+		}
+		item.Marker = marker
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// writeList encodes a list as JSON, or its markers as GeoJSON or GPX when ?format= is set
+func writeList(w http.ResponseWriter, r *http.Request, list models.MarkerListDetail) {
+	switch r.URL.Query().Get("format") {
+	case "geojson":
+		features := make([]models.Feature, 0, len(list.Items))
+		for _, item := range list.Items {
+			features = append(features, models.NewPointFeature(item.Marker.ID, item.Marker.Latitude, item.Marker.Longitude, item))
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		json.NewEncoder(w).Encode(models.NewFeatureCollection(features))
+	case "gpx":
+		doc := gpx.New(list.Name)
+		for _, item := range list.Items {
+			wpt := gpx.Waypoint{Lat: item.Marker.Latitude, Lon: item.Marker.Longitude, Name: item.Marker.Name}
+			if item.Note != nil {
+				wpt.Desc = *item.Note
+			}
+			doc.Waypoints = append(doc.Waypoints, wpt)
+		}
+
+		w.Header().Set("Content-Type", "application/gpx+xml")
+		w.Header().Set("Content-Disposition", `attachment; filename="list.gpx"`)
+		if err := doc.Write(w); err != nil {
+			log.Printf("Write GPX error: %v", err)
+		}
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// GetMyListsHandler lists the authenticated user's saved lists
+func GetMyListsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts: map[string]string{
+				"updated_at": "ml.updated_at",
+				"name":       "ml.name",
+			},
+			DefaultSort: "-updated_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{userID}
+		conditions := []string{"ml.user_id = $1"}
+		if clause, cursorArgs := page.Where("ml.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, %s
+			FROM marker_lists ml
+			WHERE %s
+			%s
+		`, listColumns, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("ml.id")), args...)
+		if err != nil {
+			log.Println("Lists query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var lists []models.MarkerList
+		var keys [][2]string
+		for rows.Next() {
+			var sortKey string
+			list, err := scanList(rows, &sortKey)
+			if err != nil {
+				http.Error(w, "Error scanning lists", http.StatusInternalServerError)
+				return
+			}
+			lists = append(lists, list)
+			keys = append(keys, [2]string{sortKey, list.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(lists, keys, page))
+	}
+}
+
+// CreateListHandler creates a saved list for the authenticated user
+func CreateListHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req models.CreateListRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		visibility := "private"
+		if req.Visibility != nil {
+			visibility = *req.Visibility
+		}
+
+		var shareToken *string
+		if visibility == "shared" {
+			token, err := utils.RandomToken(18)
+			if err != nil {
+				http.Error(w, "Error creating share link", http.StatusInternalServerError)
+				return
+			}
+			shareToken = &token
+		}
+
+		list, err := scanList(db.QueryRow(`
+			INSERT INTO marker_lists AS ml (user_id, name, description, visibility, share_token)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+listColumns,
+			userID, req.Name, req.Description, visibility, shareToken))
+		if err != nil {
+			log.Printf("Create list error: %v", err)
+			http.Error(w, "Error creating list", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// GetListHandler returns one of the authenticated user's lists with its markers.
+// Supports ?format=geojson and ?format=gpx
+func GetListHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		list, err := scanList(db.QueryRow("SELECT "+listColumns+" FROM marker_lists ml WHERE ml.id = $1 AND ml.user_id = $2",
+			listID, userID))
+		if err == sql.ErrNoRows {
+			http.Error(w, "List not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		items, err := loadListItems(db, list.ID, userID)
+		if err != nil {
+			log.Println("List items query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeList(w, r, models.MarkerListDetail{MarkerList: list, Items: items})
+	}
+}
+
+// GetSharedListHandler returns a shared list by its link token. Markers are still subject to
+// their own visibility, so a shared list never reveals markers the viewer couldn't otherwise see.
+// Supports ?format=geojson and ?format=gpx
+func GetSharedListHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := chi.URLParam(r, "token")
+
+		list, err := scanList(db.QueryRow(`
+			SELECT `+listColumns+`
+			FROM marker_lists ml
+			JOIN users u ON ml.user_id = u.id
+			WHERE ml.share_token = $1 AND ml.visibility = 'shared' AND u.is_deleted = FALSE
+		`, token))
+		if err == sql.ErrNoRows {
+			http.Error(w, "List not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		items, err := loadListItems(db, list.ID, middleware.ViewerID(r))
+		if err != nil {
+			log.Println("List items query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		// The token is only for the owner to hand out again
+		list.ShareToken = nil
+		list.MarkerCount = len(items)
+		writeList(w, r, models.MarkerListDetail{MarkerList: list, Items: items})
+	}
+}
+
+// UpdateListHandler renames, describes or changes the sharing of one of the authenticated user's lists
+func UpdateListHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.UpdateListRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		newToken, err := utils.RandomToken(18)
+		if err != nil {
+			http.Error(w, "Error creating share link", http.StatusInternalServerError)
+			return
+		}
+
+		// Making a list private drops its link; sharing it again issues a fresh one
+		list, err := scanList(db.QueryRow(`
+			UPDATE marker_lists ml
+			SET name = COALESCE($1, name),
+				description = COALESCE($2, description),
+				visibility = COALESCE($3, visibility),
+				share_token = CASE
+					WHEN COALESCE($3, visibility) = 'private' THEN NULL
+					WHEN share_token IS NULL OR $4 THEN $5
+					ELSE share_token END,
+				updated_at = NOW()
+			WHERE id = $6 AND user_id = $7
+			RETURNING `+listColumns,
+			req.Name, req.Description, req.Visibility, req.RegenerateLink, newToken, listID, userID))
+		if err == sql.ErrNoRows {
+			http.Error(w, "List not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Update list error: %v", err)
+			http.Error(w, "Failed to update list", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// DeleteListHandler removes one of the authenticated user's lists
+func DeleteListHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM marker_lists WHERE id = $1 AND user_id = $2", listID, userID)
+		if err != nil {
+			http.Error(w, "Error deleting list", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "List not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "List deleted successfully"})
+	}
+}
+
+// SetListItemHandler adds a marker to one of the authenticated user's lists, or updates
+// its note. A position moves the marker there; new markers otherwise go to the end.
+func SetListItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+		markerID, err := uuid.Parse(chi.URLParam(r, "markerID"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.ListItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if err := lockOwnedList(tx, listID, userID); err == sql.ErrNoRows {
+			http.Error(w, "List not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		var visible bool
+		err = tx.QueryRow(fmt.Sprintf(`
+			SELECT EXISTS (
+				SELECT 1 FROM user_markers um
+				JOIN users u ON um.user_id = u.id
+				WHERE um.id = $1 AND u.is_deleted = FALSE AND %s
+			)
+		`, markerVisibleTo(2)), markerID, userID).Scan(&visible)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !visible {
+			http.Error(w, "Marker not found", http.StatusNotFound)
+			return
+		}
+
+		ids, err := listMarkerIDs(tx, listID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		current := -1
+		for i, id := range ids {
+			if id == markerID.String() {
+				current = i
+				break
+			}
+		}
+
+		if current < 0 {
+			if len(ids) >= models.MaxListItems {
+				http.Error(w, fmt.Sprintf("Lists can hold at most %d markers", models.MaxListItems), http.StatusBadRequest)
+				return
+			}
+			_, err = tx.Exec("INSERT INTO marker_list_items (list_id, marker_id, position, note) VALUES ($1, $2, $3, $4)",
+				listID, markerID, len(ids)+1, req.Note)
+			ids = append(ids, markerID.String())
+			current = len(ids) - 1
+		} else if req.Note != nil {
+			_, err = tx.Exec("UPDATE marker_list_items SET note = $1 WHERE list_id = $2 AND marker_id = $3",
+				req.Note, listID, markerID)
+		}
+		if err != nil {
+			log.Printf("Save list item error: %v", err)
+			http.Error(w, "Failed to save list item", http.StatusInternalServerError)
+			return
+		}
+
+		if req.Position != nil {
+			target := *req.Position - 1
+			if target >= len(ids) {
+				target = len(ids) - 1
+			}
+			id := ids[current]
+			ids = append(ids[:current], ids[current+1:]...)
+			ids = append(ids[:target], append([]string{id}, ids[target:]...)...)
+			if err := renumberList(tx, listID, ids); err != nil {
+				http.Error(w, "Failed to save list item", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if _, err := tx.Exec("UPDATE marker_lists SET updated_at = NOW() WHERE id = $1", listID); err != nil {
+			http.Error(w, "Failed to save list item", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "List updated"})
+	}
+}
+
+// RemoveListItemHandler removes a marker from one of the authenticated user's lists
+func RemoveListItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+		markerID, err := uuid.Parse(chi.URLParam(r, "markerID"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if err := lockOwnedList(tx, listID, userID); err == sql.ErrNoRows {
+			http.Error(w, "List not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		result, err := tx.Exec("DELETE FROM marker_list_items WHERE list_id = $1 AND marker_id = $2", listID, markerID)
+		if err != nil {
+			http.Error(w, "Error removing marker from list", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Marker is not in this list", http.StatusNotFound)
+			return
+		}
+
+		// Close the gap so positions stay 1..n
+		ids, err := listMarkerIDs(tx, listID)
+		if err == nil {
+			err = renumberList(tx, listID, ids)
+		}
+		if err == nil {
+			_, err = tx.Exec("UPDATE marker_lists SET updated_at = NOW() WHERE id = $1", listID)
+		}
+		if err != nil {
+			http.Error(w, "Error removing marker from list", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Marker removed from list"})
+	}
+}
+
+// ReorderListHandler sets the order of every marker in one of the authenticated user's lists
+func ReorderListHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.ReorderListRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if err := lockOwnedList(tx, listID, userID); err == sql.ErrNoRows {
+			http.Error(w, "List not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		ids, err := listMarkerIDs(tx, listID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		// The new order must name every marker in the list exactly once
+		remaining := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			remaining[id] = true
+		}
+		for _, id := range req.MarkerIDs {
+			if !remaining[strings.ToLower(id)] {
+				http.Error(w, "marker_ids must list each marker in the list exactly once", http.StatusBadRequest)
+				return
+			}
+			delete(remaining, strings.ToLower(id))
+		}
+		if len(remaining) > 0 {
+			http.Error(w, "marker_ids must list each marker in the list exactly once", http.StatusBadRequest)
+			return
+		}
+
+		if err := renumberList(tx, listID, req.MarkerIDs); err != nil {
+			log.Printf("Reorder list error: %v", err)
+			http.Error(w, "Failed to reorder list", http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec("UPDATE marker_lists SET updated_at = NOW() WHERE id = $1", listID); err != nil {
+			http.Error(w, "Failed to reorder list", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "List reordered"})
+	}
+}
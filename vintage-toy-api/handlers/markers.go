@@ -3,26 +3,281 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Joseph_Bartram8/vintage-toy-api/activity"
+	"github.com/Joseph_Bartram8/vintage-toy-api/calendar"
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
 	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+	"github.com/Joseph_Bartram8/vintage-toy-api/suggest"
+	"github.com/Joseph_Bartram8/vintage-toy-api/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-// GetAllMarkersHandler retrieves all markers along with relevant user data, including profile image
+// maxTileMarkers caps how many markers a single map tile returns
+const maxTileMarkers = 2000
+
+// markerColumns returns the columns read by scanMarker. Responses use the fuzzed location
+// maintained by trigger_fuzz_marker_location, except for the owner bound to $viewerArg,
+// who sees the exact point. The same viewer decides favourited_by_me. A viewerArg of 0
+// always selects the public location.
+func markerColumns(viewerArg int) string {
+	location := "um.public_latitude, um.public_longitude, um.accuracy_m"
+	favourited := "FALSE"
+	if viewerArg > 0 {
+		favourited = fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM marker_favourites mf WHERE mf.marker_id = um.id AND mf.user_id = $%d::uuid)", viewerArg)
+		isOwner := fmt.Sprintf("um.user_id = $%d::uuid", viewerArg)
+		location = fmt.Sprintf(`
+			CASE WHEN %[1]s THEN um.latitude ELSE um.public_latitude END,
+			CASE WHEN %[1]s THEN um.longitude ELSE um.public_longitude END,
+			CASE WHEN %[1]s THEN 0 ELSE um.accuracy_m END`, isOwner)
+	}
+	return fmt.Sprintf(`
+		um.id, um.name, um.description, %s, um.location_precision, um.visibility, um.region, um.marker_type, um.created_at,
+		ub.display_name, ub.store_name, u.first_name, u.last_name, ub.show_real_name, ub.profile_image,
+		me.starts_at, me.ends_at, me.timezone, me.rrule, me.exdates, me.capacity,
+		%s, um.rating_avg, um.rating_count, um.favourite_count, %s`, location, shopStatusColumns, favourited)
+}
+
+// shopStatusColumns computes a shop's open status, next opening and current closing time
+const shopStatusColumns = `
+	CASE WHEN um.marker_type <> 'Shop' THEN NULL
+		WHEN NOT EXISTS (SELECT 1 FROM shop_opening_hours soh WHERE soh.marker_id = um.id) THEN 'unknown'
+		WHEN shop_is_open(um.id, NOW()) THEN 'open'
+		ELSE 'closed' END,
+	CASE WHEN um.marker_type = 'Shop' THEN shop_next_opening(um.id, NOW()) END,
+	CASE WHEN um.marker_type = 'Shop' THEN shop_closing_time(um.id, NOW()) END`
+
+// markerFrom joins the tables read by markerColumns
+const markerFrom = `
+	FROM user_markers um
+	JOIN users u ON um.user_id = u.id
+	LEFT JOIN user_bios ub ON u.id = ub.user_id
+	LEFT JOIN marker_events me ON me.marker_id = um.id`
+
+// upcomingOnly drops events whose final occurrence has ended from map listings
+const upcomingOnly = "(me.series_ends_at IS NULL OR me.series_ends_at > NOW())"
+
+// markerVisibleTo returns a condition limiting markers to those the viewer bound to
+// $viewerArg may see. A NULL viewer is anonymous and only sees public markers. Markers of
+// users the viewer has blocked or muted are left out.
+func markerVisibleTo(viewerArg int) string {
+	return fmt.Sprintf(`((um.visibility = 'public'
+		OR (um.visibility = 'members' AND $%[1]d::uuid IS NOT NULL)
+		OR (um.visibility = 'followers' AND EXISTS (
+			SELECT 1 FROM user_follows f WHERE f.follower_id = $%[1]d::uuid AND f.followee_id = um.user_id))
+		OR um.user_id = $%[1]d::uuid)
+		AND %[2]s)`, viewerArg, notHiddenFrom(viewerArg, "um.user_id"))
+}
+
+// scanMarker reads a row selected with markerColumns, followed by any extra columns
+func scanMarker(rows *sql.Rows, extra ...interface{}) (models.MarkerResponse, error) {
+	var marker models.MarkerResponse
+	var user models.MarkerUserInfo
+	var displayName, firstName, lastName, profileImage sql.NullString
+	var showRealName sql.NullBool
+	var startsAt, endsAt sql.NullTime
+	var timezone, rrule sql.NullString
+	var exdates []string
+	var capacity sql.NullInt64
+	var openStatus sql.NullString
+	var nextOpensAt, closesAt sql.NullTime
+	var ratingAvg sql.NullFloat64
+
+	dest := []interface{}{
+		&marker.ID, &marker.Name, &marker.Description, &marker.Latitude, &marker.Longitude, &marker.AccuracyM,
+		&marker.LocationPrecision, &marker.Visibility, &marker.Region, &marker.MarkerType, &marker.CreatedAt,
+		&displayName, &user.StoreName, &firstName, &lastName, &showRealName, &profileImage,
+		&startsAt, &endsAt, &timezone, &rrule, pq.Array(&exdates), &capacity,
+		&openStatus, &nextOpensAt, &closesAt, &ratingAvg, &marker.RatingCount,
+		&marker.FavouriteCount, &marker.FavouritedByMe,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return marker, err
+	}
+
+	user.DisplayName = displayName.String
+
+	// Handle privacy setting for real names
+	if showRealName.Bool {
+		if firstName.Valid {
+			user.FirstName = &firstName.String
+		}
+		if lastName.Valid {
+			user.LastName = &lastName.String
+		}
+	}
+
+	// Assign profile image if available
+	if profileImage.Valid {
+		user.ProfileImage = &profileImage.String
+	}
+
+	if startsAt.Valid {
+		schedule := &models.EventSchedule{
+			StartsAt: startsAt.Time.Format(calendar.LocalTimeLayout),
+			EndsAt:   endsAt.Time.Format(calendar.LocalTimeLayout),
+			Timezone: timezone.String,
+		}
+		if rrule.Valid {
+			schedule.RRule = &rrule.String
+		}
+		if capacity.Valid {
+			c := int(capacity.Int64)
+			schedule.Capacity = &c
+		}
+		for _, ex := range exdates {
+			schedule.ExDates = append(schedule.ExDates, strings.Replace(ex, " ", "T", 1))
+		}
+		marker.Schedule = schedule
+	}
+
+	if openStatus.Valid {
+		marker.OpenStatus = &openStatus.String
+	}
+	if nextOpensAt.Valid {
+		marker.NextOpensAt = &nextOpensAt.Time
+	}
+	if closesAt.Valid {
+		marker.ClosesAt = &closesAt.Time
+	}
+
+	if ratingAvg.Valid {
+		marker.RatingAvg = &ratingAvg.Float64
+	}
+
+	marker.User = user
+	return marker, nil
+}
+
+// writeMarkers encodes markers as JSON, or as GeoJSON when ?format=geojson is set
+func writeMarkers(w http.ResponseWriter, r *http.Request, page pagination.Page[models.MarkerResponse]) {
+	if r.URL.Query().Get("format") == "geojson" {
+		features := make([]models.Feature, 0, len(page.Data))
+		for _, m := range page.Data {
+			features = append(features, models.NewPointFeature(m.ID, m.Latitude, m.Longitude, m))
+		}
+		fc := models.NewFeatureCollection(features)
+		fc.NextCursor = page.NextCursor
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		json.NewEncoder(w).Encode(fc)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// GetAllMarkersHandler retrieves a page of markers along with relevant user data, including profile image.
+// Supports ?region=, ?marker_type=, ?lat=&lng= (enables sort=distance), ?include_past=true,
+// ?open_now=true, ?open_at=, ?min_rating=, ?sort=, ?limit=, ?cursor= and ?format=geojson
 func GetAllMarkersHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		query := `
-			SELECT 
-				um.id, um.name, um.description, um.latitude, um.longitude, um.region, um.marker_type, um.created_at,
-				ub.display_name, ub.store_name, u.first_name, u.last_name, ub.show_real_name, ub.profile_image
-			FROM user_markers um
-			JOIN users u ON um.user_id = u.id
-			LEFT JOIN user_bios ub ON u.id = ub.user_id
-			WHERE u.is_deleted = FALSE;
-		`
-
-		rows, err := db.Query(query)
+		args := []interface{}{middleware.ViewerID(r)}
+		conditions := []string{"u.is_deleted = FALSE", markerVisibleTo(1)}
+
+		// Past one-off events are hidden unless explicitly requested
+		if r.URL.Query().Get("include_past") != "true" {
+			conditions = append(conditions, upcomingOnly)
+		}
+
+		opts := pagination.Options{
+			Sorts: map[string]string{
+				"created_at": "COALESCE(um.created_at, 'epoch'::timestamp)",
+				"name":       "um.name",
+				"rating":     "COALESCE(um.rating_avg, 0)",
+				"reviews":    "um.rating_count",
+			},
+			DefaultSort: "-created_at",
+		}
+
+		// Distance sorting is only available when a reference point is supplied,
+		// and is measured to the public location so it can't be used to triangulate
+		distanceExpr := "NULL::float8"
+		lat, lng, hasPoint, err := utils.ParsePoint(r, "lat", "lng")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if hasPoint {
+			args = append(args, lat, lng)
+			distanceExpr = utils.DistanceSQL("um.public_latitude", "um.public_longitude", len(args)-1, len(args))
+			opts.Sorts["distance"] = distanceExpr
+		}
+
+		if region := r.URL.Query().Get("region"); region != "" {
+			if !models.Regions[region] {
+				http.Error(w, "Invalid region", http.StatusBadRequest)
+				return
+			}
+			args = append(args, region)
+			conditions = append(conditions, fmt.Sprintf("um.region = $%d", len(args)))
+		}
+
+		if markerType := r.URL.Query().Get("marker_type"); markerType != "" {
+			if !models.MarkerTypes[markerType] {
+				http.Error(w, "Invalid marker type", http.StatusBadRequest)
+				return
+			}
+			args = append(args, markerType)
+			conditions = append(conditions, fmt.Sprintf("um.marker_type = $%d", len(args)))
+		}
+
+		// Opening hours filters only ever match shops
+		if r.URL.Query().Get("open_now") == "true" {
+			conditions = append(conditions, "um.marker_type = 'Shop' AND shop_is_open(um.id, NOW())")
+		}
+		if openAt := r.URL.Query().Get("open_at"); openAt != "" {
+			t, err := time.Parse(time.RFC3339, openAt)
+			if err != nil {
+				http.Error(w, "Invalid open_at, expected RFC 3339", http.StatusBadRequest)
+				return
+			}
+			args = append(args, t)
+			conditions = append(conditions, fmt.Sprintf("um.marker_type = 'Shop' AND shop_is_open(um.id, $%d)", len(args)))
+		}
+
+		if minRating := r.URL.Query().Get("min_rating"); minRating != "" {
+			v, err := strconv.ParseFloat(minRating, 64)
+			if err != nil || v < 1 || v > 5 {
+				http.Error(w, "Invalid min_rating, expected a number from 1 to 5", http.StatusBadRequest)
+				return
+			}
+			args = append(args, v)
+			conditions = append(conditions, fmt.Sprintf("um.rating_avg >= $%d", len(args)))
+		}
+
+		page, err := pagination.FromRequest(r, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if clause, cursorArgs := page.Where("um.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		query := fmt.Sprintf(`
+			SELECT %s, %s, %s
+			%s
+			WHERE %s
+			%s;
+		`, markerColumns(1), distanceExpr, page.SortValue(), markerFrom, strings.Join(conditions, " AND "), page.OrderBy("um.id"))
+
+		rows, err := db.Query(query, args...)
 		if err != nil {
 			log.Println("Database query error:", err)
 			http.Error(w, "Database error", http.StatusInternalServerError)
@@ -31,46 +286,344 @@ func GetAllMarkersHandler(db *sql.DB) http.HandlerFunc {
 		defer rows.Close()
 
 		var markers []models.MarkerResponse
+		var keys [][2]string
 
 		for rows.Next() {
-			var marker models.MarkerResponse
-			var user models.MarkerUserInfo
-			var firstName, lastName, profileImage sql.NullString
-			var showRealName bool
-
-			err := rows.Scan(
-				&marker.ID, &marker.Name, &marker.Description, &marker.Latitude, &marker.Longitude,
-				&marker.Region, &marker.MarkerType, &marker.CreatedAt,
-				&user.DisplayName, &user.StoreName, &firstName, &lastName, &showRealName, &profileImage,
-			)
+			var distance sql.NullFloat64
+			var sortKey string
 
+			marker, err := scanMarker(rows, &distance, &sortKey)
 			if err != nil {
 				log.Println("Row scan error:", err)
 				http.Error(w, "Database scan error", http.StatusInternalServerError)
 				return
 			}
 
-			// Handle privacy setting for real names
-			if showRealName {
-				if firstName.Valid {
-					user.FirstName = &firstName.String
-				}
-				if lastName.Valid {
-					user.LastName = &lastName.String
-				}
+			if distance.Valid {
+				marker.DistanceM = &distance.Float64
 			}
 
-			// Assign profile image if available
-			if profileImage.Valid {
-				user.ProfileImage = &profileImage.String
+			markers = append(markers, marker)
+			keys = append(keys, [2]string{sortKey, marker.ID})
+		}
+
+		// Return JSON response
+		writeMarkers(w, r, pagination.NewPage(markers, keys, page))
+	}
+}
+
+// GetMarkerTileHandler returns the markers inside a web-mercator tile as GeoJSON.
+// Tile membership is decided on the public location, the same as every other public output.
+func GetMarkerTileHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		z, errZ := strconv.Atoi(chi.URLParam(r, "z"))
+		x, errX := strconv.Atoi(chi.URLParam(r, "x"))
+		y, errY := strconv.Atoi(strings.TrimSuffix(chi.URLParam(r, "y"), ".geojson"))
+		if errZ != nil || errX != nil || errY != nil || z < 0 || z > 22 {
+			http.Error(w, "Invalid tile coordinates", http.StatusBadRequest)
+			return
+		}
+		n := 1 << z
+		if x < 0 || x >= n || y < 0 || y >= n {
+			http.Error(w, "Invalid tile coordinates", http.StatusBadRequest)
+			return
+		}
+
+		west, north := tileToLatLng(x, y, n)
+		east, south := tileToLatLng(x+1, y+1, n)
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s
+			%s
+			WHERE u.is_deleted = FALSE AND %s
+			  AND um.public_latitude >= $1 AND um.public_latitude < $2
+			  AND um.public_longitude >= $3 AND um.public_longitude < $4
+			  AND %s
+			LIMIT %d
+		`, markerColumns(5), markerFrom, upcomingOnly, markerVisibleTo(5), maxTileMarkers), south, north, west, east, middleware.ViewerID(r))
+		if err != nil {
+			log.Println("Tile query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var features []models.Feature
+		for rows.Next() {
+			marker, err := scanMarker(rows)
+			if err != nil {
+				log.Println("Tile scan error:", err)
+				http.Error(w, "Database scan error", http.StatusInternalServerError)
+				return
 			}
+			features = append(features, models.NewPointFeature(marker.ID, marker.Latitude, marker.Longitude, marker))
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		json.NewEncoder(w).Encode(models.NewFeatureCollection(features))
+	}
+}
+
+// tileToLatLng returns the longitude and latitude of a tile's north-west corner
+func tileToLatLng(x, y, n int) (lng, lat float64) {
+	lng = float64(x)/float64(n)*360 - 180
+	lat = math.Atan(math.Sinh(math.Pi*(1-2*float64(y)/float64(n)))) * 180 / math.Pi
+	return lng, lat
+}
+
+// GetMyMarkersHandler lists the authenticated user's own markers with their exact locations
+func GetMyMarkersHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
 
-			marker.User = user
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts: map[string]string{
+				"created_at": "COALESCE(um.created_at, 'epoch'::timestamp)",
+				"name":       "um.name",
+			},
+			DefaultSort: "-created_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{userID}
+		conditions := []string{"um.user_id = $1"}
+		if clause, cursorArgs := page.Where("um.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, %s
+			%s
+			WHERE %s
+			%s
+		`, markerColumns(1), page.SortValue(), markerFrom, strings.Join(conditions, " AND "), page.OrderBy("um.id")), args...)
+		if err != nil {
+			log.Println("Database query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var markers []models.MarkerResponse
+		var keys [][2]string
+		for rows.Next() {
+			var sortKey string
+			marker, err := scanMarker(rows, &sortKey)
+			if err != nil {
+				log.Println("Row scan error:", err)
+				http.Error(w, "Database scan error", http.StatusInternalServerError)
+				return
+			}
 			markers = append(markers, marker)
+			keys = append(keys, [2]string{sortKey, marker.ID})
+		}
+
+		writeMarkers(w, r, pagination.NewPage(markers, keys, page))
+	}
+}
+
+// CreateMarkerHandler adds a marker owned by the authenticated user
+func CreateMarkerHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req models.CreateMarkerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !models.Regions[req.Region] {
+			http.Error(w, "Invalid region", http.StatusBadRequest)
+			return
+		}
+		if !models.MarkerTypes[req.MarkerType] {
+			http.Error(w, "Invalid marker type", http.StatusBadRequest)
+			return
+		}
+		if req.LocationPrecision != nil && !models.LocationPrecisions[*req.LocationPrecision] {
+			http.Error(w, "Invalid location precision", http.StatusBadRequest)
+			return
+		}
+		visibility := "public"
+		if req.Visibility != nil {
+			visibility = *req.Visibility
+		}
+		if !models.MarkerVisibilities[visibility] {
+			http.Error(w, "Invalid visibility", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		// A NULL precision lets the trigger apply the per-type default
+		var markerID string
+		err = tx.QueryRow(`
+			INSERT INTO user_markers (user_id, name, description, latitude, longitude, region, marker_type, location_precision, visibility)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id
+		`, userID, req.Name, req.Description, req.Latitude, req.Longitude, req.Region, req.MarkerType, req.LocationPrecision, visibility).
+			Scan(&markerID)
+		if err != nil {
+			log.Printf("Create marker error: %v", err)
+			http.Error(w, "Error creating marker", http.StatusInternalServerError)
+			return
+		}
+
+		eventType := activity.TypeMarkerCreated
+		if req.MarkerType == "Event" {
+			eventType = activity.TypeEventCreated
+		}
+		err = activity.Record(tx, activity.Event{
+			ActorID:   userID,
+			Type:      eventType,
+			SubjectID: markerID,
+			Details:   map[string]interface{}{"name": req.Name, "marker_type": req.MarkerType, "region": req.Region},
+		})
+		if err != nil {
+			log.Printf("Record activity error: %v", err)
+			http.Error(w, "Error creating marker", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		// Only public markers are offered as suggestions to everyone
+		if visibility == "public" {
+			suggest.Default.SetMarker(markerID, userID.String(), req.Name)
 		}
 
-		// Return JSON response
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(markers)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": markerID})
+	}
+}
+
+// UpdateMarkerHandler updates one of the authenticated user's markers
+func UpdateMarkerHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.UpdateMarkerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Region != nil && !models.Regions[*req.Region] {
+			http.Error(w, "Invalid region", http.StatusBadRequest)
+			return
+		}
+		if req.LocationPrecision != nil && !models.LocationPrecisions[*req.LocationPrecision] {
+			http.Error(w, "Invalid location precision", http.StatusBadRequest)
+			return
+		}
+		if req.Visibility != nil && !models.MarkerVisibilities[*req.Visibility] {
+			http.Error(w, "Invalid visibility", http.StatusBadRequest)
+			return
+		}
+
+		var name, visibility string
+		err = db.QueryRow(`
+			UPDATE user_markers
+			SET name = COALESCE($1, name),
+				description = COALESCE($2, description),
+				latitude = COALESCE($3, latitude),
+				longitude = COALESCE($4, longitude),
+				region = COALESCE($5, region),
+				location_precision = COALESCE($6, location_precision),
+				visibility = COALESCE($7, visibility),
+				updated_at = NOW()
+			WHERE id = $8 AND user_id = $9
+			RETURNING name, visibility
+		`, req.Name, req.Description, req.Latitude, req.Longitude, req.Region, req.LocationPrecision, req.Visibility,
+			markerID, userID).Scan(&name, &visibility)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Marker not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Update marker error: %v", err)
+			http.Error(w, "Failed to update marker", http.StatusInternalServerError)
+			return
+		}
+
+		if visibility == "public" {
+			suggest.Default.SetMarker(markerID.String(), userID.String(), name)
+		} else {
+			suggest.Default.RemoveMarker(markerID.String())
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Marker updated successfully"})
+	}
+}
+
+// DeleteMarkerHandler removes one of the authenticated user's markers
+func DeleteMarkerHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		res, err := db.Exec("DELETE FROM user_markers WHERE id = $1 AND user_id = $2", markerID, userID)
+		if err != nil {
+			http.Error(w, "Error deleting marker", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Marker not found", http.StatusNotFound)
+			return
+		}
+
+		suggest.Default.RemoveMarker(markerID.String())
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Marker deleted successfully"})
 	}
 }
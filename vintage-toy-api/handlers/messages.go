@@ -0,0 +1,798 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/notify"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// deletedUserName replaces the name of deleted accounts in conversations
+const deletedUserName = "Deleted user"
+
+// errMessagingBlocked is returned when a block stands between the sender and a recipient
+var errMessagingBlocked = errors.New("You cannot message a user who has blocked you or whom you have blocked")
+
+// messageColumns returns the columns read by scanMessage, from messages m with the sender
+// left joined as users u and user_bios ub
+var messageColumns = fmt.Sprintf(`
+	m.id, m.conversation_id,
+	CASE WHEN u.is_deleted THEN NULL ELSE m.sender_id END AS sender_id,
+	CASE WHEN u.id IS NULL OR u.is_deleted THEN '%s' ELSE ub.display_name END AS sender_name,
+	m.body,
+	ARRAY(
+		SELECT rp.user_id FROM conversation_participants rp
+		WHERE rp.conversation_id = m.conversation_id AND rp.user_id IS DISTINCT FROM m.sender_id
+			AND rp.last_read_at >= m.created_at
+			AND NOT EXISTS (SELECT 1 FROM users ru WHERE ru.id = rp.user_id AND ru.is_deleted)
+		ORDER BY rp.user_id
+	) AS read_by,
+	m.created_at`, deletedUserName)
+
+// scanMessage reads a row selected with messageColumns, followed by any extra columns
+func scanMessage(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.Message, error) {
+	var m models.Message
+	dest := []interface{}{&m.ID, &m.ConversationID, &m.SenderID, &m.SenderName, &m.Body, pq.Array(&m.ReadBy), &m.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return m, err
+	}
+	if m.ReadBy == nil {
+		m.ReadBy = []string{}
+	}
+	return m, nil
+}
+
+// unreadMessages counts the messages from others in conversation cp.conversation_id that
+// participant cp hasn't read, leaving out those from users cp has blocked
+const unreadMessages = `(
+	SELECT COUNT(*) FROM messages um
+	WHERE um.conversation_id = cp.conversation_id AND um.sender_id IS DISTINCT FROM cp.user_id
+		AND um.created_at > COALESCE(cp.last_read_at, '-infinity')
+		AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = cp.user_id AND blocked_id = um.sender_id))`
+
+// conversationColumns returns the columns read by scanConversation, from conversations c
+// and the viewer's conversation_participants cp, with the latest message joined as lm
+var conversationColumns = fmt.Sprintf(`
+	c.id, cs.type, cs.id, cs.name, cp.muted, cp.archived, %s, c.last_message_at, c.created_at,
+	lm.id, lm.conversation_id, lm.sender_id, lm.sender_name, lm.body, lm.read_by, lm.created_at`, unreadMessages)
+
+// conversationFrom joins the tables read by conversationColumns for the viewer bound to
+// $viewerArg. A marker the viewer can no longer see is left out of the subject, and
+// messages from users the viewer has blocked are passed over for the latest message.
+func conversationFrom(viewerArg int) string {
+	return fmt.Sprintf(`
+	FROM conversations c
+	JOIN conversation_participants cp ON cp.conversation_id = c.id AND cp.user_id = $%[1]d
+	LEFT JOIN LATERAL (
+		SELECT 'marker' AS type, um.id, um.name FROM user_markers um
+		WHERE um.id = c.marker_id AND %[2]s
+		UNION ALL
+		SELECT 'listing', l.id, l.title FROM listings l WHERE l.id = c.listing_id
+	) cs ON TRUE
+	LEFT JOIN LATERAL (
+		SELECT %[3]s
+		FROM messages m
+		LEFT JOIN users u ON m.sender_id = u.id
+		LEFT JOIN user_bios ub ON m.sender_id = ub.user_id
+		WHERE m.conversation_id = c.id AND %[4]s
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT 1
+	) lm ON TRUE`, viewerArg, markerVisibleTo(viewerArg), messageColumns, notBlockedBy(viewerArg, "m.sender_id"))
+}
+
+// scanConversation reads a row selected with conversationColumns, followed by any extra columns
+func scanConversation(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.Conversation, error) {
+	var c models.Conversation
+	var subjectType, subjectID, subjectName sql.NullString
+	var lastID, lastConversationID, lastSenderID, lastSenderName, lastBody sql.NullString
+	var lastReadBy []string
+	var lastCreatedAt sql.NullTime
+
+	dest := []interface{}{
+		&c.ID, &subjectType, &subjectID, &subjectName, &c.Muted, &c.Archived, &c.UnreadCount, &c.LastMessageAt, &c.CreatedAt,
+		&lastID, &lastConversationID, &lastSenderID, &lastSenderName, &lastBody, pq.Array(&lastReadBy), &lastCreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return c, err
+	}
+
+	c.Participants = []models.ConversationParticipant{}
+	if subjectType.Valid {
+		c.Subject = &models.ConversationSubject{Type: subjectType.String, ID: subjectID.String, Name: subjectName.String}
+	}
+	if lastID.Valid {
+		c.LastMessage = &models.Message{
+			ID:             lastID.String,
+			ConversationID: lastConversationID.String,
+			SenderName:     lastSenderName.String,
+			Body:           lastBody.String,
+			ReadBy:         lastReadBy,
+			CreatedAt:      lastCreatedAt.Time,
+		}
+		if lastSenderID.Valid {
+			c.LastMessage.SenderID = &lastSenderID.String
+		}
+		if c.LastMessage.ReadBy == nil {
+			c.LastMessage.ReadBy = []string{}
+		}
+	}
+	return c, nil
+}
+
+// loadConversationParticipants fills in the participants of each conversation
+func loadConversationParticipants(db *sql.DB, conversations []models.Conversation) error {
+	if len(conversations) == 0 {
+		return nil
+	}
+
+	index := map[string]int{}
+	ids := make([]string, len(conversations))
+	for i, c := range conversations {
+		index[c.ID] = i
+		ids[i] = c.ID
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT cp.conversation_id, CASE WHEN u.is_deleted THEN NULL ELSE cp.user_id END, u.is_deleted,
+			CASE WHEN u.is_deleted THEN '%s' ELSE ub.display_name END,
+			CASE WHEN u.is_deleted THEN NULL ELSE ub.profile_image END,
+			cp.last_read_at
+		FROM conversation_participants cp
+		JOIN users u ON cp.user_id = u.id
+		LEFT JOIN user_bios ub ON cp.user_id = ub.user_id
+		WHERE cp.conversation_id = ANY($1::uuid[])
+		ORDER BY cp.conversation_id, cp.joined_at, cp.user_id
+	`, deletedUserName), pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var conversationID string
+		var p models.ConversationParticipant
+		var displayName, profileImage sql.NullString
+		var lastReadAt sql.NullTime
+
+		if err := rows.Scan(&conversationID, &p.ID, &p.Deleted, &displayName, &profileImage, &lastReadAt); err != nil {
+			return err
+		}
+		p.DisplayName = displayName.String
+		if profileImage.Valid {
+			p.ProfileImage = &profileImage.String
+		}
+		if lastReadAt.Valid {
+			p.LastReadAt = &lastReadAt.Time
+		}
+
+		c := &conversations[index[conversationID]]
+		c.Participants = append(c.Participants, p)
+	}
+	return rows.Err()
+}
+
+// sendMessage adds a message from senderID to a conversation, brings it back to the inbox
+// of anyone who archived it and notifies those who aren't muting it or the sender. Recipients
+// with unread messages there already have been told, so are not notified again.
+func sendMessage(tx *sql.Tx, conversationID string, senderID uuid.UUID, body string) (string, error) {
+	var messageID string
+	var sentAt time.Time
+	err := tx.QueryRow(`
+		INSERT INTO messages (conversation_id, sender_id, body)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, conversationID, senderID, body).Scan(&messageID, &sentAt)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec("UPDATE conversations SET last_message_at = $1 WHERE id = $2", sentAt, conversationID)
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := tx.Query(`
+		SELECT cp.user_id
+		FROM conversation_participants cp
+		JOIN users u ON cp.user_id = u.id
+		WHERE cp.conversation_id = $1 AND cp.user_id <> $2 AND cp.muted = FALSE AND u.is_deleted = FALSE
+			AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = cp.user_id AND muted_id = $2)
+			AND NOT EXISTS (
+				SELECT 1 FROM messages um
+				WHERE um.conversation_id = cp.conversation_id AND um.id <> $3
+					AND um.sender_id IS DISTINCT FROM cp.user_id
+					AND um.created_at > COALESCE(cp.last_read_at, '-infinity'))
+	`, conversationID, senderID, messageID)
+	if err != nil {
+		return "", err
+	}
+	var recipients []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return "", err
+		}
+		recipients = append(recipients, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	// The sender has read everything up to their own message
+	_, err = tx.Exec(`
+		UPDATE conversation_participants
+		SET archived = CASE WHEN user_id = $2 THEN archived ELSE FALSE END,
+			last_read_at = CASE WHEN user_id = $2 THEN GREATEST(last_read_at, $3) ELSE last_read_at END
+		WHERE conversation_id = $1
+	`, conversationID, senderID, sentAt)
+	if err != nil {
+		return "", err
+	}
+
+	var senderName string
+	err = tx.QueryRow("SELECT display_name FROM user_bios WHERE user_id = $1", senderID).Scan(&senderName)
+	if err != nil {
+		return "", err
+	}
+	for _, recipient := range recipients {
+		err := notify.Send(tx, notify.Notification{
+			UserID: recipient,
+			Type:   notify.TypeNewMessage,
+			Title:  "New message from " + senderName,
+			Body:   messagePreview(body),
+			Link:   "/conversations/" + conversationID,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return messageID, nil
+}
+
+// messagePreview shortens a message body for a notification
+func messagePreview(body string) string {
+	const maxPreview = 140
+	runes := []rune(body)
+	if len(runes) <= maxPreview {
+		return body
+	}
+	return string(runes[:maxPreview-1]) + "…"
+}
+
+// isConversationParticipant reports whether userID takes part in a conversation
+func isConversationParticipant(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, conversationID, userID uuid.UUID) (bool, error) {
+	var member bool
+	err := q.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2)
+	`, conversationID, userID).Scan(&member)
+	return member, err
+}
+
+// CreateConversationHandler starts a conversation between the authenticated user and one
+// or more others, optionally about a marker or listing, with its first message
+func CreateConversationHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req models.CreateConversationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if strings.TrimSpace(req.Body) == "" {
+			http.Error(w, "Message cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		seen := map[string]bool{userID.String(): true}
+		var others []string
+		for _, id := range req.ParticipantIDs {
+			id = strings.ToLower(id)
+			if !seen[id] {
+				seen[id] = true
+				others = append(others, id)
+			}
+		}
+		if len(others) == 0 {
+			http.Error(w, "A conversation needs someone other than you", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var found int
+		err = tx.QueryRow("SELECT COUNT(*) FROM users WHERE id = ANY($1::uuid[]) AND is_deleted = FALSE",
+			pq.Array(others)).Scan(&found)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if found != len(others) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		if blocked, err := blockedBetween(tx, userID, others...); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		} else if blocked {
+			http.Error(w, errMessagingBlocked.Error(), http.StatusForbidden)
+			return
+		}
+
+		if req.MarkerID != nil {
+			var visible bool
+			err := tx.QueryRow(fmt.Sprintf(`
+				SELECT EXISTS (SELECT 1 FROM user_markers um WHERE um.id = $1 AND %s)
+			`, markerVisibleTo(2)), *req.MarkerID, userID).Scan(&visible)
+			if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if !visible {
+				http.Error(w, "Marker not found", http.StatusNotFound)
+				return
+			}
+		}
+		if req.ListingID != nil {
+			var visible bool
+			err := tx.QueryRow(`
+				SELECT EXISTS (SELECT 1 FROM listings WHERE id = $1 AND (status <> 'draft' OR seller_id = $2))
+			`, *req.ListingID, userID).Scan(&visible)
+			if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if !visible {
+				http.Error(w, "Listing not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		var conversationID string
+		err = tx.QueryRow(`
+			INSERT INTO conversations (created_by, marker_id, listing_id)
+			VALUES ($1, $2, $3)
+			RETURNING id
+		`, userID, req.MarkerID, req.ListingID).Scan(&conversationID)
+		if err != nil {
+			log.Printf("Create conversation error: %v", err)
+			http.Error(w, "Error creating conversation", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO conversation_participants (conversation_id, user_id)
+			SELECT $1, unnest($2::uuid[])
+		`, conversationID, pq.Array(append(others, userID.String())))
+		if err != nil {
+			log.Printf("Add conversation participants error: %v", err)
+			http.Error(w, "Error creating conversation", http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := sendMessage(tx, conversationID, userID, req.Body); err != nil {
+			log.Printf("Send message error: %v", err)
+			http.Error(w, "Error creating conversation", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": conversationID})
+	}
+}
+
+// GetConversationsHandler lists the authenticated user's conversations, most recently
+// active first. Archived conversations are listed with ?archived=true instead.
+// Supports ?limit= and ?cursor=
+func GetConversationsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts:       map[string]string{"last_message": "c.last_message_at"},
+			DefaultSort: "-last_message",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{userID, r.URL.Query().Get("archived") == "true"}
+		conditions := []string{"cp.archived = $2"}
+		if clause, cursorArgs := page.Where("c.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, %s
+			%s
+			WHERE %s
+			%s
+		`, conversationColumns, page.SortValue(), conversationFrom(1), strings.Join(conditions, " AND "),
+			page.OrderBy("c.id")), args...)
+		if err != nil {
+			log.Println("Conversations query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var conversations []models.Conversation
+		var keys [][2]string
+		for rows.Next() {
+			var sortKey string
+			c, err := scanConversation(rows, &sortKey)
+			if err != nil {
+				log.Println("Conversation scan error:", err)
+				http.Error(w, "Error scanning conversations", http.StatusInternalServerError)
+				return
+			}
+			conversations = append(conversations, c)
+			keys = append(keys, [2]string{sortKey, c.ID})
+		}
+		rows.Close()
+
+		result := pagination.NewPage(conversations, keys, page)
+		if err := loadConversationParticipants(db, result.Data); err != nil {
+			log.Println("Conversation participants error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// GetConversationHandler returns one of the authenticated user's conversations
+func GetConversationHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		conversationID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+			return
+		}
+
+		c, err := scanConversation(db.QueryRow(fmt.Sprintf(`
+			SELECT %s
+			%s
+			WHERE c.id = $2
+		`, conversationColumns, conversationFrom(1)), userID, conversationID))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("Conversation query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		conversations := []models.Conversation{c}
+		if err := loadConversationParticipants(db, conversations); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(conversations[0])
+	}
+}
+
+// UpdateConversationHandler mutes, unmutes, archives or unarchives a conversation for the
+// authenticated user
+func UpdateConversationHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		conversationID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.UpdateConversationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec(`
+			UPDATE conversation_participants
+			SET muted = COALESCE($1, muted),
+				archived = COALESCE($2, archived)
+			WHERE conversation_id = $3 AND user_id = $4
+		`, req.Muted, req.Archived, conversationID, userID)
+		if err != nil {
+			http.Error(w, "Failed to update conversation", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Conversation updated successfully"})
+	}
+}
+
+// GetMessagesHandler lists the messages of one of the authenticated user's conversations,
+// newest first, leaving out those from users they have blocked. Supports ?limit= and ?cursor=
+func GetMessagesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		conversationID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts:       map[string]string{"created_at": "m.created_at"},
+			DefaultSort: "-created_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		member, err := isConversationParticipant(db, conversationID, userID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !member {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+
+		args := []interface{}{conversationID, userID}
+		conditions := []string{"m.conversation_id = $1", notBlockedBy(2, "m.sender_id")}
+		if clause, cursorArgs := page.Where("m.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, %s
+			FROM messages m
+			LEFT JOIN users u ON m.sender_id = u.id
+			LEFT JOIN user_bios ub ON m.sender_id = ub.user_id
+			WHERE %s
+			%s
+		`, messageColumns, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("m.id")), args...)
+		if err != nil {
+			log.Println("Messages query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var messages []models.Message
+		var keys [][2]string
+		for rows.Next() {
+			var sortKey string
+			m, err := scanMessage(rows, &sortKey)
+			if err != nil {
+				http.Error(w, "Error scanning messages", http.StatusInternalServerError)
+				return
+			}
+			messages = append(messages, m)
+			keys = append(keys, [2]string{sortKey, m.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(messages, keys, page))
+	}
+}
+
+// SendMessageHandler adds a message from the authenticated user to one of their
+// conversations. It is refused while a block stands between them and anyone in it.
+func SendMessageHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		conversationID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.SendMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if strings.TrimSpace(req.Body) == "" {
+			http.Error(w, "Message cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if member, err := isConversationParticipant(tx, conversationID, userID); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		} else if !member {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+
+		var others []string
+		err = tx.QueryRow(`
+			SELECT ARRAY(
+				SELECT user_id FROM conversation_participants WHERE conversation_id = $1 AND user_id <> $2
+			)
+		`, conversationID, userID).Scan(pq.Array(&others))
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if blocked, err := blockedBetween(tx, userID, others...); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		} else if blocked {
+			http.Error(w, errMessagingBlocked.Error(), http.StatusForbidden)
+			return
+		}
+
+		messageID, err := sendMessage(tx, conversationID.String(), userID, req.Body)
+		if err != nil {
+			log.Printf("Send message error: %v", err)
+			http.Error(w, "Error sending message", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": messageID})
+	}
+}
+
+// MarkConversationReadHandler records that the authenticated user has read every message
+// in a conversation so far
+func MarkConversationReadHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		conversationID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+			return
+		}
+
+		// Read up to the latest message rather than NOW(), so a message still being sent
+		// when this runs isn't marked read unseen
+		result, err := db.Exec(`
+			UPDATE conversation_participants cp
+			SET last_read_at = GREATEST(cp.last_read_at,
+				(SELECT MAX(m.created_at) FROM messages m WHERE m.conversation_id = cp.conversation_id))
+			WHERE cp.conversation_id = $1 AND cp.user_id = $2
+		`, conversationID, userID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Conversation marked as read"})
+	}
+}
+
+// GetUnreadMessagesHandler counts the authenticated user's unread messages, leaving out
+// muted conversations
+func GetUnreadMessagesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var unread models.UnreadMessages
+		err := db.QueryRow(fmt.Sprintf(`
+			SELECT COALESCE(SUM(n), 0), COUNT(*) FILTER (WHERE n > 0)
+			FROM (
+				SELECT %s AS n
+				FROM conversation_participants cp
+				WHERE cp.user_id = $1 AND cp.muted = FALSE
+			) counts
+		`, unreadMessages), userID).Scan(&unread.Messages, &unread.Conversations)
+		if err != nil {
+			log.Println("Unread messages query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(unread)
+	}
+}
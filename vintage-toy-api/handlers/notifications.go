@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/notify"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// GetNotificationsHandler lists the authenticated user's in-app notifications, newest
+// first. Supports ?unread=true, ?type=, ?limit= and ?cursor=
+func GetNotificationsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts:       map[string]string{"created_at": "n.created_at"},
+			DefaultSort: "-created_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{userID}
+		conditions := []string{"n.user_id = $1", "n.in_app"}
+		if r.URL.Query().Get("unread") == "true" {
+			conditions = append(conditions, "n.read_at IS NULL")
+		}
+		if t := r.URL.Query().Get("type"); t != "" {
+			if !notify.IsType(t) {
+				http.Error(w, "Invalid notification type", http.StatusBadRequest)
+				return
+			}
+			args = append(args, t)
+			conditions = append(conditions, fmt.Sprintf("n.type = $%d", len(args)))
+		}
+		if clause, cursorArgs := page.Where("n.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT n.id, n.type, n.title, n.body, n.link, n.read_at, n.created_at, %s
+			FROM notifications n
+			WHERE %s
+			%s
+		`, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("n.id")), args...)
+		if err != nil {
+			log.Println("Notifications query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var notifications []models.Notification
+		var keys [][2]string
+		for rows.Next() {
+			var n models.Notification
+			var sortKey string
+			if err := rows.Scan(&n.ID, &n.Type, &n.Title, &n.Body, &n.Link, &n.ReadAt, &n.CreatedAt, &sortKey); err != nil {
+				http.Error(w, "Error scanning notifications", http.StatusInternalServerError)
+				return
+			}
+			notifications = append(notifications, n)
+			keys = append(keys, [2]string{sortKey, n.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(notifications, keys, page))
+	}
+}
+
+// GetUnreadNotificationCountHandler counts the authenticated user's unread in-app notifications
+func GetUnreadNotificationCountHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var unread int
+		err := db.QueryRow(`
+			SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND in_app AND read_at IS NULL
+		`, userID).Scan(&unread)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"unread": unread})
+	}
+}
+
+// MarkNotificationReadHandler marks one of the authenticated user's notifications as read
+func MarkNotificationReadHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		notificationID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec(`
+			UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+			WHERE id = $1 AND user_id = $2 AND in_app
+		`, notificationID, userID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Notification not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Notification marked as read"})
+	}
+}
+
+// MarkAllNotificationsReadHandler marks all the authenticated user's notifications as read
+func MarkAllNotificationsReadHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		_, err := db.Exec("UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL", userID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "All notifications marked as read"})
+	}
+}
+
+// GetNotificationPreferencesHandler lists how every type of notification reaches the
+// authenticated user on every channel, defaults included
+func GetNotificationPreferencesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		rows, err := db.Query("SELECT type, channel, delivery FROM notification_preferences WHERE user_id = $1", userID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		chosen := map[[2]string]string{}
+		for rows.Next() {
+			var t, channel, delivery string
+			if err := rows.Scan(&t, &channel, &delivery); err != nil {
+				http.Error(w, "Error scanning preferences", http.StatusInternalServerError)
+				return
+			}
+			chosen[[2]string{t, channel}] = delivery
+		}
+
+		preferences := []models.NotificationPreference{}
+		for _, t := range notify.Types {
+			for _, channel := range notify.Channels {
+				delivery, ok := chosen[[2]string{t, channel}]
+				if !ok {
+					delivery = notify.DefaultDelivery(t, channel)
+				}
+				preferences = append(preferences, models.NotificationPreference{Type: t, Channel: channel, Delivery: delivery})
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preferences)
+	}
+}
+
+// UpdateNotificationPreferencesHandler sets how some types of notification reach the
+// authenticated user. Preferences not mentioned are left as they are.
+func UpdateNotificationPreferencesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req models.UpdateNotificationPreferencesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var types, channels, deliveries []string
+		for _, p := range req.Preferences {
+			if !notify.IsType(p.Type) {
+				http.Error(w, "Invalid notification type: "+p.Type, http.StatusBadRequest)
+				return
+			}
+			if p.Delivery == notify.DeliveryDaily && p.Channel != notify.ChannelEmail {
+				http.Error(w, "Only email can be delivered as a daily digest", http.StatusBadRequest)
+				return
+			}
+			types = append(types, p.Type)
+			channels = append(channels, p.Channel)
+			deliveries = append(deliveries, p.Delivery)
+		}
+
+		_, err := db.Exec(`
+			INSERT INTO notification_preferences (user_id, type, channel, delivery)
+			SELECT $1, p.type, p.channel, p.delivery
+			FROM unnest($2::text[], $3::text[], $4::text[]) AS p(type, channel, delivery)
+			ON CONFLICT (user_id, type, channel) DO UPDATE
+			SET delivery = EXCLUDED.delivery, updated_at = NOW()
+		`, userID, pq.Array(types), pq.Array(channels), pq.Array(deliveries))
+		if err != nil {
+			log.Printf("Update notification preferences error: %v", err)
+			http.Error(w, "Failed to update preferences", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Preferences updated successfully"})
+	}
+}
+
+// UnsubscribeHandler turns off email for the notification type in a signed ?token=, or for
+// every type when the token is for all of them. It needs no sign-in, so it can be followed
+// straight from an email, and answers POST for one-click unsubscribe from mail clients.
+func UnsubscribeHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, notificationType, err := notify.ParseUnsubscribeToken(r.URL.Query().Get("token"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		types := []string{notificationType}
+		if notificationType == notify.AllTypes {
+			types = notify.Types
+		}
+
+		_, err = db.Exec(`
+			INSERT INTO notification_preferences (user_id, type, channel, delivery)
+			SELECT u.id, t, 'email', 'off'
+			FROM users u, unnest($2::text[]) AS t
+			WHERE u.id = $1
+			ON CONFLICT (user_id, type, channel) DO UPDATE
+			SET delivery = 'off', updated_at = NOW()
+		`, userID, pq.Array(types))
+		if err != nil {
+			log.Printf("Unsubscribe error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		message := "You will no longer receive emails about this"
+		if notificationType == notify.AllTypes {
+			message = "You will no longer receive notification emails"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": message})
+	}
+}
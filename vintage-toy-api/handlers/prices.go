@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/prices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultPriceMonths = 24
+	maxPriceMonths     = 120
+	// minGradeSample is the fewest sales at an entry's own grade for a valuation to use them
+	// rather than the sales of the catalogue item at every grade
+	minGradeSample = 3
+)
+
+// recordListingSale records the price of a sold listing that is linked to the catalogue
+func recordListingSale(tx *sql.Tx, listingID uuid.UUID) error {
+	_, err := tx.Exec(`
+		INSERT INTO price_observations (catalogue_item_id, source, source_id, price_pence, condition_grade, packaging)
+		SELECT catalogue_item_id, 'listing', id, price_pence, condition_grade, packaging
+		FROM listings
+		WHERE id = $1 AND catalogue_item_id IS NOT NULL
+		ON CONFLICT (source, source_id) DO NOTHING
+	`, listingID)
+	return err
+}
+
+// recordTradePrice records the price of a completed trade that was a straight sale: a
+// single catalogue-linked entry given by one party for cash from the other. Cash balancing
+// an exchange of items says nothing reliable about the price of any one of them.
+func recordTradePrice(tx *sql.Tx, offerID uuid.UUID) error {
+	_, err := tx.Exec(`
+		INSERT INTO price_observations (catalogue_item_id, source, source_id, price_pence, condition_grade, packaging)
+		SELECT co.catalogue_item_id, 'trade', t.id, ROUND(ABS(t.cash_pence)::numeric / i.quantity), i.condition_grade,
+			i.packaging
+		FROM trade_offers t
+		JOIN trade_offer_items i ON i.offer_id = t.id
+		JOIN collection_items co ON i.collection_item_id = co.id
+		WHERE t.id = $1
+		  AND t.cash_pence <> 0
+		  AND co.catalogue_item_id IS NOT NULL
+		  AND i.owner_id = CASE WHEN t.cash_pence > 0 THEN t.recipient_id ELSE t.proposer_id END
+		  AND (SELECT COUNT(*) FROM trade_offer_items x WHERE x.offer_id = t.id) = 1
+		ON CONFLICT (source, source_id) DO NOTHING
+	`, offerID)
+	return err
+}
+
+// priceObservation is one recorded sale
+type priceObservation struct {
+	catalogueItemID string
+	price           int
+	grade           sql.NullInt64
+	observedAt      time.Time
+}
+
+// loadPriceObservations returns the sales of the given catalogue items since since, oldest first
+func loadPriceObservations(db *sql.DB, catalogueItemIDs []string, since time.Time) ([]priceObservation, error) {
+	rows, err := db.Query(`
+		SELECT catalogue_item_id, price_pence, condition_grade, observed_at
+		FROM price_observations
+		WHERE catalogue_item_id = ANY($1::uuid[]) AND observed_at >= $2
+		ORDER BY observed_at, id
+	`, pq.Array(catalogueItemIDs), since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var observations []priceObservation
+	for rows.Next() {
+		var o priceObservation
+		if err := rows.Scan(&o.catalogueItemID, &o.price, &o.grade, &o.observedAt); err != nil {
+			return nil, err
+		}
+		observations = append(observations, o)
+	}
+	return observations, rows.Err()
+}
+
+// filterPriceOutliers drops outliers from the sales of a single catalogue item. Each condition
+// grade is judged on its own, since a mint example can fairly sell for many times a worn
+// one; ungraded sales are grade 0. It returns the sales kept and the outliers at each grade.
+func filterPriceOutliers(observations []priceObservation) ([]priceObservation, map[int64]int) {
+	byGrade := map[int64][]int{}
+	for _, o := range observations {
+		byGrade[o.grade.Int64] = append(byGrade[o.grade.Int64], o.price)
+	}
+	type fence struct {
+		low, high float64
+		ok        bool
+	}
+	fences := map[int64]fence{}
+	for grade, values := range byGrade {
+		low, high, ok := prices.Fences(values)
+		fences[grade] = fence{low, high, ok}
+	}
+
+	var kept []priceObservation
+	outliers := map[int64]int{}
+	for _, o := range observations {
+		f := fences[o.grade.Int64]
+		if f.ok && (float64(o.price) < f.low || float64(o.price) > f.high) {
+			outliers[o.grade.Int64]++
+			continue
+		}
+		kept = append(kept, o)
+	}
+	return kept, outliers
+}
+
+// priceStats summarises values, rounding the quartiles to whole pence
+func priceStats(values []int, outliers int) models.PriceStats {
+	s := prices.Summarise(values)
+	stats := models.PriceStats{Count: s.Count, Outliers: outliers, Confidence: s.Confidence}
+	if s.Count > 0 {
+		q1, median, q3 := int(math.Round(s.LowerQuartile)), int(math.Round(s.Median)), int(math.Round(s.UpperQuartile))
+		stats.LowerQuartilePence, stats.MedianPence, stats.UpperQuartilePence = &q1, &median, &q3
+	}
+	return stats
+}
+
+// priceSince reads ?months=, how far back prices are taken into account
+func priceSince(r *http.Request) (time.Time, error) {
+	months := defaultPriceMonths
+	if v := r.URL.Query().Get("months"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPriceMonths {
+			return time.Time{}, fmt.Errorf("Invalid months, expected 1 to %d", maxPriceMonths)
+		}
+		months = n
+	}
+	return time.Now().AddDate(0, -months, 0), nil
+}
+
+// pricePeriod returns the start of the week (from Monday) or month containing t
+func pricePeriod(t time.Time, interval string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if interval == "week" {
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day.AddDate(0, 0, 1-day.Day())
+}
+
+// GetCatalogueItemPricesHandler returns what a catalogue item has sold for: a median per
+// period and the quartiles overall and at each condition grade, with outliers removed.
+// Supports ?months= (default 24) and ?interval=week|month
+func GetCatalogueItemPricesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid catalogue item ID", http.StatusBadRequest)
+			return
+		}
+
+		since, err := priceSince(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		interval := r.URL.Query().Get("interval")
+		if interval == "" {
+			interval = "month"
+		} else if interval != "week" && interval != "month" {
+			http.Error(w, "Invalid interval, expected week or month", http.StatusBadRequest)
+			return
+		}
+
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM catalogue_items WHERE id = $1)", itemID).Scan(&exists); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		} else if !exists {
+			http.Error(w, "Catalogue item not found", http.StatusNotFound)
+			return
+		}
+
+		observations, err := loadPriceObservations(db, []string{itemID.String()}, since)
+		if err != nil {
+			log.Println("Price observations query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		kept, outliers := filterPriceOutliers(observations)
+
+		history := models.PriceHistory{
+			CatalogueItemID: itemID.String(),
+			Since:           since,
+			Interval:        interval,
+			ByCondition:     []models.PriceStats{},
+			Series:          []models.PricePoint{},
+		}
+
+		var all []int
+		byGrade := map[int64][]int{}
+		byPeriod := map[time.Time][]int{}
+		for _, o := range kept {
+			all = append(all, o.price)
+			if o.grade.Valid {
+				byGrade[o.grade.Int64] = append(byGrade[o.grade.Int64], o.price)
+			}
+			period := pricePeriod(o.observedAt, interval)
+			byPeriod[period] = append(byPeriod[period], o.price)
+		}
+
+		totalOutliers := 0
+		for _, n := range outliers {
+			totalOutliers += n
+		}
+		history.Overall = priceStats(all, totalOutliers)
+
+		for grade := int64(1); grade <= 10; grade++ {
+			if len(byGrade[grade]) == 0 && outliers[grade] == 0 {
+				continue
+			}
+			stats := priceStats(byGrade[grade], outliers[grade])
+			g := int(grade)
+			stats.ConditionGrade = &g
+			history.ByCondition = append(history.ByCondition, stats)
+		}
+
+		for period, values := range byPeriod {
+			s := prices.Summarise(values)
+			history.Series = append(history.Series, models.PricePoint{
+				Period:      period,
+				Count:       s.Count,
+				MedianPence: int(math.Round(s.Median)),
+			})
+		}
+		sort.Slice(history.Series, func(i, j int) bool {
+			return history.Series[i].Period.Before(history.Series[j].Period)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+	}
+}
+
+// GetCollectionValuationHandler estimates what each entry in the authenticated user's
+// collection is worth from recent sales of the same catalogue item, and the total.
+// Supports ?months= (default 24)
+func GetCollectionValuationHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		since, err := priceSince(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT co.id, co.catalogue_item_id, %s, co.condition_grade, co.quantity, co.purchase_price_pence
+			%s
+			WHERE co.user_id = $1
+			ORDER BY %[1]s, co.id
+		`, collectionName, collectionFrom), userID)
+		if err != nil {
+			log.Println("Collection valuation query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		valuation := models.CollectionValuation{
+			Confidence: map[string]int{},
+			Items:      []models.CollectionValuationItem{},
+		}
+		var catalogueItemIDs []string
+		seen := map[string]bool{}
+		for rows.Next() {
+			var item models.CollectionValuationItem
+			var grade, spent sql.NullInt64
+			if err := rows.Scan(&item.CollectionItemID, &item.CatalogueItemID, &item.Name, &grade, &item.Quantity, &spent); err != nil {
+				http.Error(w, "Error scanning collection", http.StatusInternalServerError)
+				return
+			}
+			if grade.Valid {
+				g := int(grade.Int64)
+				item.ConditionGrade = &g
+			}
+			valuation.SpentPence += int(spent.Int64)
+			if item.CatalogueItemID != nil && !seen[*item.CatalogueItemID] {
+				seen[*item.CatalogueItemID] = true
+				catalogueItemIDs = append(catalogueItemIDs, *item.CatalogueItemID)
+			}
+			valuation.Items = append(valuation.Items, item)
+		}
+		rows.Close()
+
+		observations, err := loadPriceObservations(db, catalogueItemIDs, since)
+		if err != nil {
+			log.Println("Price observations query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		byItem := map[string][]priceObservation{}
+		for _, o := range observations {
+			byItem[o.catalogueItemID] = append(byItem[o.catalogueItemID], o)
+		}
+
+		// Prices at each grade, and at any grade, of every catalogue item after removing outliers
+		type itemPrices struct {
+			all     []int
+			byGrade map[int64][]int
+		}
+		known := map[string]itemPrices{}
+		for id, obs := range byItem {
+			kept, _ := filterPriceOutliers(obs)
+			p := itemPrices{byGrade: map[int64][]int{}}
+			for _, o := range kept {
+				p.all = append(p.all, o.price)
+				if o.grade.Valid {
+					p.byGrade[o.grade.Int64] = append(p.byGrade[o.grade.Int64], o.price)
+				}
+			}
+			known[id] = p
+		}
+
+		for i := range valuation.Items {
+			item := &valuation.Items[i]
+			var values []int
+			if item.CatalogueItemID != nil {
+				p := known[*item.CatalogueItemID]
+				values = p.all
+				if item.ConditionGrade != nil && len(p.byGrade[int64(*item.ConditionGrade)]) >= minGradeSample {
+					values = p.byGrade[int64(*item.ConditionGrade)]
+					item.MatchedCondition = true
+				}
+			}
+
+			s := prices.Summarise(values)
+			item.SampleSize, item.Confidence = s.Count, s.Confidence
+			if s.Count == 0 {
+				valuation.Unvalued++
+				continue
+			}
+			unit := int(math.Round(s.Median))
+			total := unit * item.Quantity
+			item.UnitPence, item.TotalPence = &unit, &total
+			valuation.TotalPence += total
+			valuation.Valued++
+			valuation.Confidence[s.Confidence]++
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(valuation)
+	}
+}
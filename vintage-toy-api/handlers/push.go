@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/webpush"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// VAPIDPublicKey is the key browsers subscribe with, set at startup. Empty when push is
+// unavailable.
+var VAPIDPublicKey string
+
+// GetVAPIDPublicKeyHandler returns the applicationServerKey for pushManager.subscribe
+func GetVAPIDPublicKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if VAPIDPublicKey == "" {
+			http.Error(w, "Push notifications are unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"public_key": VAPIDPublicKey})
+	}
+}
+
+// CreatePushSubscriptionHandler registers one of the authenticated user's browsers for push
+// notifications. Subscribing again from the same browser updates its keys, and moves it to
+// this user if someone else had signed in on it.
+func CreatePushSubscriptionHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req models.PushSubscriptionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := webpush.ValidateKeys(req.Keys.P256dh, req.Keys.Auth); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var userAgent *string
+		if ua := r.UserAgent(); ua != "" {
+			userAgent = &ua
+		}
+
+		var id string
+		err := db.QueryRow(`
+			INSERT INTO push_subscriptions (user_id, endpoint, p256dh, auth, user_agent)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (endpoint) DO UPDATE
+			SET user_id = EXCLUDED.user_id, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth,
+				user_agent = EXCLUDED.user_agent
+			RETURNING id
+		`, userID, req.Endpoint, req.Keys.P256dh, req.Keys.Auth, userAgent).Scan(&id)
+		if err != nil {
+			log.Printf("Create push subscription error: %v", err)
+			http.Error(w, "Failed to save push subscription", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	}
+}
+
+// GetPushSubscriptionsHandler lists the authenticated user's push-enabled browsers
+func GetPushSubscriptionsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT id, endpoint, user_agent, created_at, last_used_at
+			FROM push_subscriptions
+			WHERE user_id = $1
+			ORDER BY created_at DESC
+		`, userID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		subscriptions := []models.PushSubscription{}
+		for rows.Next() {
+			var s models.PushSubscription
+			if err := rows.Scan(&s.ID, &s.Endpoint, &s.UserAgent, &s.CreatedAt, &s.LastUsedAt); err != nil {
+				http.Error(w, "Error scanning push subscriptions", http.StatusInternalServerError)
+				return
+			}
+			subscriptions = append(subscriptions, s)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(subscriptions)
+	}
+}
+
+// DeletePushSubscriptionHandler stops push notifications to one of the authenticated
+// user's browsers
+func DeletePushSubscriptionHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		subscriptionID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM push_subscriptions WHERE id = $1 AND user_id = $2", subscriptionID, userID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Push subscription not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Push subscription deleted successfully"})
+	}
+}
@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/activity"
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// GetMarkerReviewsHandler lists the reviews of a marker the caller can see.
+// Supports ?rating=, ?sort=created_at|rating, ?limit= and ?cursor=
+func GetMarkerReviewsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		var exists bool
+		err = db.QueryRow(fmt.Sprintf(`
+			SELECT EXISTS (
+				SELECT 1 FROM user_markers um
+				JOIN users u ON um.user_id = u.id
+				WHERE um.id = $1 AND u.is_deleted = FALSE AND %s
+			)
+		`, markerVisibleTo(2)), markerID, middleware.ViewerID(r)).Scan(&exists)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Marker not found", http.StatusNotFound)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts: map[string]string{
+				"created_at": "mr.created_at",
+				"rating":     "mr.rating",
+			},
+			DefaultSort: "-created_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{markerID, middleware.ViewerID(r)}
+		conditions := []string{"mr.marker_id = $1", "u.is_deleted = FALSE", notHiddenFrom(2, "mr.user_id")}
+		if rating := r.URL.Query().Get("rating"); rating != "" {
+			v, err := strconv.Atoi(rating)
+			if err != nil || v < 1 || v > 5 {
+				http.Error(w, "Invalid rating, expected 1 to 5", http.StatusBadRequest)
+				return
+			}
+			args = append(args, v)
+			conditions = append(conditions, fmt.Sprintf("mr.rating = $%d", len(args)))
+		}
+		if clause, cursorArgs := page.Where("mr.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT mr.id, mr.marker_id, mr.rating, mr.body, mr.reply, mr.replied_at, mr.created_at, mr.updated_at,
+				ub.display_name, ub.store_name, u.first_name, u.last_name, ub.show_real_name, ub.profile_image, %s
+			FROM marker_reviews mr
+			JOIN users u ON mr.user_id = u.id
+			LEFT JOIN user_bios ub ON u.id = ub.user_id
+			WHERE %s
+			%s
+		`, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("mr.id")), args...)
+		if err != nil {
+			log.Println("Reviews query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var reviews []models.ReviewResponse
+		var keys [][2]string
+		for rows.Next() {
+			var review models.ReviewResponse
+			var reply, displayName, firstName, lastName, profileImage sql.NullString
+			var repliedAt sql.NullTime
+			var showRealName sql.NullBool
+			var sortKey string
+
+			err := rows.Scan(&review.ID, &review.MarkerID, &review.Rating, &review.Body, &reply, &repliedAt,
+				&review.CreatedAt, &review.UpdatedAt, &displayName, &review.User.StoreName, &firstName, &lastName,
+				&showRealName, &profileImage, &sortKey)
+			if err != nil {
+				http.Error(w, "Error scanning reviews", http.StatusInternalServerError)
+				return
+			}
+
+			review.User.DisplayName = displayName.String
+			if showRealName.Bool {
+				if firstName.Valid {
+					review.User.FirstName = &firstName.String
+				}
+				if lastName.Valid {
+					review.User.LastName = &lastName.String
+				}
+			}
+			if profileImage.Valid {
+				review.User.ProfileImage = &profileImage.String
+			}
+			if reply.Valid {
+				review.Reply = &models.ReviewReply{Body: reply.String, RepliedAt: repliedAt.Time}
+			}
+
+			reviews = append(reviews, review)
+			keys = append(keys, [2]string{sortKey, review.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(reviews, keys, page))
+	}
+}
+
+// ReviewMarkerHandler creates or edits the authenticated user's review of a marker
+func ReviewMarkerHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.ReviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var markerType, markerName string
+		var ownerID uuid.UUID
+		err = db.QueryRow(fmt.Sprintf(`
+			SELECT um.marker_type, um.name, um.user_id
+			FROM user_markers um
+			JOIN users u ON um.user_id = u.id
+			WHERE um.id = $1 AND u.is_deleted = FALSE AND %s
+		`, markerVisibleTo(2)), markerID, userID).Scan(&markerType, &markerName, &ownerID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Marker not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !models.ReviewableMarkerTypes[markerType] {
+			http.Error(w, "Only Shop and Trade Meetup markers can be reviewed", http.StatusBadRequest)
+			return
+		}
+		if ownerID == userID {
+			http.Error(w, "You cannot review your own marker", http.StatusForbidden)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var reviewID string
+		var inserted bool
+		err = tx.QueryRow(`
+			INSERT INTO marker_reviews (marker_id, user_id, rating, body)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (marker_id, user_id) DO UPDATE
+			SET rating = EXCLUDED.rating,
+				body = EXCLUDED.body,
+				updated_at = NOW()
+			RETURNING id, (xmax = 0)
+		`, markerID, userID, req.Rating, req.Body).Scan(&reviewID, &inserted)
+		if err != nil {
+			log.Printf("Save review error: %v", err)
+			http.Error(w, "Failed to save review", http.StatusInternalServerError)
+			return
+		}
+
+		// Followers hear about new reviews, not every edit
+		if inserted {
+			err := activity.Record(tx, activity.Event{
+				ActorID:   userID,
+				Type:      activity.TypeReviewPosted,
+				SubjectID: reviewID,
+				Details:   map[string]interface{}{"marker_id": markerID, "marker_name": markerName, "rating": req.Rating},
+			})
+			if err != nil {
+				log.Printf("Record activity error: %v", err)
+				http.Error(w, "Failed to save review", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if inserted {
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Review added"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "Review updated"})
+	}
+}
+
+// DeleteReviewHandler removes the authenticated user's review of a marker
+func DeleteReviewHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM marker_reviews WHERE marker_id = $1 AND user_id = $2", markerID, userID)
+		if err != nil {
+			http.Error(w, "Error deleting review", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Review not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Review deleted successfully"})
+	}
+}
+
+// ReplyToReviewHandler sets the marker owner's public reply to a review of their marker
+func ReplyToReviewHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		reviewID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid review ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.ReviewReplyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Replying again edits the existing reply rather than adding another
+		result, err := db.Exec(`
+			UPDATE marker_reviews mr
+			SET reply = $1, replied_at = NOW()
+			FROM user_markers um
+			WHERE mr.id = $2 AND mr.marker_id = um.id AND um.user_id = $3
+		`, req.Body, reviewID, userID)
+		if err != nil {
+			http.Error(w, "Failed to save reply", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Review not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Reply saved"})
+	}
+}
+
+// DeleteReviewReplyHandler removes the marker owner's reply to a review
+func DeleteReviewReplyHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		reviewID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid review ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec(`
+			UPDATE marker_reviews mr
+			SET reply = NULL, replied_at = NULL
+			FROM user_markers um
+			WHERE mr.id = $1 AND mr.marker_id = um.id AND um.user_id = $2 AND mr.reply IS NOT NULL
+		`, reviewID, userID)
+		if err != nil {
+			http.Error(w, "Error deleting reply", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Reply not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Reply deleted successfully"})
+	}
+}
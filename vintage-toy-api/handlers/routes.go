@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/gpx"
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/routing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultRouteSpeedKmh = 40
+	defaultRouteDwell    = 30 * time.Minute
+	// routeHorizon is how far past departure opening hours and events are considered
+	routeHorizon = 48 * time.Hour
+)
+
+// shopWindows returns the opening periods of each shop between from and to, keyed by marker ID
+func shopWindows(db *sql.DB, markerIDs []string, from, to time.Time) (map[string][]routing.Window, error) {
+	windows := map[string][]routing.Window{}
+	if len(markerIDs) == 0 {
+		return windows, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT s.marker_id,
+			(d::date + h.opens_at) AT TIME ZONE 'Europe/London',
+			(d::date + h.closes_at) AT TIME ZONE 'Europe/London'
+		FROM unnest($1::uuid[]) AS s(marker_id)
+		CROSS JOIN generate_series(
+			($2::timestamptz AT TIME ZONE 'Europe/London')::date,
+			($3::timestamptz AT TIME ZONE 'Europe/London')::date,
+			INTERVAL '1 day'
+		) d
+		CROSS JOIN LATERAL shop_hours_on(s.marker_id, d::date) h
+		ORDER BY 1, 2
+	`, pq.Array(markerIDs), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var w routing.Window
+		if err := rows.Scan(&id, &w.Start, &w.End); err != nil {
+			return nil, err
+		}
+		windows[id] = append(windows[id], w)
+	}
+	return windows, rows.Err()
+}
+
+// routeMarkerIDs returns the stops requested directly, followed by the markers of the caller's list if one is given
+func routeMarkerIDs(db *sql.DB, req models.PlanRouteRequest, userID uuid.UUID) ([]string, error) {
+	if req.ListID == nil {
+		return req.MarkerIDs, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT li.marker_id
+		FROM marker_list_items li
+		JOIN marker_lists ml ON li.list_id = ml.id
+		WHERE ml.id = $1 AND ml.user_id = $2
+		ORDER BY li.position
+	`, *req.ListID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := append([]string{}, req.MarkerIDs...)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PlanRouteHandler orders a set of markers into a trip from a start point, taking shop
+// opening hours and event times into account. Everything is computed locally on
+// great-circle distances. Supports ?format=gpx
+func PlanRouteHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req models.PlanRouteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		requested, err := routeMarkerIDs(db, req, userID)
+		if err != nil {
+			log.Println("Route list query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		seen := map[string]bool{}
+		var markerIDs []string
+		for _, id := range requested {
+			id = strings.ToLower(id)
+			if !seen[id] {
+				seen[id] = true
+				markerIDs = append(markerIDs, id)
+			}
+		}
+		if len(markerIDs) == 0 {
+			http.Error(w, "A route needs at least one marker", http.StatusBadRequest)
+			return
+		}
+		if len(markerIDs) > models.MaxRouteStops {
+			http.Error(w, fmt.Sprintf("A route can visit at most %d markers", models.MaxRouteStops), http.StatusBadRequest)
+			return
+		}
+
+		opts := routing.Options{
+			Closed:   req.ReturnToStart,
+			Depart:   time.Now().Truncate(time.Minute),
+			SpeedKmh: defaultRouteSpeedKmh,
+			Dwell:    defaultRouteDwell,
+		}
+		if req.DepartAt != nil {
+			opts.Depart = *req.DepartAt
+		}
+		if req.SpeedKmh != nil {
+			opts.SpeedKmh = *req.SpeedKmh
+		}
+		if req.DwellMinutes != nil {
+			opts.Dwell = time.Duration(*req.DwellMinutes) * time.Minute
+		}
+		horizon := opts.Depart.Add(routeHorizon)
+
+		// Other people's markers are placed at their public location, as everywhere else
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s
+			%s
+			WHERE um.id = ANY($2::uuid[]) AND u.is_deleted = FALSE AND %s
+		`, markerColumns(1), markerFrom, markerVisibleTo(1)), userID, pq.Array(markerIDs))
+		if err != nil {
+			log.Println("Route markers query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		byID := map[string]models.MarkerResponse{}
+		for rows.Next() {
+			marker, err := scanMarker(rows)
+			if err != nil {
+				log.Println("Row scan error:", err)
+				http.Error(w, "Database scan error", http.StatusInternalServerError)
+				return
+			}
+			byID[marker.ID] = marker
+		}
+		if len(byID) != len(markerIDs) {
+			http.Error(w, "One or more markers not found", http.StatusNotFound)
+			return
+		}
+
+		// Shops without any hours set are treated as always open
+		var shopIDs []string
+		for _, m := range byID {
+			if m.OpenStatus != nil && *m.OpenStatus != "unknown" {
+				shopIDs = append(shopIDs, m.ID)
+			}
+		}
+		openings, err := shopWindows(db, shopIDs, opts.Depart, horizon)
+		if err != nil {
+			log.Println("Route opening hours query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		markers := make([]models.MarkerResponse, len(markerIDs))
+		stops := make([]routing.Stop, len(markerIDs))
+		for i, id := range markerIDs {
+			m := byID[id]
+			markers[i] = m
+			stops[i].Point = routing.Point{Lat: m.Latitude, Lng: m.Longitude}
+
+			switch {
+			case m.OpenStatus != nil && *m.OpenStatus != "unknown":
+				stops[i].Windows = append([]routing.Window{}, openings[id]...)
+			case m.Schedule != nil:
+				schedule, err := scheduleFromMarker(m.Schedule)
+				if err != nil {
+					log.Printf("Skipping invalid schedule on marker %s: %v", m.ID, err)
+					continue
+				}
+				stops[i].Windows = []routing.Window{}
+				for _, o := range schedule.Between(opts.Depart, horizon) {
+					stops[i].Windows = append(stops[i].Windows, routing.Window{Start: o.Start, End: o.End})
+				}
+			}
+		}
+
+		start := routing.Point{Lat: req.StartLatitude, Lng: req.StartLongitude}
+		plan := routing.Solve(start, stops, opts)
+
+		result := models.RoutePlan{
+			StartLatitude:  req.StartLatitude,
+			StartLongitude: req.StartLongitude,
+			ReturnToStart:  req.ReturnToStart,
+			DepartAt:       opts.Depart,
+			FinishAt:       plan.Finish,
+			TotalDistanceM: plan.TotalM,
+			Stops:          make([]models.RouteStop, 0, len(plan.Visits)),
+		}
+		if req.ReturnToStart {
+			result.ReturnLegDistanceM = &plan.ReturnLegM
+		}
+		for i, v := range plan.Visits {
+			m := markers[v.Stop]
+			result.Stops = append(result.Stops, models.RouteStop{
+				Position:     i + 1,
+				MarkerID:     m.ID,
+				Name:         m.Name,
+				MarkerType:   m.MarkerType,
+				Latitude:     m.Latitude,
+				Longitude:    m.Longitude,
+				LegDistanceM: v.LegM,
+				ArriveAt:     v.Arrive,
+				WaitMinutes:  int(v.Start.Sub(v.Arrive).Minutes()),
+				DepartAt:     v.Leave,
+				WithinHours:  v.OnTime,
+			})
+		}
+
+		if r.URL.Query().Get("format") == "gpx" {
+			writeRouteGPX(w, result)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// writeRouteGPX encodes a planned route as GPX, with each stop as both a waypoint and a route point
+func writeRouteGPX(w http.ResponseWriter, plan models.RoutePlan) {
+	london, err := time.LoadLocation(defaultEventTimezone)
+	if err != nil {
+		london = time.UTC
+	}
+
+	doc := gpx.New("Toy trip")
+	startPoint := gpx.Waypoint{Lat: plan.StartLatitude, Lon: plan.StartLongitude, Name: "Start"}
+	route := gpx.Route{Name: "Toy trip", Points: []gpx.Waypoint{startPoint}}
+
+	for _, s := range plan.Stops {
+		wpt := gpx.Waypoint{
+			Lat:  s.Latitude,
+			Lon:  s.Longitude,
+			Name: fmt.Sprintf("%d. %s", s.Position, s.Name),
+			Desc: fmt.Sprintf("%s, arrive %s", s.MarkerType, s.ArriveAt.In(london).Format("Mon 15:04")),
+		}
+		doc.Waypoints = append(doc.Waypoints, wpt)
+		route.Points = append(route.Points, wpt)
+	}
+	if plan.ReturnToStart {
+		route.Points = append(route.Points, startPoint)
+	}
+	doc.Routes = []gpx.Route{route}
+
+	w.Header().Set("Content-Type", "application/gpx+xml")
+	w.Header().Set("Content-Disposition", `attachment; filename="route.gpx"`)
+	if err := doc.Write(w); err != nil {
+		log.Printf("Write GPX error: %v", err)
+	}
+}
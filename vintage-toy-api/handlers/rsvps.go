@@ -0,0 +1,527 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/calendar"
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/notify"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// eventSchedule loads a marker's stored schedule. ok is false when it has none.
+func eventSchedule(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, markerID uuid.UUID) (schedule calendar.Schedule, ok bool, err error) {
+	var startsAt, endsAt time.Time
+	var timezone string
+	var rrule sql.NullString
+	var exdates []string
+	err = q.QueryRow("SELECT starts_at, ends_at, timezone, rrule, exdates FROM marker_events WHERE marker_id = $1", markerID).
+		Scan(&startsAt, &endsAt, &timezone, &rrule, pq.Array(&exdates))
+	if err == sql.ErrNoRows {
+		return schedule, false, nil
+	} else if err != nil {
+		return schedule, false, err
+	}
+	for i := range exdates {
+		exdates[i] = strings.Replace(exdates[i], " ", "T", 1)
+	}
+
+	var rule *string
+	if rrule.Valid {
+		rule = &rrule.String
+	}
+	schedule, err = calendar.NewSchedule(startsAt.Format(calendar.LocalTimeLayout), endsAt.Format(calendar.LocalTimeLayout),
+		timezone, rule, exdates)
+	return schedule, err == nil, err
+}
+
+// requestedOccurrence resolves the occurrence an RSVP request is about from its
+// occurrence_start, an RFC 3339 time. It may be left out for a one-off event.
+func requestedOccurrence(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, markerID uuid.UUID, value string) (calendar.Occurrence, int, error) {
+	schedule, ok, err := eventSchedule(q, markerID)
+	if err != nil {
+		log.Printf("Event schedule lookup error: %v", err)
+		return calendar.Occurrence{}, http.StatusInternalServerError, errors.New("Database error")
+	}
+	if !ok {
+		return calendar.Occurrence{}, http.StatusBadRequest, errors.New("This event has no schedule")
+	}
+
+	if value == "" {
+		if schedule.Rule != nil {
+			return calendar.Occurrence{}, http.StatusBadRequest, errors.New("occurrence_start is required for a recurring event")
+		}
+		return calendar.Occurrence{Start: schedule.Start, End: schedule.End}, 0, nil
+	}
+
+	start, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return calendar.Occurrence{}, http.StatusBadRequest, errors.New("Invalid occurrence_start")
+	}
+	for _, o := range schedule.Between(start, start.Add(time.Nanosecond)) {
+		if o.Start.Equal(start) {
+			return o, 0, nil
+		}
+	}
+	return calendar.Occurrence{}, http.StatusBadRequest, errors.New("The event has no occurrence starting at occurrence_start")
+}
+
+// goingCount counts confirmed attendees of one occurrence of an event
+func goingCount(tx *sql.Tx, markerID uuid.UUID, occurrence time.Time) (int, error) {
+	var n int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM event_rsvps
+		WHERE marker_id = $1 AND occurrence_starts_at = $2 AND status = 'going'
+	`, markerID, occurrence).Scan(&n)
+	return n, err
+}
+
+// promoteWaitlist moves waitlisted RSVPs for an occurrence to going, oldest first, while it
+// has room, and notifies each promoted user. The caller must hold a lock on the marker row.
+func promoteWaitlist(tx *sql.Tx, markerID uuid.UUID, occurrence time.Time) error {
+	var name string
+	var capacity sql.NullInt64
+	var timezone sql.NullString
+	err := tx.QueryRow(`
+		SELECT um.name, me.capacity, me.timezone
+		FROM user_markers um
+		LEFT JOIN marker_events me ON me.marker_id = um.id
+		WHERE um.id = $1
+	`, markerID).Scan(&name, &capacity, &timezone)
+	if err != nil {
+		return err
+	}
+
+	// A NULL limit promotes everyone once the capacity has been removed
+	var room *int64
+	if capacity.Valid {
+		going, err := goingCount(tx, markerID, occurrence)
+		if err != nil {
+			return err
+		}
+		free := capacity.Int64 - int64(going)
+		if free <= 0 {
+			return nil
+		}
+		room = &free
+	}
+
+	rows, err := tx.Query(`
+		UPDATE event_rsvps
+		SET status = 'going', waitlisted_at = NULL, updated_at = NOW()
+		WHERE marker_id = $1 AND occurrence_starts_at = $2 AND user_id IN (
+			SELECT user_id FROM event_rsvps
+			WHERE marker_id = $1 AND occurrence_starts_at = $2 AND status = 'waitlisted'
+			ORDER BY waitlisted_at
+			LIMIT $3
+		)
+		RETURNING user_id
+	`, markerID, occurrence, room)
+	if err != nil {
+		return err
+	}
+
+	var promoted []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return err
+		}
+		promoted = append(promoted, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if loc, err := time.LoadLocation(timezone.String); timezone.Valid && err == nil {
+		occurrence = occurrence.In(loc)
+	}
+	for _, userID := range promoted {
+		err := notify.Send(tx, notify.Notification{
+			UserID: userID,
+			Type:   notify.TypeWaitlistPromoted,
+			Title:  "You're going to " + name,
+			Body: fmt.Sprintf("A place opened up on %s and you've been moved off the waitlist.",
+				occurrence.Format("Monday 2 January at 15:04 MST")),
+			Link: "/markers/" + markerID.String(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// promoteWaitlists runs promoteWaitlist for every occurrence of an event that has a waitlist
+func promoteWaitlists(tx *sql.Tx, markerID uuid.UUID) error {
+	rows, err := tx.Query(`
+		SELECT DISTINCT occurrence_starts_at FROM event_rsvps
+		WHERE marker_id = $1 AND status = 'waitlisted'
+		ORDER BY occurrence_starts_at
+	`, markerID)
+	if err != nil {
+		return err
+	}
+	var occurrences []time.Time
+	for rows.Next() {
+		var occurrence time.Time
+		if err := rows.Scan(&occurrence); err != nil {
+			rows.Close()
+			return err
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, occurrence := range occurrences {
+		if err := promoteWaitlist(tx, markerID, occurrence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RSVPHandler records the authenticated user's response to one occurrence of an event.
+// Joining a full occurrence puts the user on its waitlist.
+func RSVPHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.RSVPRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		// Locking the marker serialises RSVPs so capacity can't be oversubscribed
+		var markerType string
+		var ownerID string
+		var capacity sql.NullInt64
+		err = tx.QueryRow(fmt.Sprintf(`
+			SELECT um.marker_type, um.user_id, me.capacity
+			FROM user_markers um
+			JOIN users u ON um.user_id = u.id
+			LEFT JOIN marker_events me ON me.marker_id = um.id
+			WHERE um.id = $1 AND u.is_deleted = FALSE AND %s
+			FOR UPDATE OF um
+		`, markerVisibleTo(2)), markerID, userID).Scan(&markerType, &ownerID, &capacity)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Marker not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("RSVP lookup error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if blocked, err := blockedBetween(tx, userID, ownerID); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		} else if blocked {
+			http.Error(w, errBlocked.Error(), http.StatusForbidden)
+			return
+		}
+		if !models.EventMarkerTypes[markerType] {
+			http.Error(w, "Only Event and Trade Meetup markers accept RSVPs", http.StatusBadRequest)
+			return
+		}
+
+		var occurrenceStart string
+		if req.OccurrenceStart != nil {
+			occurrenceStart = *req.OccurrenceStart
+		}
+		occurrence, status, err := requestedOccurrence(tx, markerID, occurrenceStart)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if occurrence.End.Before(time.Now()) {
+			http.Error(w, "This occurrence has ended", http.StatusBadRequest)
+			return
+		}
+
+		var previous string
+		err = tx.QueryRow(`
+			SELECT status FROM event_rsvps
+			WHERE marker_id = $1 AND occurrence_starts_at = $2 AND user_id = $3
+		`, markerID, occurrence.Start, userID).Scan(&previous)
+		if err != nil && err != sql.ErrNoRows {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		going, err := goingCount(tx, markerID, occurrence.Start)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		rsvpStatus := req.Status
+		if rsvpStatus == "going" && previous != "going" && capacity.Valid && int64(going) >= capacity.Int64 {
+			rsvpStatus = "waitlisted"
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO event_rsvps (marker_id, occurrence_starts_at, user_id, status, waitlisted_at)
+			VALUES ($1, $2, $3, $4, CASE WHEN $4 = 'waitlisted' THEN NOW() END)
+			ON CONFLICT (marker_id, occurrence_starts_at, user_id) DO UPDATE
+			SET status = EXCLUDED.status,
+				-- Keep an existing place in the queue
+				waitlisted_at = CASE WHEN EXCLUDED.status = 'waitlisted'
+					THEN COALESCE(event_rsvps.waitlisted_at, EXCLUDED.waitlisted_at) END,
+				updated_at = NOW()
+		`, markerID, occurrence.Start, userID, rsvpStatus)
+		if err != nil {
+			log.Printf("RSVP save error: %v", err)
+			http.Error(w, "Failed to save RSVP", http.StatusInternalServerError)
+			return
+		}
+
+		if previous == "going" && rsvpStatus != "going" {
+			if err := promoteWaitlist(tx, markerID, occurrence.Start); err != nil {
+				log.Printf("Waitlist promotion error: %v", err)
+				http.Error(w, "Failed to save RSVP", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if going, err = goingCount(tx, markerID, occurrence.Start); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Commit failed", http.StatusInternalServerError)
+			return
+		}
+
+		resp := models.RSVPResponse{Status: rsvpStatus, OccurrenceStart: occurrence.Start, GoingCount: going}
+		if capacity.Valid {
+			c := int(capacity.Int64)
+			resp.Capacity = &c
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// CancelRSVPHandler withdraws the authenticated user's RSVP to an occurrence, promoting its
+// waitlist if they were going. Takes ?occurrence_start= as RSVPHandler does.
+func CancelRSVPHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec("SELECT 1 FROM user_markers WHERE id = $1 FOR UPDATE", markerID); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		occurrence, status, err := requestedOccurrence(tx, markerID, r.URL.Query().Get("occurrence_start"))
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		var previous string
+		err = tx.QueryRow(`
+			DELETE FROM event_rsvps
+			WHERE marker_id = $1 AND occurrence_starts_at = $2 AND user_id = $3
+			RETURNING status
+		`, markerID, occurrence.Start, userID).Scan(&previous)
+		if err == sql.ErrNoRows {
+			http.Error(w, "RSVP not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error cancelling RSVP", http.StatusInternalServerError)
+			return
+		}
+
+		if previous == "going" {
+			if err := promoteWaitlist(tx, markerID, occurrence.Start); err != nil {
+				log.Printf("Waitlist promotion error: %v", err)
+				http.Error(w, "Error cancelling RSVP", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Commit failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "RSVP cancelled successfully"})
+	}
+}
+
+// GetAttendeesHandler lists RSVPs for an occurrence of one of the authenticated user's events.
+// Real names are only included for attendees who have show_real_name set.
+// Supports ?occurrence_start=, as RSVPHandler does, and ?status=
+func GetAttendeesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		markerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid marker ID", http.StatusBadRequest)
+			return
+		}
+
+		var exists bool
+		err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM user_markers WHERE id = $1 AND user_id = $2)", markerID, userID).
+			Scan(&exists)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Marker not found", http.StatusNotFound)
+			return
+		}
+
+		occurrence, status, err := requestedOccurrence(db, markerID, r.URL.Query().Get("occurrence_start"))
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts: map[string]string{
+				"responded_at": "er.updated_at",
+				"name":         "ub.display_name",
+			},
+			DefaultSort: "responded_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{markerID, userID, occurrence.Start}
+		conditions := []string{"er.marker_id = $1", "er.occurrence_starts_at = $3", "u.is_deleted = FALSE",
+			notHiddenFrom(2, "er.user_id")}
+		if status := r.URL.Query().Get("status"); status != "" {
+			switch status {
+			case "going", "interested", "not_going", "waitlisted":
+				args = append(args, status)
+				conditions = append(conditions, fmt.Sprintf("er.status = $%d", len(args)))
+			default:
+				http.Error(w, "Invalid status", http.StatusBadRequest)
+				return
+			}
+		}
+		if clause, cursorArgs := page.Where("er.user_id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT er.user_id, ub.display_name, u.first_name, u.last_name, ub.show_real_name, ub.profile_image,
+				er.status, er.updated_at, %s
+			FROM event_rsvps er
+			JOIN users u ON er.user_id = u.id
+			JOIN user_bios ub ON u.id = ub.user_id
+			WHERE %s
+			%s
+		`, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("er.user_id")), args...)
+		if err != nil {
+			log.Println("Attendees query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var attendees []models.Attendee
+		var keys [][2]string
+		for rows.Next() {
+			var a models.Attendee
+			var attendeeID, firstName, lastName, sortKey string
+			var profileImage sql.NullString
+			var showRealName bool
+
+			err := rows.Scan(&attendeeID, &a.DisplayName, &firstName, &lastName, &showRealName, &profileImage,
+				&a.Status, &a.RespondedAt, &sortKey)
+			if err != nil {
+				http.Error(w, "Error scanning attendees", http.StatusInternalServerError)
+				return
+			}
+
+			if showRealName {
+				a.FirstName = &firstName
+				a.LastName = &lastName
+			}
+			if profileImage.Valid {
+				a.ProfileImage = &profileImage.String
+			}
+
+			attendees = append(attendees, a)
+			keys = append(keys, [2]string{sortKey, attendeeID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(attendees, keys, page))
+	}
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+	"github.com/Joseph_Bartram8/vintage-toy-api/suggest"
+	"github.com/Joseph_Bartram8/vintage-toy-api/utils"
+
+	"github.com/google/uuid"
+)
+
+const defaultSearchRadiusKm = 25.0
+
+// Search snippets are highlighted by ts_headline between these control characters, which
+// are stripped from the text first so users can't forge them. highlightSnippet swaps them
+// for <mark> tags once the rest has been escaped.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// headlineOptions are the ts_headline options for search snippets
+const headlineOptions = `'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxWords=20, MinWords=5'`
+
+// snippetSource joins the columns a snippet is drawn from, without any highlight markers
+func snippetSource(columns ...string) string {
+	return fmt.Sprintf("translate(concat_ws(' ', %s), chr(2) || chr(3), '')", strings.Join(columns, ", "))
+}
+
+// highlightSnippet HTML-escapes a ts_headline snippet and marks its matches with <mark>
+func highlightSnippet(snippet string) string {
+	return strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// SearchHandler runs a ranked full-text and trigram search across users, shops and markers.
+// Supports ?type=user|shop|marker, ?region=, ?marker_type= and ?lat=&lng=&radius_km= filters.
+func SearchHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		term := strings.TrimSpace(q.Get("q"))
+		if term == "" {
+			http.Error(w, "Missing search query", http.StatusBadRequest)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts: map[string]string{
+				"relevance": "results.rank",
+				"name":      "results.title",
+			},
+			DefaultSort: "-relevance",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{term, middleware.ViewerID(r)}
+
+		// Marker filters narrow marker hits directly, and user hits to people with a matching marker.
+		// The first condition is always the caller's marker visibility.
+		markerConditions := []string{markerVisibleTo(2)}
+		if region := q.Get("region"); region != "" {
+			if !models.Regions[region] {
+				http.Error(w, "Invalid region", http.StatusBadRequest)
+				return
+			}
+			args = append(args, region)
+			markerConditions = append(markerConditions, fmt.Sprintf("um.region = $%d", len(args)))
+		}
+		if markerType := q.Get("marker_type"); markerType != "" {
+			if !models.MarkerTypes[markerType] {
+				http.Error(w, "Invalid marker type", http.StatusBadRequest)
+				return
+			}
+			args = append(args, markerType)
+			markerConditions = append(markerConditions, fmt.Sprintf("um.marker_type = $%d", len(args)))
+		}
+
+		lat, lng, hasPoint, err := utils.ParsePoint(r, "lat", "lng")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if hasPoint {
+			radiusKm := defaultSearchRadiusKm
+			if rk := q.Get("radius_km"); rk != "" {
+				radiusKm, err = strconv.ParseFloat(rk, 64)
+				if err != nil || radiusKm <= 0 {
+					http.Error(w, "Invalid radius", http.StatusBadRequest)
+					return
+				}
+			}
+			args = append(args, lat, lng, radiusKm*1000)
+			markerConditions = append(markerConditions, fmt.Sprintf("%s <= $%d",
+				utils.DistanceSQL("um.public_latitude", "um.public_longitude", len(args)-2, len(args)-1), len(args)))
+		}
+		markerFilter := strings.Join(markerConditions, " AND ")
+
+		userFilter := notHiddenFrom(2, "u.id")
+		if len(markerConditions) > 1 {
+			userFilter += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM user_markers um WHERE um.user_id = u.id AND %s)", markerFilter)
+		}
+
+		resultConditions := []string{"TRUE"}
+		switch resultType := q.Get("type"); resultType {
+		case "":
+		case "user", "shop", "marker":
+			args = append(args, resultType)
+			resultConditions = append(resultConditions, fmt.Sprintf("results.type = $%d", len(args)))
+		default:
+			http.Error(w, "Invalid result type", http.StatusBadRequest)
+			return
+		}
+
+		if clause, cursorArgs := page.Where("results.id", len(args)+1); clause != "" {
+			resultConditions = append(resultConditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		query := fmt.Sprintf(`
+			WITH search AS (SELECT websearch_to_tsquery('uk_english', $1) AS tsq)
+			SELECT results.type, results.id, results.title, results.subtitle, results.snippet, results.rank,
+				results.profile_image, results.marker_type, results.region, results.latitude, results.longitude, %[8]s
+			FROM (
+				SELECT
+					CASE WHEN COALESCE(ub.store_name, '') <> '' THEN 'shop' ELSE 'user' END AS type,
+					u.id::text AS id, ub.display_name AS title, ub.store_name AS subtitle,
+					ts_headline('uk_english', %[1]s, search.tsq, %[2]s) AS snippet,
+					(ts_rank_cd(ub.search_vector, search.tsq) +
+						GREATEST(similarity(ub.display_name, $1), similarity(COALESCE(ub.store_name, ''), $1)))::float8 AS rank,
+					ub.profile_image, NULL::text AS marker_type, NULL::text AS region,
+					NULL::float8 AS latitude, NULL::float8 AS longitude
+				FROM users u
+				JOIN user_bios ub ON u.id = ub.user_id
+				CROSS JOIN search
+				WHERE u.is_deleted = FALSE
+				  AND (ub.search_vector @@ search.tsq OR ub.display_name %% $1 OR ub.store_name %% $1)
+				  AND %[4]s
+
+				UNION ALL
+
+				SELECT
+					'marker', um.id::text, um.name, ub.display_name,
+					ts_headline('uk_english', %[3]s, search.tsq, %[2]s),
+					(ts_rank_cd(um.search_vector, search.tsq) + similarity(um.name, $1))::float8,
+					ub.profile_image, um.marker_type, um.region, um.public_latitude, um.public_longitude
+				FROM user_markers um
+				JOIN users u ON um.user_id = u.id
+				LEFT JOIN user_bios ub ON u.id = ub.user_id
+				CROSS JOIN search
+				WHERE u.is_deleted = FALSE
+				  AND (um.search_vector @@ search.tsq OR um.name %% $1)
+				  AND %[5]s
+			) results
+			WHERE %[6]s
+			%[7]s
+		`, snippetSource("ub.display_name", "ub.store_name", "ub.bio_description"), headlineOptions,
+			snippetSource("um.name", "um.description"), userFilter, markerFilter,
+			strings.Join(resultConditions, " AND "), page.OrderBy("results.id"), page.SortValue())
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			log.Println("Search query error:", err)
+			http.Error(w, "Database query error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var results []models.SearchResult
+		var keys [][2]string
+		for rows.Next() {
+			var result models.SearchResult
+			var subtitle, profileImage, markerType, region sql.NullString
+			var latitude, longitude sql.NullFloat64
+			var snippet, sortKey string
+
+			err := rows.Scan(
+				&result.Type, &result.ID, &result.Title, &subtitle, &snippet, &result.Rank,
+				&profileImage, &markerType, &region, &latitude, &longitude, &sortKey,
+			)
+			if err != nil {
+				log.Println("Search scan error:", err)
+				http.Error(w, "Error scanning results", http.StatusInternalServerError)
+				return
+			}
+
+			result.Snippet = highlightSnippet(snippet)
+			if subtitle.Valid {
+				result.Subtitle = &subtitle.String
+			}
+			if profileImage.Valid {
+				result.ProfileImage = &profileImage.String
+			}
+			if markerType.Valid {
+				result.MarkerType = &markerType.String
+			}
+			if region.Valid {
+				result.Region = &region.String
+			}
+			if latitude.Valid && longitude.Valid {
+				result.Latitude = &latitude.Float64
+				result.Longitude = &longitude.Float64
+			}
+
+			results = append(results, result)
+			keys = append(keys, [2]string{sortKey, result.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(results, keys, page))
+	}
+}
+
+// suggestBudget is the most time a single autocomplete lookup may spend
+const suggestBudget = 20 * time.Millisecond
+
+// SuggestHandler returns prefix matches from the in-memory suggestion index, leaving out
+// users the caller has blocked or muted
+func SuggestHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		term := strings.TrimSpace(r.URL.Query().Get("q"))
+		if term == "" {
+			http.Error(w, "Missing search query", http.StatusBadRequest)
+			return
+		}
+
+		limit := 8
+		if l := r.URL.Query().Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n < 1 || n > 20 {
+				http.Error(w, "limit must be between 1 and 20", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		var hidden map[string]bool
+		if viewerID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID); ok {
+			var err error
+			if hidden, err = hiddenUserIDs(db, viewerID); err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), suggestBudget)
+		defer cancel()
+
+		suggestions := suggest.Default.Lookup(ctx, term, limit, hidden)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(suggestions, nil, pagination.Params{Limit: limit}))
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/realtime"
+
+	"github.com/google/uuid"
+)
+
+// streamHeartbeat keeps idle streams from being closed by proxies
+const streamHeartbeat = 25 * time.Second
+
+// StreamHandler streams live updates to the authenticated user as Server-Sent Events:
+// markers created, updated or deleted within ?bbox=west,south,east,north, and their own
+// new messages and notifications. Each event is named after its type, and a resync event
+// means updates may have been missed and the client should fetch afresh.
+func StreamHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var bbox *realtime.BBox
+		if b := r.URL.Query().Get("bbox"); b != "" {
+			parsed, err := realtime.ParseBBox(b)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			bbox = &parsed
+		}
+
+		rc := http.NewResponseController(w)
+		// Streams outlive any server-wide write timeout
+		rc.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 5000\n\n")
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		sub := realtime.Default.Subscribe(userID, bbox)
+		defer realtime.Default.Unsubscribe(sub)
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-sub.Events:
+				if !ok {
+					// Dropped for falling behind; the client reconnects and resyncs
+					return
+				}
+				data, err := json.Marshal(e)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
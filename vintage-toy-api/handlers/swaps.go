@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/notify"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// loadSwapDetails fills in the legs and participants of each proposal
+func loadSwapDetails(db *sql.DB, proposals []models.SwapProposal) error {
+	if len(proposals) == 0 {
+		return nil
+	}
+
+	index := map[string]int{}
+	ids := make([]string, len(proposals))
+	for i, p := range proposals {
+		index[p.ID] = i
+		ids[i] = p.ID
+	}
+
+	rows, err := db.Query(`
+		SELECT l.proposal_id, l.collection_item_id, l.name, l.distance_m,
+			l.giver_id, gb.display_name, gb.store_name, gb.profile_image,
+			l.receiver_id, rb.display_name, rb.store_name, rb.profile_image
+		FROM swap_proposal_legs l
+		JOIN user_bios gb ON l.giver_id = gb.user_id
+		JOIN user_bios rb ON l.receiver_id = rb.user_id
+		WHERE l.proposal_id = ANY($1::uuid[])
+		ORDER BY l.proposal_id, l.position
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var proposalID string
+		var leg models.SwapLeg
+		var distance sql.NullFloat64
+		var giverStore, giverImage, receiverStore, receiverImage sql.NullString
+
+		err := rows.Scan(&proposalID, &leg.CollectionItemID, &leg.Name, &distance,
+			&leg.Giver.ID, &leg.Giver.DisplayName, &giverStore, &giverImage,
+			&leg.Receiver.ID, &leg.Receiver.DisplayName, &receiverStore, &receiverImage)
+		if err != nil {
+			return err
+		}
+		if distance.Valid {
+			leg.DistanceM = &distance.Float64
+		}
+		if giverStore.Valid {
+			leg.Giver.StoreName = &giverStore.String
+		}
+		if giverImage.Valid {
+			leg.Giver.ProfileImage = &giverImage.String
+		}
+		if receiverStore.Valid {
+			leg.Receiver.StoreName = &receiverStore.String
+		}
+		if receiverImage.Valid {
+			leg.Receiver.ProfileImage = &receiverImage.String
+		}
+
+		p := &proposals[index[proposalID]]
+		p.Legs = append(p.Legs, leg)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	rows, err = db.Query(`
+		SELECT proposal_id, user_id, response, responded_at
+		FROM swap_proposal_participants
+		WHERE proposal_id = ANY($1::uuid[])
+		ORDER BY proposal_id, user_id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var proposalID string
+		var participant models.SwapParticipant
+		if err := rows.Scan(&proposalID, &participant.UserID, &participant.Response, &participant.RespondedAt); err != nil {
+			return err
+		}
+		p := &proposals[index[proposalID]]
+		p.Participants = append(p.Participants, participant)
+	}
+	return rows.Err()
+}
+
+// GetSwapsHandler lists the swap proposals the authenticated user is part of.
+// Supports ?status=, ?limit= and ?cursor=
+func GetSwapsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts:       map[string]string{"created_at": "sp.created_at"},
+			DefaultSort: "-created_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{userID}
+		conditions := []string{"EXISTS (SELECT 1 FROM swap_proposal_participants pp WHERE pp.proposal_id = sp.id AND pp.user_id = $1)"}
+		if status := r.URL.Query().Get("status"); status != "" {
+			args = append(args, status)
+			conditions = append(conditions, fmt.Sprintf("sp.status = $%d", len(args)))
+		}
+		if clause, cursorArgs := page.Where("sp.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT sp.id, sp.status, sp.expires_at, sp.created_at, sp.updated_at, %s
+			FROM swap_proposals sp
+			WHERE %s
+			%s
+		`, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("sp.id")), args...)
+		if err != nil {
+			log.Println("Swaps query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var proposals []models.SwapProposal
+		var keys [][2]string
+		for rows.Next() {
+			var p models.SwapProposal
+			var sortKey string
+			if err := rows.Scan(&p.ID, &p.Status, &p.ExpiresAt, &p.CreatedAt, &p.UpdatedAt, &sortKey); err != nil {
+				http.Error(w, "Error scanning swap proposals", http.StatusInternalServerError)
+				return
+			}
+			proposals = append(proposals, p)
+			keys = append(keys, [2]string{sortKey, p.ID})
+		}
+		rows.Close()
+
+		if err := loadSwapDetails(db, proposals); err != nil {
+			log.Println("Swap details query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(proposals, keys, page))
+	}
+}
+
+// GetSwapHandler returns a swap proposal the authenticated user is part of
+func GetSwapHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		proposalID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid swap proposal ID", http.StatusBadRequest)
+			return
+		}
+
+		var p models.SwapProposal
+		err = db.QueryRow(`
+			SELECT sp.id, sp.status, sp.expires_at, sp.created_at, sp.updated_at
+			FROM swap_proposals sp
+			JOIN swap_proposal_participants pp ON pp.proposal_id = sp.id
+			WHERE sp.id = $1 AND pp.user_id = $2
+		`, proposalID, userID).Scan(&p.ID, &p.Status, &p.ExpiresAt, &p.CreatedAt, &p.UpdatedAt)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Swap proposal not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		proposals := []models.SwapProposal{p}
+		if err := loadSwapDetails(db, proposals); err != nil {
+			log.Println("Swap details query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(proposals[0])
+	}
+}
+
+// completeSwap moves the item in each leg of an accepted proposal from its giver to its receiver
+func completeSwap(tx *sql.Tx, proposalID uuid.UUID) error {
+	rows, err := tx.Query(`
+		SELECT collection_item_id, giver_id, receiver_id
+		FROM swap_proposal_legs
+		WHERE proposal_id = $1
+		ORDER BY position
+	`, proposalID)
+	if err != nil {
+		return err
+	}
+	type leg struct {
+		item     sql.NullString
+		giver    uuid.UUID
+		receiver uuid.UUID
+	}
+	var legs []leg
+	var ids []string
+	for rows.Next() {
+		var l leg
+		if err := rows.Scan(&l.item, &l.giver, &l.receiver); err != nil {
+			rows.Close()
+			return err
+		}
+		if !l.item.Valid {
+			rows.Close()
+			return errTradeUnavailable
+		}
+		legs = append(legs, l)
+		ids = append(ids, l.item.String)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	current, err := lockCollectionItems(tx, ids)
+	if err != nil {
+		return err
+	}
+	for _, l := range legs {
+		h, ok := current[l.item.String]
+		if !ok || h.owner != l.giver {
+			return errTradeUnavailable
+		}
+		if err := giveCollectionItem(tx, l.item.String, h, l.receiver, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SwapActionHandler accepts, declines or completes a swap proposal for the authenticated
+// participant, as named by action. The proposal is accepted once every participant has
+// accepted it and declined as soon as anyone declines. Completing an accepted proposal
+// moves every item to its receiver in the same transaction. A proposal can only be declined
+// while a block stands between any two of its participants.
+func SwapActionHandler(db *sql.DB, action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		proposalID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid swap proposal ID", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var status, response string
+		err = tx.QueryRow(`
+			SELECT sp.status, pp.response
+			FROM swap_proposals sp
+			JOIN swap_proposal_participants pp ON pp.proposal_id = sp.id
+			WHERE sp.id = $1 AND pp.user_id = $2 AND (sp.status <> 'pending' OR sp.expires_at > NOW())
+			FOR UPDATE OF sp
+		`, proposalID, userID).Scan(&status, &response)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Swap proposal not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if action != "decline" {
+			var blocked bool
+			err := tx.QueryRow(`
+				SELECT EXISTS (
+					SELECT 1 FROM swap_proposal_participants a
+					JOIN swap_proposal_participants b ON b.proposal_id = a.proposal_id
+					JOIN user_blocks ub ON ub.blocker_id = a.user_id AND ub.blocked_id = b.user_id
+					WHERE a.proposal_id = $1
+				)
+			`, proposalID).Scan(&blocked)
+			if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if blocked {
+				http.Error(w, "A block stands between participants of this swap", http.StatusForbidden)
+				return
+			}
+		}
+
+		responses := map[string]string{"accept": "accepted", "decline": "declined"}
+		newStatus := status
+		switch action {
+		case "accept", "decline":
+			if status != "pending" {
+				http.Error(w, fmt.Sprintf("Cannot %s a swap that is %s", action, status), http.StatusConflict)
+				return
+			}
+			_, err = tx.Exec(`
+				UPDATE swap_proposal_participants SET response = $1, responded_at = NOW()
+				WHERE proposal_id = $2 AND user_id = $3
+			`, responses[action], proposalID, userID)
+			if err != nil {
+				break
+			}
+			if action == "decline" {
+				newStatus = "declined"
+				break
+			}
+			var waiting bool
+			err = tx.QueryRow(`
+				SELECT EXISTS (SELECT 1 FROM swap_proposal_participants WHERE proposal_id = $1 AND response <> 'accepted')
+			`, proposalID).Scan(&waiting)
+			if err == nil && !waiting {
+				newStatus = "accepted"
+			}
+		case "complete":
+			if status != "accepted" {
+				http.Error(w, "Only swaps everyone has accepted can be completed", http.StatusConflict)
+				return
+			}
+			err = completeSwap(tx, proposalID)
+			if err == errTradeUnavailable {
+				http.Error(w, "One or more items are no longer available", http.StatusConflict)
+				return
+			}
+			newStatus = "completed"
+		}
+		if err != nil {
+			log.Printf("Swap %s error: %v", action, err)
+			http.Error(w, "Error updating swap proposal", http.StatusInternalServerError)
+			return
+		}
+
+		if newStatus != status {
+			_, err := tx.Exec("UPDATE swap_proposals SET status = $1, updated_at = NOW() WHERE id = $2", newStatus, proposalID)
+			if err == nil {
+				err = notifySwapParticipants(tx, proposalID, userID, "Swap "+newStatus)
+			}
+			if err != nil {
+				log.Printf("Swap %s error: %v", action, err)
+				http.Error(w, "Error updating swap proposal", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": newStatus})
+	}
+}
+
+// notifySwapParticipants tells every participant other than the actor about a change to a proposal
+func notifySwapParticipants(tx *sql.Tx, proposalID, actorID uuid.UUID, title string) error {
+	rows, err := tx.Query("SELECT user_id FROM swap_proposal_participants WHERE proposal_id = $1 AND user_id <> $2",
+		proposalID, actorID)
+	if err != nil {
+		return err
+	}
+	var recipients []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		recipients = append(recipients, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range recipients {
+		err := notify.Send(tx, notify.Notification{
+			UserID: id,
+			Type:   notify.TypeSwapProposal,
+			Title:  title,
+			Link:   "/swaps/" + proposalID.String(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,718 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/notify"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// errTradeUnavailable is returned when an item in an offer no longer belongs to the
+// party giving it up, or they no longer have enough of it
+var errTradeUnavailable = errors.New("trade item unavailable")
+
+// tradeAction describes a change of state of an offer
+type tradeAction struct {
+	from []string // statuses the action can be taken from
+	by   string   // "proposer", "recipient", or empty for either party
+	to   string   // the resulting status, also recorded as the action in the history
+	verb string   // how the action reads in the other party's notification
+}
+
+// tradeActions are the actions taken with TradeActionHandler. Counter-offers have their own handler.
+var tradeActions = map[string]tradeAction{
+	"accept":   {from: []string{"pending"}, by: "recipient", to: "accepted", verb: "accepted"},
+	"decline":  {from: []string{"pending", "accepted"}, by: "recipient", to: "declined", verb: "declined"},
+	"withdraw": {from: []string{"pending", "accepted"}, by: "proposer", to: "withdrawn", verb: "withdrew"},
+	// Either party confirms; the trade completes once both have
+	"complete": {from: []string{"accepted"}, to: "completed", verb: "completed"},
+}
+
+// tradeColumns returns the columns read by scanTradeOffer
+const tradeColumns = `
+	t.id, t.parent_offer_id, t.status, t.cash_pence, t.message, t.created_at, t.updated_at,
+	t.proposer_confirmed_at, t.recipient_confirmed_at,
+	t.proposer_id, pb.display_name, pb.store_name, pb.profile_image,
+	t.recipient_id, rb.display_name, rb.store_name, rb.profile_image`
+
+// tradeFrom joins the tables read by tradeColumns
+const tradeFrom = `
+	FROM trade_offers t
+	JOIN user_bios pb ON t.proposer_id = pb.user_id
+	JOIN user_bios rb ON t.recipient_id = rb.user_id`
+
+// scanTradeOffer reads a row selected with tradeColumns, followed by any extra columns
+func scanTradeOffer(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.TradeOffer, error) {
+	var offer models.TradeOffer
+	var proposerStore, proposerImage, recipientStore, recipientImage sql.NullString
+
+	dest := []interface{}{
+		&offer.ID, &offer.ParentOfferID, &offer.Status, &offer.CashPence, &offer.Message, &offer.CreatedAt, &offer.UpdatedAt,
+		&offer.ProposerConfirmedAt, &offer.RecipientConfirmedAt,
+		&offer.Proposer.ID, &offer.Proposer.DisplayName, &proposerStore, &proposerImage,
+		&offer.Recipient.ID, &offer.Recipient.DisplayName, &recipientStore, &recipientImage,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return offer, err
+	}
+
+	if proposerStore.Valid {
+		offer.Proposer.StoreName = &proposerStore.String
+	}
+	if proposerImage.Valid {
+		offer.Proposer.ProfileImage = &proposerImage.String
+	}
+	if recipientStore.Valid {
+		offer.Recipient.StoreName = &recipientStore.String
+	}
+	if recipientImage.Valid {
+		offer.Recipient.ProfileImage = &recipientImage.String
+	}
+	offer.Give = []models.TradeItem{}
+	offer.Receive = []models.TradeItem{}
+	return offer, nil
+}
+
+// loadTradeItems fills in the items of each offer
+func loadTradeItems(db *sql.DB, offers []models.TradeOffer) error {
+	if len(offers) == 0 {
+		return nil
+	}
+
+	index := map[string]int{}
+	ids := make([]string, len(offers))
+	for i, o := range offers {
+		index[o.ID] = i
+		ids[i] = o.ID
+	}
+
+	rows, err := db.Query(`
+		SELECT offer_id, owner_id, collection_item_id, name, condition_grade, packaging, quantity
+		FROM trade_offer_items
+		WHERE offer_id = ANY($1::uuid[])
+		ORDER BY name, id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var offerID, ownerID string
+		var item models.TradeItem
+		var grade sql.NullInt64
+		err := rows.Scan(&offerID, &ownerID, &item.CollectionItemID, &item.Name, &grade, &item.Packaging, &item.Quantity)
+		if err != nil {
+			return err
+		}
+		if grade.Valid {
+			g := int(grade.Int64)
+			item.ConditionGrade = &g
+		}
+
+		offer := &offers[index[offerID]]
+		if ownerID == offer.Proposer.ID {
+			offer.Give = append(offer.Give, item)
+		} else {
+			offer.Receive = append(offer.Receive, item)
+		}
+	}
+	return rows.Err()
+}
+
+// insertTradeItem copies a collection entry of ownerID into an offer
+func insertTradeItem(tx *sql.Tx, offerID string, ownerID uuid.UUID, item models.TradeItemRequest) error {
+	quantity := 1
+	if item.Quantity != nil {
+		quantity = *item.Quantity
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO trade_offer_items (offer_id, collection_item_id, owner_id, name, condition_grade, packaging, quantity)
+		SELECT $1, co.id, co.user_id, COALESCE(ci.name, co.name), co.condition_grade, co.packaging, $4
+		FROM collection_items co
+		LEFT JOIN catalogue_items ci ON co.catalogue_item_id = ci.id
+		WHERE co.id = $2 AND co.user_id = $3 AND co.quantity >= $4
+	`, offerID, item.CollectionItemID, ownerID, quantity)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errTradeUnavailable
+	}
+	return nil
+}
+
+// insertTradeOffer records a new offer from proposerID to recipientID with its items and
+// opening history entry. It returns the new offer's ID, or an HTTP status and message
+// when the offer can't be made, such as when a block stands between the two.
+func insertTradeOffer(tx *sql.Tx, proposerID, recipientID uuid.UUID, parentID *uuid.UUID, req models.CounterTradeOfferRequest) (string, int, error) {
+	if len(req.Give)+len(req.Receive) == 0 {
+		return "", http.StatusBadRequest, errors.New("A trade needs at least one item")
+	}
+
+	if blocked, err := blockedBetween(tx, proposerID, recipientID.String()); err != nil {
+		return "", http.StatusInternalServerError, errors.New("Database error")
+	} else if blocked {
+		return "", http.StatusForbidden, errBlocked
+	}
+
+	var offerID string
+	err := tx.QueryRow(`
+		INSERT INTO trade_offers (parent_offer_id, proposer_id, recipient_id, cash_pence, message)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, parentID, proposerID, recipientID, req.CashPence, req.Message).Scan(&offerID)
+	if err != nil {
+		log.Printf("Create trade offer error: %v", err)
+		return "", http.StatusInternalServerError, errors.New("Error creating trade offer")
+	}
+
+	for _, side := range []struct {
+		owner uuid.UUID
+		items []models.TradeItemRequest
+	}{{proposerID, req.Give}, {recipientID, req.Receive}} {
+		for _, item := range side.items {
+			err := insertTradeItem(tx, offerID, side.owner, item)
+			switch {
+			case err == errTradeUnavailable:
+				return "", http.StatusBadRequest, fmt.Errorf("Collection item %s is not available in that quantity", item.CollectionItemID)
+			case err != nil && strings.Contains(err.Error(), "duplicate key"):
+				return "", http.StatusBadRequest, errors.New("Each collection item can only be included once")
+			case err != nil:
+				log.Printf("Add trade item error: %v", err)
+				return "", http.StatusInternalServerError, errors.New("Error creating trade offer")
+			}
+		}
+	}
+
+	if err := recordTradeEvent(tx, offerID, proposerID, "proposed", nil, "pending"); err != nil {
+		log.Printf("Record trade history error: %v", err)
+		return "", http.StatusInternalServerError, errors.New("Error creating trade offer")
+	}
+	return offerID, http.StatusCreated, nil
+}
+
+// recordTradeEvent appends to the history of an offer
+func recordTradeEvent(tx *sql.Tx, offerID string, actorID uuid.UUID, action string, from *string, to string) error {
+	_, err := tx.Exec(`
+		INSERT INTO trade_offer_history (offer_id, actor_id, action, from_status, to_status)
+		VALUES ($1, $2, $3, $4, $5)
+	`, offerID, actorID, action, from, to)
+	return err
+}
+
+// transferTradeItems moves every item in a completed offer to the other party
+func transferTradeItems(tx *sql.Tx, offerID string, proposerID, recipientID uuid.UUID) error {
+	rows, err := tx.Query("SELECT collection_item_id, owner_id, quantity FROM trade_offer_items WHERE offer_id = $1", offerID)
+	if err != nil {
+		return err
+	}
+	type moving struct {
+		id       sql.NullString
+		owner    uuid.UUID
+		quantity int
+	}
+	var items []moving
+	var ids []string
+	for rows.Next() {
+		var m moving
+		if err := rows.Scan(&m.id, &m.owner, &m.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		if !m.id.Valid {
+			rows.Close()
+			return errTradeUnavailable
+		}
+		items = append(items, m)
+		ids = append(ids, m.id.String)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	current, err := lockCollectionItems(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range items {
+		h, ok := current[m.id.String]
+		if !ok || h.owner != m.owner || h.quantity < m.quantity {
+			return errTradeUnavailable
+		}
+
+		receiver := recipientID
+		if m.owner == recipientID {
+			receiver = proposerID
+		}
+		if err := giveCollectionItem(tx, m.id.String, h, receiver, m.quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyTrade tells a party about a change to an offer
+func notifyTrade(tx *sql.Tx, userID uuid.UUID, offerID, title string) error {
+	return notify.Send(tx, notify.Notification{
+		UserID: userID,
+		Type:   notify.TypeTradeOffer,
+		Title:  title,
+		Link:   "/trades/" + offerID,
+	})
+}
+
+// GetTradesHandler lists trade offers the authenticated user has sent or received.
+// Supports ?role=sent|received, ?status=, ?sort=created_at|updated_at, ?limit= and ?cursor=
+func GetTradesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts: map[string]string{
+				"created_at": "t.created_at",
+				"updated_at": "t.updated_at",
+			},
+			DefaultSort: "-updated_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{userID}
+		var conditions []string
+		switch r.URL.Query().Get("role") {
+		case "sent":
+			conditions = append(conditions, "t.proposer_id = $1")
+		case "received":
+			conditions = append(conditions, "t.recipient_id = $1")
+		case "":
+			conditions = append(conditions, "(t.proposer_id = $1 OR t.recipient_id = $1)")
+		default:
+			http.Error(w, "Invalid role, expected sent or received", http.StatusBadRequest)
+			return
+		}
+		if status := r.URL.Query().Get("status"); status != "" {
+			args = append(args, status)
+			conditions = append(conditions, fmt.Sprintf("t.status = $%d", len(args)))
+		}
+		if clause, cursorArgs := page.Where("t.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, %s
+			%s
+			WHERE %s
+			%s
+		`, tradeColumns, page.SortValue(), tradeFrom, strings.Join(conditions, " AND "), page.OrderBy("t.id")), args...)
+		if err != nil {
+			log.Println("Trades query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var offers []models.TradeOffer
+		var keys [][2]string
+		for rows.Next() {
+			var sortKey string
+			offer, err := scanTradeOffer(rows, &sortKey)
+			if err != nil {
+				log.Println("Row scan error:", err)
+				http.Error(w, "Error scanning trade offers", http.StatusInternalServerError)
+				return
+			}
+			offers = append(offers, offer)
+			keys = append(keys, [2]string{sortKey, offer.ID})
+		}
+		rows.Close()
+
+		if err := loadTradeItems(db, offers); err != nil {
+			log.Println("Trade items query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(offers, keys, page))
+	}
+}
+
+// GetTradeHandler returns one of the authenticated user's trade offers with its history
+func GetTradeHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		offerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid trade offer ID", http.StatusBadRequest)
+			return
+		}
+
+		offer, err := scanTradeOffer(db.QueryRow(fmt.Sprintf(`
+			SELECT %s
+			%s
+			WHERE t.id = $1 AND (t.proposer_id = $2 OR t.recipient_id = $2)
+		`, tradeColumns, tradeFrom), offerID, userID))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Trade offer not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("Trade query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		offers := []models.TradeOffer{offer}
+		if err := loadTradeItems(db, offers); err != nil {
+			log.Println("Trade items query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		offer = offers[0]
+
+		rows, err := db.Query(`
+			SELECT action, actor_id, from_status, to_status, created_at
+			FROM trade_offer_history
+			WHERE offer_id = $1
+			ORDER BY id
+		`, offerID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var e models.TradeEvent
+			if err := rows.Scan(&e.Action, &e.ActorID, &e.FromStatus, &e.ToStatus, &e.CreatedAt); err != nil {
+				http.Error(w, "Error scanning trade history", http.StatusInternalServerError)
+				return
+			}
+			offer.History = append(offer.History, e)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(offer)
+	}
+}
+
+// CreateTradeOfferHandler proposes a trade of the authenticated user's collection entries
+// for another collector's, with optional cash on either side
+func CreateTradeOfferHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req models.CreateTradeOfferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		recipientID := uuid.MustParse(req.RecipientID)
+		if recipientID == userID {
+			http.Error(w, "You cannot trade with yourself", http.StatusBadRequest)
+			return
+		}
+
+		var exists bool
+		err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND is_deleted = FALSE)", recipientID).Scan(&exists)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Recipient not found", http.StatusNotFound)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		offerID, status, err := insertTradeOffer(tx, userID, recipientID, nil, req.CounterTradeOfferRequest)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		if err := notifyTrade(tx, recipientID, offerID, "You have a new trade offer"); err != nil {
+			log.Printf("Trade notification error: %v", err)
+			http.Error(w, "Error creating trade offer", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": offerID})
+	}
+}
+
+// CounterTradeOfferHandler replies to a pending offer with different terms. The original
+// offer is closed as countered and the new one goes back to its proposer.
+func CounterTradeOfferHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		parentID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid trade offer ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.CounterTradeOfferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var proposerID uuid.UUID
+		var status string
+		err = tx.QueryRow(`
+			SELECT proposer_id, status FROM trade_offers
+			WHERE id = $1 AND recipient_id = $2
+			FOR UPDATE
+		`, parentID, userID).Scan(&proposerID, &status)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Trade offer not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if status != "pending" {
+			http.Error(w, "Only pending offers can be countered, this one is "+status, http.StatusConflict)
+			return
+		}
+
+		_, err = tx.Exec("UPDATE trade_offers SET status = 'countered', updated_at = NOW() WHERE id = $1", parentID)
+		if err == nil {
+			err = recordTradeEvent(tx, parentID.String(), userID, "countered", &status, "countered")
+		}
+		if err != nil {
+			log.Printf("Counter trade offer error: %v", err)
+			http.Error(w, "Error countering trade offer", http.StatusInternalServerError)
+			return
+		}
+
+		offerID, code, err := insertTradeOffer(tx, userID, proposerID, &parentID, req)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		if err := notifyTrade(tx, proposerID, offerID, "Your trade offer has been countered"); err != nil {
+			log.Printf("Trade notification error: %v", err)
+			http.Error(w, "Error countering trade offer", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": offerID})
+	}
+}
+
+// TradeActionHandler accepts, declines, withdraws or completes a trade offer, as named by
+// action. Completing records the caller's confirmation that the trade has been handed over;
+// once both parties have confirmed, every item moves to its new owner in the same transaction.
+// An offer can't be accepted or completed while a block stands between the parties.
+func TradeActionHandler(db *sql.DB, action string) http.HandlerFunc {
+	rule := tradeActions[action]
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		offerID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid trade offer ID", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var proposerID, recipientID uuid.UUID
+		var status, proposerName, recipientName string
+		var proposerConfirmed, recipientConfirmed bool
+		err = tx.QueryRow(`
+			SELECT t.proposer_id, t.recipient_id, t.status, pb.display_name, rb.display_name,
+				t.proposer_confirmed_at IS NOT NULL, t.recipient_confirmed_at IS NOT NULL
+			FROM trade_offers t
+			JOIN user_bios pb ON t.proposer_id = pb.user_id
+			JOIN user_bios rb ON t.recipient_id = rb.user_id
+			WHERE t.id = $1 AND (t.proposer_id = $2 OR t.recipient_id = $2)
+			FOR UPDATE OF t
+		`, offerID, userID).Scan(&proposerID, &recipientID, &status, &proposerName, &recipientName,
+			&proposerConfirmed, &recipientConfirmed)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Trade offer not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		other, actorName := recipientID, proposerName
+		if userID == recipientID {
+			other, actorName = proposerID, recipientName
+		}
+		if (rule.by == "proposer" && userID != proposerID) || (rule.by == "recipient" && userID != recipientID) {
+			http.Error(w, fmt.Sprintf("Only the %s can %s this offer", rule.by, action), http.StatusForbidden)
+			return
+		}
+		allowed := false
+		for _, from := range rule.from {
+			allowed = allowed || status == from
+		}
+		if !allowed {
+			http.Error(w, fmt.Sprintf("Cannot %s an offer that is %s", action, status), http.StatusConflict)
+			return
+		}
+		if rule.to == "accepted" || rule.to == "completed" {
+			if blocked, err := blockedBetween(tx, userID, other.String()); err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			} else if blocked {
+				http.Error(w, errBlocked.Error(), http.StatusForbidden)
+				return
+			}
+		}
+
+		if rule.to == "completed" {
+			confirmed, otherConfirmed, column := proposerConfirmed, recipientConfirmed, "proposer_confirmed_at"
+			if userID == recipientID {
+				confirmed, otherConfirmed, column = recipientConfirmed, proposerConfirmed, "recipient_confirmed_at"
+			}
+			if confirmed {
+				http.Error(w, "You have already confirmed this trade", http.StatusConflict)
+				return
+			}
+			_, err := tx.Exec("UPDATE trade_offers SET "+column+" = NOW(), updated_at = NOW() WHERE id = $1", offerID)
+			if err == nil && !otherConfirmed {
+				err = recordTradeEvent(tx, offerID.String(), userID, "confirmed", &status, status)
+				if err == nil {
+					err = notifyTrade(tx, other, offerID.String(), actorName+" confirmed your trade, confirm it too to complete it")
+				}
+				if err == nil {
+					err = tx.Commit()
+				}
+				if err != nil {
+					log.Printf("Trade confirm error: %v", err)
+					http.Error(w, "Error updating trade offer", http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]string{"message": "Trade confirmed, waiting for the other party"})
+				return
+			}
+			if err == nil {
+				err = recordTradePrice(tx, offerID)
+			}
+			if err == nil {
+				err = transferTradeItems(tx, offerID.String(), proposerID, recipientID)
+			}
+			if err == errTradeUnavailable {
+				http.Error(w, "One or more items are no longer available in that quantity", http.StatusConflict)
+				return
+			} else if err != nil {
+				log.Printf("Transfer trade items error: %v", err)
+				http.Error(w, "Error completing trade", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		_, err = tx.Exec("UPDATE trade_offers SET status = $1, updated_at = NOW() WHERE id = $2", rule.to, offerID)
+		if err == nil {
+			err = recordTradeEvent(tx, offerID.String(), userID, rule.to, &status, rule.to)
+		}
+		if err == nil {
+			err = notifyTrade(tx, other, offerID.String(), fmt.Sprintf("%s %s your trade", actorName, rule.verb))
+		}
+		if err != nil {
+			log.Printf("Trade %s error: %v", action, err)
+			http.Error(w, "Error updating trade offer", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Database commit error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Trade offer " + rule.to})
+	}
+}
@@ -3,12 +3,15 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
 	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+	"github.com/Joseph_Bartram8/vintage-toy-api/suggest"
 	"golang.org/x/crypto/bcrypt"
 
 	//"github.com/go-chi/chi/v5"
@@ -17,16 +20,45 @@ import (
 	//"golang.org/x/crypto/bcrypt"
 )
 
-// Get all users
+// userListSorts are the orderings shared by the user list endpoints
+var userListSorts = map[string]string{
+	"created_at": "u.created_at",
+	"name":       "ub.display_name",
+}
+
+// Get a page of users
 func GetUsersHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query(`
+		page, err := pagination.FromRequest(r, pagination.Options{Sorts: userListSorts, DefaultSort: "name"})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{middleware.ViewerID(r)}
+		conditions := []string{"u.is_deleted = FALSE", notHiddenFrom(1, "u.id")}
+
+		// Optional filter for accounts that do or don't run a shop
+		switch r.URL.Query().Get("has_store") {
+		case "true":
+			conditions = append(conditions, "COALESCE(ub.store_name, '') <> ''")
+		case "false":
+			conditions = append(conditions, "COALESCE(ub.store_name, '') = ''")
+		}
+
+		if clause, cursorArgs := page.Where("u.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
 			SELECT 
-				ub.display_name, ub.store_name, ub.bio_description, ub.profile_image
+				u.id, ub.display_name, ub.store_name, ub.bio_description, ub.profile_image, ub.collection_public, %s
 			FROM users u
 			JOIN user_bios ub ON u.id = ub.user_id
-			WHERE u.is_deleted = FALSE
-		`)
+			WHERE %s
+			%s
+		`, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("u.id")), args...)
 		if err != nil {
 			http.Error(w, "Database error", http.StatusInternalServerError)
 			return
@@ -34,11 +66,14 @@ func GetUsersHandler(db *sql.DB) http.HandlerFunc {
 		defer rows.Close()
 
 		var users []models.PublicUserSummary
+		var keys [][2]string
 		for rows.Next() {
 			var user models.PublicUserSummary
+			var sortKey string
 			var storeName, bioDescription, profileImage sql.NullString
 
-			err := rows.Scan(&user.DisplayName, &storeName, &bioDescription, &profileImage)
+			err := rows.Scan(&user.ID, &user.DisplayName, &storeName, &bioDescription, &profileImage,
+				&user.CollectionPublic, &sortKey)
 			if err != nil {
 				http.Error(w, "Error scanning users", http.StatusInternalServerError)
 				return
@@ -55,10 +90,11 @@ func GetUsersHandler(db *sql.DB) http.HandlerFunc {
 			}
 
 			users = append(users, user)
+			keys = append(keys, [2]string{sortKey, user.ID})
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(users)
+		json.NewEncoder(w).Encode(pagination.NewPage(users, keys, page))
 	}
 }
 
@@ -124,6 +160,8 @@ func CreateUserHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		suggest.Default.SetUser(userID.String(), req.DisplayName, nil)
+
 		// Success response
 		resp := map[string]interface{}{
 			"id":           userID,
@@ -175,14 +213,14 @@ func GetCurrentUserHandler(db *sql.DB) http.HandlerFunc {
 		err = db.QueryRow(`
 			SELECT u.first_name, u.last_name, u.email, u.is_deleted, 
 				   ub.display_name, ub.store_name, ub.bio_description, 
-				   ub.profile_image, ub.show_real_name, ub.updated_at
+				   ub.profile_image, ub.show_real_name, ub.collection_public, ub.updated_at
 			FROM users u
 			LEFT JOIN user_bios ub ON u.id = ub.user_id
 			WHERE u.id = $1 AND u.is_deleted = FALSE;
 		`, userID).Scan(
 			&user.FirstName, &user.LastName, &user.Email, &isDeleted,
 			&bio.DisplayName, &storeName, &bioDescription,
-			&profileImage, &bio.ShowRealName, &updatedAt,
+			&profileImage, &bio.ShowRealName, &bio.CollectionPublic, &updatedAt,
 		)
 
 		if err == sql.ErrNoRows {
@@ -242,15 +280,19 @@ func UpdateUserHandler(db *sql.DB) http.HandlerFunc {
 		}
 
 		// Update user_bios table
-		_, err = tx.Exec(`
+		var displayName string
+		var storeName sql.NullString
+		err = tx.QueryRow(`
 			UPDATE user_bios
 			SET display_name = COALESCE($1, display_name),
 				bio_description = COALESCE($2, bio_description),
 				profile_image = COALESCE($3, profile_image),
 				show_real_name = COALESCE($4, show_real_name),
+				collection_public = COALESCE($5, collection_public),
 				updated_at = NOW()
-			WHERE user_id = $5
-		`, req.DisplayName, req.BioDescription, req.ProfileImage, req.ShowRealName, userID)
+			WHERE user_id = $6
+			RETURNING display_name, store_name
+		`, req.DisplayName, req.BioDescription, req.ProfileImage, req.ShowRealName, req.CollectionPublic, userID).Scan(&displayName, &storeName)
 
 		if err != nil {
 			tx.Rollback()
@@ -259,6 +301,16 @@ func UpdateUserHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Making the collection public puts its tradeable items in front of wishlists
+		if req.CollectionPublic != nil && *req.CollectionPublic {
+			if err := notifyWishlistMatches(tx, userID); err != nil {
+				tx.Rollback()
+				log.Printf("Wishlist match error: %v", err)
+				http.Error(w, "Failed to update user bio", http.StatusInternalServerError)
+				return
+			}
+		}
+
 		// Commit changes
 		if err := tx.Commit(); err != nil {
 			log.Printf("Transaction commit error: %v", err)
@@ -266,6 +318,12 @@ func UpdateUserHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		var store *string
+		if storeName.Valid {
+			store = &storeName.String
+		}
+		suggest.Default.SetUser(userID.String(), displayName, store)
+
 		// Return success
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
@@ -291,6 +349,8 @@ func DeleteUserHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		suggest.Default.RemoveUser(userID.String())
+
 		// Return success response
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Account deleted successfully"})
@@ -306,14 +366,34 @@ func SearchUsersHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		rows, err := db.Query(`
-			SELECT ub.display_name, ub.profile_image, ub.store_name
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts:        userListSorts,
+			DefaultSort:  "name",
+			DefaultLimit: 10,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{query, middleware.ViewerID(r)}
+		conditions := []string{
+			"u.is_deleted = FALSE",
+			"(ub.display_name ILIKE '%' || $1 || '%' OR ub.store_name ILIKE '%' || $1 || '%')",
+			notHiddenFrom(2, "u.id"),
+		}
+		if clause, cursorArgs := page.Where("u.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT u.id, ub.display_name, ub.profile_image, ub.store_name, %s
 			FROM users u
 			JOIN user_bios ub ON u.id = ub.user_id
-			WHERE u.is_deleted = FALSE
-			  AND (ub.display_name ILIKE '%' || $1 || '%' OR ub.store_name ILIKE '%' || $1 || '%')
-			LIMIT 10
-		`, query)
+			WHERE %s
+			%s
+		`, page.SortValue(), strings.Join(conditions, " AND "), page.OrderBy("u.id")), args...)
 		if err != nil {
 			http.Error(w, "Database query error", http.StatusInternalServerError)
 			return
@@ -321,11 +401,13 @@ func SearchUsersHandler(db *sql.DB) http.HandlerFunc {
 		defer rows.Close()
 
 		var results []models.SearchUserResult
+		var keys [][2]string
 		for rows.Next() {
 			var user models.SearchUserResult
+			var userID, sortKey string
 			var storeName, profileImage sql.NullString
 
-			if err := rows.Scan(&user.DisplayName, &profileImage, &storeName); err != nil {
+			if err := rows.Scan(&userID, &user.DisplayName, &profileImage, &storeName, &sortKey); err != nil {
 				http.Error(w, "Error scanning results", http.StatusInternalServerError)
 				return
 			}
@@ -338,9 +420,10 @@ func SearchUsersHandler(db *sql.DB) http.HandlerFunc {
 			}
 
 			results = append(results, user)
+			keys = append(keys, [2]string{sortKey, userID})
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(results)
+		json.NewEncoder(w).Encode(pagination.NewPage(results, keys, page))
 	}
 }
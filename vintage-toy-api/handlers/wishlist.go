@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/middleware"
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+	"github.com/Joseph_Bartram8/vintage-toy-api/notify"
+	"github.com/Joseph_Bartram8/vintage-toy-api/pagination"
+	"github.com/Joseph_Bartram8/vintage-toy-api/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultWishlistMatches = 50
+	maxWishlistMatches     = 100
+)
+
+// wishlistDistance is the closest distance between the markers of the wishlist owner in the
+// match wm and those of the seller that the owner can see, placed at their public location
+var wishlistDistance = fmt.Sprintf(`(
+	SELECT MIN(%s)
+	FROM user_markers bm
+	JOIN user_markers sm ON sm.user_id = wm.seller_id
+	WHERE bm.user_id = wm.user_id
+	  AND (sm.visibility IN ('public', 'members')
+		OR (sm.visibility = 'followers' AND EXISTS (
+			SELECT 1 FROM user_follows f WHERE f.follower_id = wm.user_id AND f.followee_id = sm.user_id)))
+)`, utils.DistanceBetweenSQL("bm.latitude", "bm.longitude", "sm.public_latitude", "sm.public_longitude"))
+
+// wishlistOfferLink returns where a wishlist owner can see an offer
+func wishlistOfferLink(source, sourceID, sellerID string) string {
+	switch source {
+	case "collection":
+		return "/users/" + sellerID + "/collection"
+	case "listing":
+		return "/listings/" + sourceID
+	default:
+		return "/users/" + sellerID
+	}
+}
+
+type wishlistAlert struct {
+	userID   uuid.UUID
+	itemName string
+	source   string
+	sourceID string
+	sellerID string
+	seller   string
+	price    sql.NullInt64
+}
+
+// notifyWishlistMatches alerts the owner of every wishlist entry satisfied by one of the
+// seller's offers. Each offer is only alerted once per wishlist entry, so this can be
+// called after any change to what the seller is offering.
+func notifyWishlistMatches(tx *sql.Tx, sellerID uuid.UUID) error {
+	rows, err := tx.Query(`
+		WITH matched AS (
+			INSERT INTO wishlist_matches (wishlist_item_id, source, source_id)
+			SELECT wm.wishlist_item_id, wm.source, wm.source_id
+			FROM wishlist_offer_matches wm
+			WHERE wm.seller_id = $1
+			ON CONFLICT DO NOTHING
+			RETURNING wishlist_item_id, source, source_id
+		)
+		SELECT wi.user_id, ci.name, m.source, m.source_id, ub.display_name, wo.price_pence
+		FROM matched m
+		JOIN wishlist_items wi ON m.wishlist_item_id = wi.id
+		JOIN catalogue_items ci ON wi.catalogue_item_id = ci.id
+		JOIN wishlist_offers wo ON wo.source = m.source AND wo.source_id = m.source_id
+		JOIN user_bios ub ON wo.seller_id = ub.user_id
+	`, sellerID)
+	if err != nil {
+		return err
+	}
+
+	var alerts []wishlistAlert
+	for rows.Next() {
+		a := wishlistAlert{sellerID: sellerID.String()}
+		if err := rows.Scan(&a.userID, &a.itemName, &a.source, &a.sourceID, &a.seller, &a.price); err != nil {
+			rows.Close()
+			return err
+		}
+		alerts = append(alerts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, a := range alerts {
+		body := a.seller + " has one available"
+		if a.price.Valid {
+			body += fmt.Sprintf(" for £%.2f", float64(a.price.Int64)/100)
+		}
+		err := notify.Send(tx, notify.Notification{
+			UserID: a.userID,
+			Type:   notify.TypeWishlistMatch,
+			Title:  "Wishlist match: " + a.itemName,
+			Body:   body + ".",
+			Link:   wishlistOfferLink(a.source, a.sourceID, a.sellerID),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetWishlistHandler lists the authenticated user's wishlist with the number of current
+// matches for each entry. Supports ?sort=created_at|name, ?limit= and ?cursor=
+func GetWishlistHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := pagination.FromRequest(r, pagination.Options{
+			Sorts: map[string]string{
+				"created_at": "wi.created_at",
+				"name":       "ci.name",
+			},
+			DefaultSort: "-created_at",
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{userID}
+		conditions := []string{"wi.user_id = $1"}
+		if clause, cursorArgs := page.Where("wi.id", len(args)+1); clause != "" {
+			conditions = append(conditions, clause)
+			args = append(args, cursorArgs...)
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT %s, wi.id, wi.max_price_pence, wi.min_condition, wi.packagings, wi.notes, wi.created_at, wi.updated_at,
+				(SELECT COUNT(*) FROM wishlist_offer_matches wm WHERE wm.wishlist_item_id = wi.id), %s
+			%s
+			JOIN wishlist_items wi ON wi.catalogue_item_id = ci.id
+			WHERE %s
+			%s
+		`, catalogueItemColumns, page.SortValue(), catalogueItemFrom,
+			strings.Join(conditions, " AND "), page.OrderBy("wi.id")), args...)
+		if err != nil {
+			log.Println("Wishlist query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var items []models.WishlistItem
+		var keys [][2]string
+		for rows.Next() {
+			var item models.WishlistItem
+			var maxPrice, minCondition sql.NullInt64
+			var sortKey string
+
+			item.CatalogueItem, err = scanCatalogueItem(rows, &item.ID, &maxPrice, &minCondition, pq.Array(&item.Packagings),
+				&item.Notes, &item.CreatedAt, &item.UpdatedAt, &item.MatchCount, &sortKey)
+			if err != nil {
+				log.Println("Row scan error:", err)
+				http.Error(w, "Error scanning wishlist", http.StatusInternalServerError)
+				return
+			}
+			if maxPrice.Valid {
+				p := int(maxPrice.Int64)
+				item.MaxPricePence = &p
+			}
+			if minCondition.Valid {
+				c := int(minCondition.Int64)
+				item.MinCondition = &c
+			}
+			if item.Packagings == nil {
+				item.Packagings = []string{}
+			}
+
+			items = append(items, item)
+			keys = append(keys, [2]string{sortKey, item.ID})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pagination.NewPage(items, keys, page))
+	}
+}
+
+// SetWishlistItemHandler adds a catalogue item to the authenticated user's wishlist,
+// or replaces the limits of an existing entry
+func SetWishlistItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		itemID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid catalogue item ID", http.StatusBadRequest)
+			return
+		}
+
+		var req models.SetWishlistItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := models.Validate.Struct(req); err != nil {
+			http.Error(w, "Invalid input: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		packagings := req.Packagings
+		if packagings == nil {
+			packagings = []string{}
+		}
+
+		var id string
+		var inserted bool
+		err = db.QueryRow(`
+			INSERT INTO wishlist_items (user_id, catalogue_item_id, max_price_pence, min_condition, packagings, notes)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (user_id, catalogue_item_id) DO UPDATE
+			SET max_price_pence = EXCLUDED.max_price_pence,
+				min_condition = EXCLUDED.min_condition,
+				packagings = EXCLUDED.packagings,
+				notes = EXCLUDED.notes,
+				updated_at = NOW()
+			RETURNING id, (xmax = 0)
+		`, userID, itemID, req.MaxPricePence, req.MinCondition, pq.Array(packagings), req.Notes).Scan(&id, &inserted)
+		if err != nil {
+			if strings.Contains(err.Error(), "foreign key") {
+				http.Error(w, "Catalogue item not found", http.StatusNotFound)
+				return
+			}
+			log.Printf("Save wishlist item error: %v", err)
+			http.Error(w, "Failed to save wishlist item", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if inserted {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	}
+}
+
+// DeleteWishlistItemHandler removes a catalogue item from the authenticated user's wishlist
+func DeleteWishlistItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		itemID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid catalogue item ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM wishlist_items WHERE user_id = $1 AND catalogue_item_id = $2", userID, itemID)
+		if err != nil {
+			http.Error(w, "Error deleting wishlist item", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Wishlist item not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Removed from wishlist"})
+	}
+}
+
+// GetWishlistMatchesHandler lists current offers matching the authenticated user's wishlist,
+// nearest sellers first. Supports ?catalogue_item_id= and ?limit=
+func GetWishlistMatchesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		limit := defaultWishlistMatches
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxWishlistMatches {
+				http.Error(w, fmt.Sprintf("Invalid limit, expected 1 to %d", maxWishlistMatches), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		args := []interface{}{userID}
+		conditions := []string{"wm.user_id = $1"}
+		if err := uuidFilter(r, "catalogue_item_id", "wm.catalogue_item_id", &args, &conditions); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args = append(args, limit)
+
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT wm.wishlist_item_id, ci.id, ci.name, wm.source, wm.source_id, wm.seller_id, ub.display_name,
+				ub.store_name, ub.bio_description, ub.profile_image, ub.collection_public, wm.condition_grade,
+				wm.packaging, wm.price_pence, %s AS distance
+			FROM wishlist_offer_matches wm
+			JOIN catalogue_items ci ON wm.catalogue_item_id = ci.id
+			JOIN user_bios ub ON wm.seller_id = ub.user_id
+			WHERE %s
+			ORDER BY distance NULLS LAST, wm.price_pence NULLS LAST, wm.source_id
+			LIMIT $%d
+		`, wishlistDistance, strings.Join(conditions, " AND "), len(args)), args...)
+		if err != nil {
+			log.Println("Wishlist matches query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		matches := []models.WishlistMatch{}
+		for rows.Next() {
+			var m models.WishlistMatch
+			var storeName, bioDescription, profileImage sql.NullString
+			var grade, price sql.NullInt64
+			var distance sql.NullFloat64
+
+			err := rows.Scan(&m.WishlistItemID, &m.CatalogueItemID, &m.Name, &m.Source, &m.SourceID,
+				&m.Seller.ID, &m.Seller.DisplayName, &storeName, &bioDescription, &profileImage, &m.Seller.CollectionPublic,
+				&grade, &m.Packaging, &price, &distance)
+			if err != nil {
+				log.Println("Row scan error:", err)
+				http.Error(w, "Error scanning matches", http.StatusInternalServerError)
+				return
+			}
+
+			if storeName.Valid {
+				m.Seller.StoreName = &storeName.String
+			}
+			if bioDescription.Valid {
+				m.Seller.BioDescription = &bioDescription.String
+			}
+			if profileImage.Valid {
+				m.Seller.ProfileImage = &profileImage.String
+			}
+			if grade.Valid {
+				g := int(grade.Int64)
+				m.ConditionGrade = &g
+			}
+			if price.Valid {
+				p := int(price.Int64)
+				m.PricePence = &p
+			}
+			if distance.Valid {
+				m.DistanceM = &distance.Float64
+			}
+			matches = append(matches, m)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(matches)
+	}
+}
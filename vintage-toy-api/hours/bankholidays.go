@@ -0,0 +1,124 @@
+package hours
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Holiday is a single England and Wales bank holiday
+type Holiday struct {
+	Date time.Time
+	Name string
+}
+
+// oneOffHolidays are proclaimed holidays that don't follow the usual rules
+var oneOffHolidays = map[int][]Holiday{
+	2022: {
+		{date(2022, time.June, 3), "Platinum Jubilee bank holiday"},
+		{date(2022, time.September, 19), "Bank Holiday for the State Funeral of Queen Elizabeth II"},
+	},
+	2023: {
+		{date(2023, time.May, 8), "Bank holiday for the coronation of King Charles III"},
+	},
+}
+
+// movedHolidays are regular holidays proclaimed onto a different date in a given year
+var movedHolidays = map[int]map[string]time.Time{
+	2020: {"Early May bank holiday": date(2020, time.May, 8)},
+	2022: {"Spring bank holiday": date(2022, time.June, 2)},
+}
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// easter returns Easter Sunday using the anonymous Gregorian algorithm
+func easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return date(year, time.Month(month), day)
+}
+
+// firstMonday returns the first Monday of the month
+func firstMonday(year int, month time.Month) time.Time {
+	d := date(year, month, 1)
+	for d.Weekday() != time.Monday {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// lastMonday returns the last Monday of the month
+func lastMonday(year int, month time.Month) time.Time {
+	d := date(year, month+1, 0)
+	for d.Weekday() != time.Monday {
+		d = d.AddDate(0, 0, -1)
+	}
+	return d
+}
+
+// BankHolidays returns the England and Wales bank holidays for a year, with
+// weekend substitute days applied
+func BankHolidays(year int) []Holiday {
+	newYear := date(year, time.January, 1)
+	switch newYear.Weekday() {
+	case time.Saturday:
+		newYear = newYear.AddDate(0, 0, 2)
+	case time.Sunday:
+		newYear = newYear.AddDate(0, 0, 1)
+	}
+
+	christmas, boxing := date(year, time.December, 25), date(year, time.December, 26)
+	switch christmas.Weekday() {
+	case time.Friday:
+		boxing = date(year, time.December, 28)
+	case time.Saturday:
+		christmas, boxing = date(year, time.December, 27), date(year, time.December, 28)
+	case time.Sunday:
+		christmas = date(year, time.December, 27)
+	}
+
+	e := easter(year)
+	holidays := []Holiday{
+		{newYear, "New Year’s Day"},
+		{e.AddDate(0, 0, -2), "Good Friday"},
+		{e.AddDate(0, 0, 1), "Easter Monday"},
+		{firstMonday(year, time.May), "Early May bank holiday"},
+		{lastMonday(year, time.May), "Spring bank holiday"},
+		{lastMonday(year, time.August), "Summer bank holiday"},
+		{christmas, "Christmas Day"},
+		{boxing, "Boxing Day"},
+	}
+
+	for i, h := range holidays {
+		if moved, ok := movedHolidays[year][h.Name]; ok {
+			holidays[i].Date = moved
+		}
+	}
+	return append(holidays, oneOffHolidays[year]...)
+}
+
+// SyncBankHolidays stores the bank holidays for the given range of years
+func SyncBankHolidays(db *sql.DB, fromYear, toYear int) error {
+	for year := fromYear; year <= toYear; year++ {
+		for _, h := range BankHolidays(year) {
+			_, err := db.Exec(`
+				INSERT INTO bank_holidays (date, name) VALUES ($1, $2)
+				ON CONFLICT (date) DO NOTHING
+			`, h.Date.Format("2006-01-02"), h.Name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// RunListingExpiry expires listings past their listing period every interval. It never returns.
+func RunListingExpiry(db *sql.DB, interval time.Duration) {
+	for {
+		if err := ExpireListings(db, time.Now()); err != nil {
+			log.Println("⚠️ Listing expiry run failed:", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// ExpireListings marks active listings whose period ended before now as expired. Searches
+// already hide them from the moment they expire; this lets their sellers see it too.
+func ExpireListings(db *sql.DB, now time.Time) error {
+	_, err := db.Exec(`
+		UPDATE listings SET status = 'expired', updated_at = NOW()
+		WHERE status = 'active' AND expires_at <= $1
+	`, now)
+	return err
+}
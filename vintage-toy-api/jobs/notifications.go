@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/mailer"
+	"github.com/Joseph_Bartram8/vintage-toy-api/notify"
+	"github.com/Joseph_Bartram8/vintage-toy-api/webpush"
+)
+
+// RunNotificationEmails emails notifications queued for instant delivery every interval.
+// It never returns.
+func RunNotificationEmails(db *sql.DB, m mailer.Mailer, interval time.Duration) {
+	for {
+		if err := notify.SendPendingEmails(db, m); err != nil {
+			log.Println("⚠️ Notification email run failed:", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// RunNotificationDigests sends the daily digests once the day's digest time has passed,
+// checking every interval. It never returns.
+func RunNotificationDigests(db *sql.DB, m mailer.Mailer, interval time.Duration) {
+	for {
+		now := time.Now()
+		if due := notify.DigestTime(now); !now.Before(due) {
+			if err := notify.SendDigests(db, m, due); err != nil {
+				log.Println("⚠️ Notification digest run failed:", err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// RunPushNotifications pushes queued notifications to subscribed browsers every interval.
+// It never returns.
+func RunPushNotifications(db *sql.DB, s *webpush.Sender, interval time.Duration) {
+	for {
+		if err := notify.SendPendingPushes(db, s); err != nil {
+			log.Println("⚠️ Push notification run failed:", err)
+		}
+		time.Sleep(interval)
+	}
+}
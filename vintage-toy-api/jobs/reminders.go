@@ -0,0 +1,152 @@
+package jobs
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/calendar"
+	"github.com/Joseph_Bartram8/vintage-toy-api/notify"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ReminderLead is how far ahead of an occurrence attendees are reminded
+const ReminderLead = 24 * time.Hour
+
+// RunEventReminders sends due event reminders every interval. It never returns.
+func RunEventReminders(db *sql.DB, interval time.Duration) {
+	for {
+		if err := SendEventReminders(db, time.Now()); err != nil {
+			log.Println("⚠️ Event reminder run failed:", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+type reminderEvent struct {
+	markerID uuid.UUID
+	name     string
+	schedule calendar.Schedule
+}
+
+// SendEventReminders notifies everyone going to or interested in an event that starts
+// within ReminderLead of now. Each attendee is reminded once per occurrence, even
+// when several server instances run this concurrently.
+func SendEventReminders(db *sql.DB, now time.Time) error {
+	rows, err := db.Query(`
+		SELECT um.id, um.name, me.starts_at, me.ends_at, me.timezone, me.rrule, me.exdates
+		FROM marker_events me
+		JOIN user_markers um ON me.marker_id = um.id
+		JOIN users u ON um.user_id = u.id
+		WHERE u.is_deleted = FALSE
+		  AND (me.series_ends_at IS NULL OR me.series_ends_at > $1)
+		  AND (me.starts_at AT TIME ZONE me.timezone) < $2
+		  AND EXISTS (
+			SELECT 1 FROM event_rsvps er
+			WHERE er.marker_id = um.id AND er.status IN ('going', 'interested')
+			  AND er.occurrence_starts_at >= $1 AND er.occurrence_starts_at < $2
+		  )
+	`, now, now.Add(ReminderLead))
+	if err != nil {
+		return err
+	}
+
+	var events []reminderEvent
+	for rows.Next() {
+		var e reminderEvent
+		var startsAt, endsAt time.Time
+		var timezone string
+		var rrule sql.NullString
+		var exdates []string
+
+		if err := rows.Scan(&e.markerID, &e.name, &startsAt, &endsAt, &timezone, &rrule, pq.Array(&exdates)); err != nil {
+			rows.Close()
+			return err
+		}
+		for i := range exdates {
+			exdates[i] = strings.Replace(exdates[i], " ", "T", 1)
+		}
+
+		var rule *string
+		if rrule.Valid {
+			rule = &rrule.String
+		}
+		e.schedule, err = calendar.NewSchedule(startsAt.Format(calendar.LocalTimeLayout), endsAt.Format(calendar.LocalTimeLayout),
+			timezone, rule, exdates)
+		if err != nil {
+			log.Printf("Skipping marker %s with invalid schedule: %v", e.markerID, err)
+			continue
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		for _, o := range e.schedule.Between(now, now.Add(ReminderLead)) {
+			if o.Start.Before(now) {
+				continue
+			}
+			if err := remind(db, e, o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// remind claims and sends the reminders for one occurrence in a single transaction
+func remind(db *sql.DB, e reminderEvent, o calendar.Occurrence) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		INSERT INTO event_reminders_sent (marker_id, user_id, occurrence_starts_at)
+		SELECT er.marker_id, er.user_id, $2
+		FROM event_rsvps er
+		WHERE er.marker_id = $1 AND er.occurrence_starts_at = $2 AND er.status IN ('going', 'interested')
+		ON CONFLICT DO NOTHING
+		RETURNING user_id
+	`, e.markerID, o.Start)
+	if err != nil {
+		return err
+	}
+
+	var recipients []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return err
+		}
+		recipients = append(recipients, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, userID := range recipients {
+		err := notify.Send(tx, notify.Notification{
+			UserID: userID,
+			Type:   notify.TypeEventReminder,
+			Title:  "Reminder: " + e.name,
+			Body:   fmt.Sprintf("Starts %s.", o.Start.Format("Monday 2 January at 15:04 MST")),
+			Link:   "/markers/" + e.markerID.String(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
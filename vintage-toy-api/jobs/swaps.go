@@ -0,0 +1,177 @@
+package jobs
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/notify"
+	"github.com/Joseph_Bartram8/vintage-toy-api/swaps"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// SwapProposalLifetime is how long participants have to accept a swap proposal
+const SwapProposalLifetime = 7 * 24 * time.Hour
+
+// RunSwapMatcher looks for new swap cycles every interval. It never returns.
+func RunSwapMatcher(db *sql.DB, interval time.Duration) {
+	for {
+		if err := MatchSwaps(db, time.Now()); err != nil {
+			log.Println("⚠️ Swap matcher run failed:", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// MatchSwaps expires stale swap proposals, then proposes every new trade cycle of two to
+// four collectors that can be made from wishlists and tradeable collection items. Items
+// already in an open proposal are left out, and a cycle that has been proposed before is
+// never proposed again.
+func MatchSwaps(db *sql.DB, now time.Time) error {
+	_, err := db.Exec(`
+		UPDATE swap_proposals SET status = 'expired', updated_at = NOW()
+		WHERE status = 'pending' AND expires_at < $1
+	`, now)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`
+		SELECT wm.user_id, wm.seller_id, wm.source_id, wm.wishlist_item_id
+		FROM wishlist_offer_matches wm
+		WHERE wm.source = 'collection'
+		  AND NOT EXISTS (
+			SELECT 1 FROM swap_proposal_legs l
+			JOIN swap_proposals p ON l.proposal_id = p.id
+			WHERE p.status IN ('pending', 'accepted')
+			  AND (l.collection_item_id = wm.source_id OR l.wishlist_item_id = wm.wishlist_item_id)
+		  )
+	`)
+	if err != nil {
+		return err
+	}
+
+	var wants []swaps.Want
+	users := map[string]bool{}
+	for rows.Next() {
+		var w swaps.Want
+		if err := rows.Scan(&w.User, &w.Owner, &w.Item, &w.Wishlist); err != nil {
+			rows.Close()
+			return err
+		}
+		wants = append(wants, w)
+		users[w.User], users[w.Owner] = true, true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(wants) == 0 {
+		return nil
+	}
+
+	locations, err := swapLocations(db, users)
+	if err != nil {
+		return err
+	}
+
+	for _, cycle := range swaps.Find(wants, locations, swaps.Options{}) {
+		if err := proposeSwap(db, cycle, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// swapLocations returns the public locations of the markers of each user that other
+// members can see
+func swapLocations(db *sql.DB, users map[string]bool) (map[string][]swaps.Point, error) {
+	ids := make([]string, 0, len(users))
+	for id := range users {
+		ids = append(ids, id)
+	}
+
+	rows, err := db.Query(`
+		SELECT user_id, public_latitude, public_longitude
+		FROM user_markers
+		WHERE user_id = ANY($1::uuid[]) AND visibility IN ('public', 'members')
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := map[string][]swaps.Point{}
+	for rows.Next() {
+		var id string
+		var p swaps.Point
+		if err := rows.Scan(&id, &p.Lat, &p.Lng); err != nil {
+			return nil, err
+		}
+		locations[id] = append(locations[id], p)
+	}
+	return locations, rows.Err()
+}
+
+// proposeSwap records a cycle as a proposal and lets every participant know, in a single transaction
+func proposeSwap(db *sql.DB, cycle swaps.Cycle, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var proposalID string
+	err = tx.QueryRow(`
+		INSERT INTO swap_proposals (cycle_key, total_distance_m, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (cycle_key) DO NOTHING
+		RETURNING id
+	`, cycle.Key(), cycle.DistanceM, now.Add(SwapProposalLifetime)).Scan(&proposalID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for i, leg := range cycle.Legs {
+		var distance *float64
+		if leg.Located {
+			distance = &leg.DistanceM
+		}
+		_, err := tx.Exec(`
+			INSERT INTO swap_proposal_legs (proposal_id, position, giver_id, receiver_id, collection_item_id,
+				wishlist_item_id, name, distance_m)
+			SELECT $1, $2, $3, $4, co.id, $6, COALESCE(ci.name, co.name), $7
+			FROM collection_items co
+			LEFT JOIN catalogue_items ci ON co.catalogue_item_id = ci.id
+			WHERE co.id = $5
+		`, proposalID, i+1, leg.Giver, leg.Receiver, leg.Item, leg.Wishlist, distance)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO swap_proposal_participants (proposal_id, user_id) VALUES ($1, $2)",
+			proposalID, leg.Giver)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, leg := range cycle.Legs {
+		err := notify.Send(tx, notify.Notification{
+			UserID: uuid.MustParse(leg.Receiver),
+			Type:   notify.TypeSwapProposal,
+			Title:  fmt.Sprintf("A %d-way swap could get you something on your wishlist", len(cycle.Legs)),
+			Body:   "Everyone involved needs to accept before it goes ahead.",
+			Link:   "/swaps/" + proposalID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,93 @@
+// Package mailer sends email through a pluggable backend: SMTP in production and the log
+// during development
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	// Extra headers, e.g. List-Unsubscribe
+	Headers map[string]string
+}
+
+// Mailer delivers email
+type Mailer interface {
+	Send(m Message) error
+}
+
+// LogMailer writes email to the log instead of sending it
+type LogMailer struct{}
+
+// Send logs the message
+func (LogMailer) Send(m Message) error {
+	log.Printf("📧 Email to %s: %s\n%s", m.To, m.Subject, m.Body)
+	return nil
+}
+
+// SMTPMailer sends email through an SMTP server
+type SMTPMailer struct {
+	// Addr is the server's host:port
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// Send delivers the message over SMTP
+func (s SMTPMailer) Send(m Message) error {
+	var b strings.Builder
+	headers := map[string]string{
+		"From":                      s.From,
+		"To":                        m.To,
+		"Subject":                   mime.QEncoding.Encode("utf-8", m.Subject),
+		"Date":                      time.Now().Format(time.RFC1123Z),
+		"MIME-Version":              "1.0",
+		"Content-Type":              "text/plain; charset=UTF-8",
+		"Content-Transfer-Encoding": "8bit",
+	}
+	for k, v := range m.Headers {
+		headers[k] = v
+	}
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, headers[k])
+	}
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{m.To}, []byte(b.String()))
+}
+
+// FromEnv returns an SMTPMailer when SMTP_HOST is set, using SMTP_PORT (default 587),
+// SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM, and a LogMailer otherwise
+func FromEnv() Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return LogMailer{}
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return SMTPMailer{Addr: host + ":" + port, From: os.Getenv("SMTP_FROM"), Auth: auth}
+}
@@ -4,25 +4,109 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
+	_ "time/tzdata" // Event time zones must resolve even where the host has no zoneinfo
 
 	_ "github.com/lib/pq"
 	"github.com/rs/cors" // Import CORS package
 
 	"github.com/Joseph_Bartram8/vintage-toy-api/db"
+	"github.com/Joseph_Bartram8/vintage-toy-api/handlers"
+	"github.com/Joseph_Bartram8/vintage-toy-api/hours"
+	"github.com/Joseph_Bartram8/vintage-toy-api/jobs"
+	"github.com/Joseph_Bartram8/vintage-toy-api/mailer"
+	"github.com/Joseph_Bartram8/vintage-toy-api/notify"
+	"github.com/Joseph_Bartram8/vintage-toy-api/realtime"
 	"github.com/Joseph_Bartram8/vintage-toy-api/router"
+	"github.com/Joseph_Bartram8/vintage-toy-api/suggest"
+	"github.com/Joseph_Bartram8/vintage-toy-api/webpush"
 )
 
 func main() {
 	// Connect to database
 	db.ConnectDB()
 
+	// Build the in-memory autocomplete index
+	if err := suggest.Default.Load(db.DB); err != nil {
+		log.Println("⚠️ Warning: Failed to load suggestion index:", err)
+	}
+
+	// Make sure shop opening hours know about recent and upcoming bank holidays
+	year := time.Now().Year()
+	if err := hours.SyncBankHolidays(db.DB, year-1, year+2); err != nil {
+		log.Println("⚠️ Warning: Failed to sync bank holidays:", err)
+	}
+
+	// Relay live updates announced by the database to streaming clients
+	realtime.Default.Listen(db.DB, db.URL)
+
+	// Send event reminders in the background
+	go jobs.RunEventReminders(db.DB, 10*time.Minute)
+
+	// Look for multi-party swaps between wishlists and tradeable collections
+	go jobs.RunSwapMatcher(db.DB, time.Hour)
+
+	// Listings stay live for LISTING_LIFETIME_DAYS, 30 by default
+	if days := os.Getenv("LISTING_LIFETIME_DAYS"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			log.Fatal("❌ LISTING_LIFETIME_DAYS must be a whole number of days")
+		}
+		handlers.ListingLifetime = time.Duration(n) * 24 * time.Hour
+	}
+	go jobs.RunListingExpiry(db.DB, 10*time.Minute)
+
+	// Email notifications as they happen and in a daily digest, through SMTP when
+	// SMTP_HOST is set and to the log otherwise
+	if appURL := os.Getenv("APP_URL"); appURL != "" {
+		notify.AppURL = appURL
+	}
+	if apiURL := os.Getenv("API_URL"); apiURL != "" {
+		notify.APIURL = apiURL
+	}
+	// Unsubscribe links are signed with a key derived from UNSUBSCRIBE_SECRET, or from
+	// JWT_SECRET when that isn't set
+	unsubscribeSecret := os.Getenv("UNSUBSCRIBE_SECRET")
+	if unsubscribeSecret == "" {
+		unsubscribeSecret = os.Getenv("JWT_SECRET")
+	}
+	if err := notify.SetUnsubscribeSecret(unsubscribeSecret); err != nil {
+		log.Fatal("❌ UNSUBSCRIBE_SECRET or JWT_SECRET must be set to sign unsubscribe links")
+	}
+	m := mailer.FromEnv()
+	go jobs.RunNotificationEmails(db.DB, m, time.Minute)
+	go jobs.RunNotificationDigests(db.DB, m, 15*time.Minute)
+
+	// Push notifications to subscribed browsers. The VAPID key pair comes from
+	// VAPID_PRIVATE_KEY, or is generated once and kept in the database.
+	var vapidKeys webpush.Keys
+	var err error
+	if key := os.Getenv("VAPID_PRIVATE_KEY"); key != "" {
+		vapidKeys, err = webpush.ParseKeys(key)
+	} else {
+		vapidKeys, err = webpush.LoadOrCreateKeys(db.DB)
+	}
+	if err != nil {
+		log.Println("⚠️ Warning: Push notifications disabled, no VAPID keys:", err)
+	} else {
+		subject := os.Getenv("VAPID_SUBJECT")
+		if subject == "" {
+			subject = notify.AppURL
+		}
+		handlers.VAPIDPublicKey = vapidKeys.PublicKey
+		sender := &webpush.Sender{Keys: vapidKeys, Subject: subject, TTL: 24 * time.Hour,
+			Client: &http.Client{Timeout: 10 * time.Second}}
+		go jobs.RunPushNotifications(db.DB, sender, 30*time.Second)
+	}
+
 	// Initialize router with database instance
 	r := router.SetupRouter(db.DB)
 
 	// Set up CORS middleware
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins:   []string{"https://blastfromthepastbackend.onrender.com", "http://localhost:5173"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
 		AllowedHeaders:   []string{"Authorization", "Content-Type"},
 		AllowCredentials: true,
 	})
@@ -35,7 +119,7 @@ func main() {
 
 	// Start server with CORS handling
 	log.Printf("🚀 Server running on :%s\n", port)
-	err := http.ListenAndServe(":"+port, corsHandler.Handler(r))
+	err = http.ListenAndServe(":"+port, corsHandler.Handler(r))
 	if err != nil {
 		log.Fatal("❌ Server failed to start:", err)
 	}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// AdminMiddleware only lets through signed-in users flagged as admins.
+// It must run after AuthMiddleware.
+func AdminMiddleware(db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(UserIDKey).(uuid.UUID)
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			var isAdmin bool
+			err := db.QueryRow("SELECT is_admin FROM users WHERE id = $1 AND is_deleted = FALSE", userID).Scan(&isAdmin)
+			if err != nil && err != sql.ErrNoRows {
+				log.Println("Admin check error:", err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if !isAdmin {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
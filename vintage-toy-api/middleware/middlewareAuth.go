@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -16,36 +17,73 @@ type contextKey string
 
 const UserIDKey contextKey = "userID"
 
+// Reasons userIDFromRequest can fail
+var (
+	errMissingToken   = errors.New("missing token")
+	errInvalidToken   = errors.New("invalid token")
+	errInvalidSubject = errors.New("invalid token subject")
+)
+
+// userIDFromRequest reads the user ID from the JWT in the auth_token cookie
+func userIDFromRequest(r *http.Request) (uuid.UUID, error) {
+	cookie, err := r.Cookie("auth_token")
+	if err != nil {
+		return uuid.Nil, errMissingToken
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(cookie.Value, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	})
+	if err != nil || !token.Valid {
+		return uuid.Nil, errInvalidToken
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, errInvalidSubject
+	}
+	return userID, nil
+}
+
 // AuthMiddleware validates JWT from cookies
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Println("Middleware: Received Cookies:", r.Cookies())
 
-		cookie, err := r.Cookie("auth_token")
-		if err != nil {
+		if cookie, err := r.Cookie("auth_token"); err == nil {
+			log.Println("Middleware: Extracted Token:", cookie.Value)
+		}
+
+		userID, err := userIDFromRequest(r)
+		switch err {
+		case nil:
+		case errMissingToken:
 			log.Println("Middleware: No auth_token cookie found")
 			http.Error(w, "Missing token", http.StatusUnauthorized)
 			return
-		}
-
-		tokenStr := cookie.Value
-		log.Println("Middleware: Extracted Token:", tokenStr)
-
-		claims := &jwt.RegisteredClaims{}
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		})
-
-		if err != nil || !token.Valid {
+		case errInvalidToken:
 			log.Println("❌ Middleware: Invalid or expired token")
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
+		default:
+			log.Println("❌ Middleware: Invalid token subject")
+			http.Error(w, "Invalid token subject", http.StatusUnauthorized)
+			return
 		}
 
-		userID, err := uuid.Parse(claims.Subject)
+		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// OptionalAuthMiddleware attaches the user ID when a valid auth_token cookie is
+// present, and otherwise lets the request through anonymously
+func OptionalAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
 		if err != nil {
-			log.Println("❌ Middleware: Invalid token subject")
-			http.Error(w, "Invalid token subject", http.StatusUnauthorized)
+			next.ServeHTTP(w, r)
 			return
 		}
 
@@ -53,3 +91,11 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// ViewerID returns the caller's user ID as a query argument, or nil for anonymous requests
+func ViewerID(r *http.Request) interface{} {
+	if userID, ok := r.Context().Value(UserIDKey).(uuid.UUID); ok {
+		return userID
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ActivityEntry is one thing a followed user did, as shown in the feed. SubjectID is the
+// marker, listing, collection entry or review it concerns.
+type ActivityEntry struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Actor     PublicUserSummary `json:"actor"`
+	SubjectID string            `json:"subject_id"`
+	Details   json.RawMessage   `json:"details"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Follow is an entry in a user's follower or following list
+type Follow struct {
+	User       PublicUserSummary `json:"user"`
+	FollowedAt time.Time         `json:"followed_at"`
+}
+
+// RestrictedUser is an entry in the authenticated user's list of blocked or muted users
+type RestrictedUser struct {
+	User  PublicUserSummary `json:"user"`
+	Since time.Time         `json:"since"`
+}
@@ -13,8 +13,9 @@ type LoginResponse struct {
 
 // UpdateUserRequest struct
 type UpdateUserRequest struct {
-	DisplayName    *string `json:"display_name,omitempty"`
-	BioDescription *string `json:"bio_description,omitempty"`
-	ProfileImage   *string `json:"profile_image,omitempty"`
-	ShowRealName   *bool   `json:"show_real_name,omitempty"`
+	DisplayName      *string `json:"display_name,omitempty"`
+	BioDescription   *string `json:"bio_description,omitempty"`
+	ProfileImage     *string `json:"profile_image,omitempty"`
+	ShowRealName     *bool   `json:"show_real_name,omitempty"`
+	CollectionPublic *bool   `json:"collection_public,omitempty"`
 }
@@ -0,0 +1,169 @@
+package models
+
+import "time"
+
+// CatalogueRef identifies a related catalogue record by ID and name
+type CatalogueRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// Franchise represents a toy franchise returned by the API
+type Franchise struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description *string   `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Manufacturer represents a toy manufacturer returned by the API
+type Manufacturer struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	Country   *string   `json:"country,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProductLine represents a product line returned by the API
+type ProductLine struct {
+	ID          string       `json:"id"`
+	Franchise   CatalogueRef `json:"franchise"`
+	Name        string       `json:"name"`
+	Slug        string       `json:"slug"`
+	StartYear   *int         `json:"start_year,omitempty"`
+	EndYear     *int         `json:"end_year,omitempty"`
+	Description *string      `json:"description,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// CatalogueItem represents a catalogue item returned by the API
+type CatalogueItem struct {
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	Year         *int          `json:"year,omitempty"`
+	Variant      string        `json:"variant,omitempty"`
+	SKU          *string       `json:"sku,omitempty"`
+	Barcode      *string       `json:"barcode,omitempty"`
+	Images       []string      `json:"images"`
+	Description  *string       `json:"description,omitempty"`
+	Line         CatalogueRef  `json:"line"`
+	Franchise    CatalogueRef  `json:"franchise"`
+	Manufacturer *CatalogueRef `json:"manufacturer,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// CreateFranchiseRequest struct
+type CreateFranchiseRequest struct {
+	Name        string  `json:"name" validate:"required,max=100"`
+	Description *string `json:"description,omitempty"`
+}
+
+// UpdateFranchiseRequest struct
+type UpdateFranchiseRequest struct {
+	Name        *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Description *string `json:"description,omitempty"`
+}
+
+// CreateManufacturerRequest struct
+type CreateManufacturerRequest struct {
+	Name    string  `json:"name" validate:"required,max=100"`
+	Country *string `json:"country,omitempty" validate:"omitempty,max=100"`
+}
+
+// UpdateManufacturerRequest struct
+type UpdateManufacturerRequest struct {
+	Name    *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Country *string `json:"country,omitempty" validate:"omitempty,max=100"`
+}
+
+// CreateLineRequest struct
+type CreateLineRequest struct {
+	FranchiseID string  `json:"franchise_id" validate:"required,uuid"`
+	Name        string  `json:"name" validate:"required,max=150"`
+	StartYear   *int    `json:"start_year,omitempty" validate:"omitempty,min=1850,max=2100"`
+	EndYear     *int    `json:"end_year,omitempty" validate:"omitempty,min=1850,max=2100"`
+	Description *string `json:"description,omitempty"`
+}
+
+// UpdateLineRequest struct
+type UpdateLineRequest struct {
+	Name        *string `json:"name,omitempty" validate:"omitempty,min=1,max=150"`
+	StartYear   *int    `json:"start_year,omitempty" validate:"omitempty,min=1850,max=2100"`
+	EndYear     *int    `json:"end_year,omitempty" validate:"omitempty,min=1850,max=2100"`
+	Description *string `json:"description,omitempty"`
+}
+
+// CreateItemRequest struct
+type CreateItemRequest struct {
+	LineID         string   `json:"line_id" validate:"required,uuid"`
+	ManufacturerID *string  `json:"manufacturer_id,omitempty" validate:"omitempty,uuid"`
+	Name           string   `json:"name" validate:"required,max=200"`
+	Year           *int     `json:"year,omitempty" validate:"omitempty,min=1850,max=2100"`
+	Variant        string   `json:"variant" validate:"max=150"`
+	SKU            *string  `json:"sku,omitempty" validate:"omitempty,max=100"`
+	Barcode        *string  `json:"barcode,omitempty" validate:"omitempty,max=20"`
+	Images         []string `json:"images,omitempty" validate:"omitempty,max=20,dive,url"`
+	Description    *string  `json:"description,omitempty"`
+}
+
+// UpdateItemRequest struct
+type UpdateItemRequest struct {
+	LineID         *string  `json:"line_id,omitempty" validate:"omitempty,uuid"`
+	ManufacturerID *string  `json:"manufacturer_id,omitempty" validate:"omitempty,uuid"`
+	Name           *string  `json:"name,omitempty" validate:"omitempty,min=1,max=200"`
+	Year           *int     `json:"year,omitempty" validate:"omitempty,min=1850,max=2100"`
+	Variant        *string  `json:"variant,omitempty" validate:"omitempty,max=150"`
+	SKU            *string  `json:"sku,omitempty" validate:"omitempty,max=100"`
+	Barcode        *string  `json:"barcode,omitempty" validate:"omitempty,max=20"`
+	Images         []string `json:"images,omitempty" validate:"omitempty,max=20,dive,url"`
+	Description    *string  `json:"description,omitempty"`
+}
+
+// CatalogueImportRecord is one row of a bulk catalogue load. Franchises, lines and
+// manufacturers are matched by name and created when missing.
+type CatalogueImportRecord struct {
+	Franchise    string   `json:"franchise" validate:"required,max=100"`
+	Line         string   `json:"line" validate:"required,max=150"`
+	Manufacturer string   `json:"manufacturer,omitempty" validate:"max=100"`
+	Name         string   `json:"name" validate:"required,max=200"`
+	Year         *int     `json:"year,omitempty" validate:"omitempty,min=1850,max=2100"`
+	Variant      string   `json:"variant,omitempty" validate:"max=150"`
+	SKU          string   `json:"sku,omitempty" validate:"max=100"`
+	Barcode      string   `json:"barcode,omitempty" validate:"max=20"`
+	Images       []string `json:"images,omitempty" validate:"omitempty,max=20,dive,url"`
+	Description  string   `json:"description,omitempty"`
+}
+
+// CatalogueImportResult summarises a bulk catalogue load
+type CatalogueImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+// BarcodeLookup is the catalogue items matching a scanned barcode
+type BarcodeLookup struct {
+	Barcode string          `json:"barcode"`
+	Format  string          `json:"format"`
+	Items   []CatalogueItem `json:"items"`
+}
+
+// UnknownBarcode is a scanned barcode waiting for a curator to add it to the catalogue
+type UnknownBarcode struct {
+	Barcode         string     `json:"barcode"`
+	Format          string     `json:"format"`
+	ScanCount       int        `json:"scan_count"`
+	Status          string     `json:"status"`
+	CatalogueItemID *string    `json:"catalogue_item_id,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	FirstSeenAt     time.Time  `json:"first_seen_at"`
+	LastSeenAt      time.Time  `json:"last_seen_at"`
+}
+
+// ResolveBarcodeRequest links an unknown barcode to the catalogue item it belongs to
+type ResolveBarcodeRequest struct {
+	CatalogueItemID string `json:"catalogue_item_id" validate:"required,uuid"`
+}
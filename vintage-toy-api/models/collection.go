@@ -0,0 +1,100 @@
+package models
+
+import "time"
+
+// Packagings lists the values accepted by the collection_items.packaging check constraint:
+// loose, mint on card, and mint in box
+var Packagings = map[string]bool{
+	"loose": true,
+	"MOC":   true,
+	"MIB":   true,
+}
+
+// CollectionItem represents one entry in a user's collection. Name, Franchise, Line and
+// Year come from the catalogue when the entry is linked to a catalogue item.
+type CollectionItem struct {
+	ID                 string    `json:"id"`
+	CatalogueItemID    *string   `json:"catalogue_item_id,omitempty"`
+	Name               string    `json:"name"`
+	Franchise          *string   `json:"franchise,omitempty"`
+	Line               *string   `json:"line,omitempty"`
+	Year               *int      `json:"year,omitempty"`
+	ConditionGrade     *int      `json:"condition_grade,omitempty"`
+	Packaging          *string   `json:"packaging,omitempty"`
+	Quantity           int       `json:"quantity"`
+	PurchasePricePence *int      `json:"purchase_price_pence,omitempty"`
+	PurchaseDate       *string   `json:"purchase_date,omitempty"`
+	Notes              *string   `json:"notes,omitempty"`
+	Photos             []string  `json:"photos"`
+	Tradeable          bool      `json:"tradeable"`
+	AskingPricePence   *int      `json:"asking_price_pence,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// CreateCollectionItemRequest adds either a catalogue item (CatalogueItemID) or a free-form entry (Name)
+type CreateCollectionItemRequest struct {
+	CatalogueItemID    *string  `json:"catalogue_item_id,omitempty" validate:"omitempty,uuid"`
+	Name               *string  `json:"name,omitempty" validate:"omitempty,min=1,max=200"`
+	Franchise          *string  `json:"franchise,omitempty" validate:"omitempty,max=100"`
+	Line               *string  `json:"line,omitempty" validate:"omitempty,max=150"`
+	Year               *int     `json:"year,omitempty" validate:"omitempty,min=1850,max=2100"`
+	ConditionGrade     *int     `json:"condition_grade,omitempty" validate:"omitempty,min=1,max=10"`
+	Packaging          *string  `json:"packaging,omitempty" validate:"omitempty,oneof=loose MOC MIB"`
+	Quantity           *int     `json:"quantity,omitempty" validate:"omitempty,min=1"`
+	PurchasePricePence *int     `json:"purchase_price_pence,omitempty" validate:"omitempty,min=0"`
+	PurchaseDate       *string  `json:"purchase_date,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	Notes              *string  `json:"notes,omitempty" validate:"omitempty,max=5000"`
+	Photos             []string `json:"photos,omitempty" validate:"omitempty,max=20,dive,url"`
+	Tradeable          *bool    `json:"tradeable,omitempty"`
+	AskingPricePence   *int     `json:"asking_price_pence,omitempty" validate:"omitempty,min=0"`
+}
+
+// UpdateCollectionItemRequest struct. Sending photos replaces the whole set.
+type UpdateCollectionItemRequest struct {
+	Name               *string  `json:"name,omitempty" validate:"omitempty,min=1,max=200"`
+	Franchise          *string  `json:"franchise,omitempty" validate:"omitempty,max=100"`
+	Line               *string  `json:"line,omitempty" validate:"omitempty,max=150"`
+	Year               *int     `json:"year,omitempty" validate:"omitempty,min=1850,max=2100"`
+	ConditionGrade     *int     `json:"condition_grade,omitempty" validate:"omitempty,min=1,max=10"`
+	Packaging          *string  `json:"packaging,omitempty" validate:"omitempty,oneof=loose MOC MIB"`
+	Quantity           *int     `json:"quantity,omitempty" validate:"omitempty,min=1"`
+	PurchasePricePence *int     `json:"purchase_price_pence,omitempty" validate:"omitempty,min=0"`
+	PurchaseDate       *string  `json:"purchase_date,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	Notes              *string  `json:"notes,omitempty" validate:"omitempty,max=5000"`
+	Photos             []string `json:"photos,omitempty" validate:"omitempty,max=20,dive,url"`
+	Tradeable          *bool    `json:"tradeable,omitempty"`
+	AskingPricePence   *int     `json:"asking_price_pence,omitempty" validate:"omitempty,min=0"`
+}
+
+// CollectionStatsGroup summarises the part of a collection in one franchise, line or decade
+type CollectionStatsGroup struct {
+	Label      string `json:"label"`
+	Entries    int    `json:"entries"`
+	Quantity   int    `json:"quantity"`
+	SpentPence *int   `json:"spent_pence,omitempty"`
+}
+
+// CollectionStats summarises a collection, grouped by GroupBy. SpentPence is left out
+// of someone else's public collection
+type CollectionStats struct {
+	GroupBy    string                 `json:"group_by"`
+	Entries    int                    `json:"entries"`
+	Quantity   int                    `json:"quantity"`
+	SpentPence *int                   `json:"spent_pence,omitempty"`
+	Groups     []CollectionStatsGroup `json:"groups"`
+}
+
+// PublicCollection is a page of a user's public collection shown alongside their profile summary
+type PublicCollection struct {
+	User       PublicUserSummary `json:"user"`
+	Data       []CollectionItem  `json:"data"`
+	NextCursor *string           `json:"next_cursor"`
+}
+
+// ScanCollectionItemRequest adds the catalogue item with a scanned barcode. When the
+// barcode matches several items, CatalogueItemID picks one of them.
+type ScanCollectionItemRequest struct {
+	Barcode string `json:"barcode" validate:"required,max=20"`
+	CreateCollectionItemRequest
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// EventMarkerTypes lists the marker types that can carry an event schedule
+var EventMarkerTypes = map[string]bool{
+	"Event":        true,
+	"Trade Meetup": true,
+}
+
+// EventSchedule describes when an event marker takes place. Times are wall-clock
+// values (calendar.LocalTimeLayout) in Timezone.
+type EventSchedule struct {
+	StartsAt string   `json:"starts_at"`
+	EndsAt   string   `json:"ends_at"`
+	Timezone string   `json:"timezone"`
+	RRule    *string  `json:"rrule,omitempty"`
+	ExDates  []string `json:"exdates,omitempty"`
+	Capacity *int     `json:"capacity,omitempty"`
+}
+
+// SetEventScheduleRequest struct
+type SetEventScheduleRequest struct {
+	StartsAt string   `json:"starts_at" validate:"required"`
+	EndsAt   string   `json:"ends_at" validate:"required"`
+	Timezone *string  `json:"timezone,omitempty"`
+	RRule    *string  `json:"rrule,omitempty"`
+	ExDates  []string `json:"exdates,omitempty"`
+	Capacity *int     `json:"capacity,omitempty" validate:"omitempty,min=1"`
+}
+
+// EventOccurrence is a single dated instance of an event marker
+type EventOccurrence struct {
+	StartsAt time.Time      `json:"starts_at"`
+	EndsAt   time.Time      `json:"ends_at"`
+	Marker   MarkerResponse `json:"marker"`
+}
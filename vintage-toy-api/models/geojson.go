@@ -0,0 +1,40 @@
+package models
+
+// FeatureCollection is a GeoJSON feature collection, with the pagination cursor as a foreign member
+type FeatureCollection struct {
+	Type       string    `json:"type"`
+	Features   []Feature `json:"features"`
+	NextCursor *string   `json:"next_cursor,omitempty"`
+}
+
+// Feature is a GeoJSON feature
+type Feature struct {
+	Type       string      `json:"type"`
+	ID         string      `json:"id,omitempty"`
+	Geometry   Geometry    `json:"geometry"`
+	Properties interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry; only points and line strings are used
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// NewPointFeature builds a point feature. GeoJSON orders coordinates longitude first.
+func NewPointFeature(id string, lat, lng float64, properties interface{}) Feature {
+	return Feature{
+		Type:       "Feature",
+		ID:         id,
+		Geometry:   Geometry{Type: "Point", Coordinates: [2]float64{lng, lat}},
+		Properties: properties,
+	}
+}
+
+// NewFeatureCollection wraps features in a collection
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
@@ -0,0 +1,31 @@
+package models
+
+// OpeningPeriod is a weekly opening window in Europe/London time. Weekday is ISO (1 = Monday).
+type OpeningPeriod struct {
+	Weekday int    `json:"weekday" validate:"min=1,max=7"`
+	Opens   string `json:"opens" validate:"required"`
+	Closes  string `json:"closes" validate:"required"`
+}
+
+// OpeningException overrides the weekly hours on one date, either closing the shop or replacing its hours
+type OpeningException struct {
+	Date   string  `json:"date" validate:"required"`
+	Closed bool    `json:"closed"`
+	Opens  *string `json:"opens,omitempty"`
+	Closes *string `json:"closes,omitempty"`
+	Note   *string `json:"note,omitempty"`
+}
+
+// OpeningHours is a shop's full opening schedule
+type OpeningHours struct {
+	Weekly               []OpeningPeriod    `json:"weekly"`
+	Exceptions           []OpeningException `json:"exceptions"`
+	ClosedOnBankHolidays bool               `json:"closed_on_bank_holidays"`
+}
+
+// SetOpeningHoursRequest replaces a shop's weekly hours and exceptions
+type SetOpeningHoursRequest struct {
+	Weekly               []OpeningPeriod    `json:"weekly" validate:"dive"`
+	Exceptions           []OpeningException `json:"exceptions" validate:"dive"`
+	ClosedOnBankHolidays *bool              `json:"closed_on_bank_holidays,omitempty"`
+}
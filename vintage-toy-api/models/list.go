@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// MaxListItems caps how many markers a single list can hold
+const MaxListItems = 500
+
+// CreateListRequest struct
+type CreateListRequest struct {
+	Name        string  `json:"name" validate:"required,max=100"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=2000"`
+	Visibility  *string `json:"visibility,omitempty" validate:"omitempty,oneof=private shared"`
+}
+
+// UpdateListRequest struct. Setting RegenerateLink on a shared list invalidates the old link.
+type UpdateListRequest struct {
+	Name           *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Description    *string `json:"description,omitempty" validate:"omitempty,max=2000"`
+	Visibility     *string `json:"visibility,omitempty" validate:"omitempty,oneof=private shared"`
+	RegenerateLink bool    `json:"regenerate_link,omitempty"`
+}
+
+// ListItemRequest adds a marker to a list, or updates its note and position
+type ListItemRequest struct {
+	Note     *string `json:"note,omitempty" validate:"omitempty,max=1000"`
+	Position *int    `json:"position,omitempty" validate:"omitempty,min=1"`
+}
+
+// ReorderListRequest gives the list's marker IDs in their new order
+type ReorderListRequest struct {
+	MarkerIDs []string `json:"marker_ids" validate:"required,dive,uuid"`
+}
+
+// MarkerList represents a saved list returned by the API
+type MarkerList struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description,omitempty"`
+	Visibility  string    `json:"visibility"`
+	ShareToken  *string   `json:"share_token,omitempty"`
+	MarkerCount int       `json:"marker_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// MarkerListItem is one marker in a list, with its position and the list owner's note
+type MarkerListItem struct {
+	Position int            `json:"position"`
+	Note     *string        `json:"note,omitempty"`
+	Marker   MarkerResponse `json:"marker"`
+}
+
+// MarkerListDetail is a list together with its markers in order
+type MarkerListDetail struct {
+	MarkerList
+	Items []MarkerListItem `json:"items"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// ListingPickup is where a listing can be collected from, at the marker's public location
+type ListingPickup struct {
+	MarkerID  string  `json:"marker_id"`
+	Name      string  `json:"name"`
+	Region    string  `json:"region"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	AccuracyM float64 `json:"accuracy_m"`
+}
+
+// Listing is stock a shop or collector is selling. Franchise comes from the catalogue
+// when the listing is linked to a catalogue item.
+type Listing struct {
+	ID              string            `json:"id"`
+	Seller          PublicUserSummary `json:"seller"`
+	CatalogueItemID *string           `json:"catalogue_item_id,omitempty"`
+	Title           string            `json:"title"`
+	Description     *string           `json:"description,omitempty"`
+	Franchise       *string           `json:"franchise,omitempty"`
+	PricePence      int               `json:"price_pence"`
+	ConditionGrade  *int              `json:"condition_grade,omitempty"`
+	Packaging       *string           `json:"packaging,omitempty"`
+	Photos          []string          `json:"photos"`
+	Quantity        int               `json:"quantity"`
+	Status          string            `json:"status"`
+	// Omitted when the listing has no pickup location or the viewer can't see its marker
+	Pickup      *ListingPickup `json:"pickup,omitempty"`
+	DistanceM   *float64       `json:"distance_m,omitempty"`
+	PublishedAt *time.Time     `json:"published_at,omitempty"`
+	ExpiresAt   *time.Time     `json:"expires_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// CreateListingRequest lists either a catalogue item (CatalogueItemID) or a free-form item (Title).
+// Listings start as drafts unless Status is active.
+type CreateListingRequest struct {
+	CatalogueItemID *string  `json:"catalogue_item_id,omitempty" validate:"omitempty,uuid"`
+	Title           *string  `json:"title,omitempty" validate:"omitempty,min=1,max=200"`
+	Description     *string  `json:"description,omitempty" validate:"omitempty,max=5000"`
+	Franchise       *string  `json:"franchise,omitempty" validate:"omitempty,max=100"`
+	PricePence      *int     `json:"price_pence" validate:"required,min=0"`
+	ConditionGrade  *int     `json:"condition_grade,omitempty" validate:"omitempty,min=1,max=10"`
+	Packaging       *string  `json:"packaging,omitempty" validate:"omitempty,oneof=loose MOC MIB"`
+	Photos          []string `json:"photos,omitempty" validate:"omitempty,max=20,dive,url"`
+	Quantity        *int     `json:"quantity,omitempty" validate:"omitempty,min=1"`
+	MarkerID        *string  `json:"marker_id,omitempty" validate:"omitempty,uuid"`
+	Status          *string  `json:"status,omitempty" validate:"omitempty,oneof=draft active"`
+}
+
+// UpdateListingRequest struct. Sending photos replaces the whole set. Expired listings
+// are relisted by setting Status back to active.
+type UpdateListingRequest struct {
+	Title          *string  `json:"title,omitempty" validate:"omitempty,min=1,max=200"`
+	Description    *string  `json:"description,omitempty" validate:"omitempty,max=5000"`
+	Franchise      *string  `json:"franchise,omitempty" validate:"omitempty,max=100"`
+	PricePence     *int     `json:"price_pence,omitempty" validate:"omitempty,min=0"`
+	ConditionGrade *int     `json:"condition_grade,omitempty" validate:"omitempty,min=1,max=10"`
+	Packaging      *string  `json:"packaging,omitempty" validate:"omitempty,oneof=loose MOC MIB"`
+	Photos         []string `json:"photos,omitempty" validate:"omitempty,max=20,dive,url"`
+	Quantity       *int     `json:"quantity,omitempty" validate:"omitempty,min=1"`
+	MarkerID       *string  `json:"marker_id,omitempty" validate:"omitempty,uuid"`
+	Status         *string  `json:"status,omitempty" validate:"omitempty,oneof=draft active reserved sold"`
+}
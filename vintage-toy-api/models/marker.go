@@ -4,22 +4,103 @@ import "time"
 
 // MarkerResponse represents the structure of a marker returned by the API
 type MarkerResponse struct {
-	ID          string          `json:"id"`
-	Name        string          `json:"name"`
-	Description *string         `json:"description,omitempty"`
-	Latitude    float64         `json:"latitude"`
-	Longitude   float64         `json:"longitude"`
-	Region      string          `json:"region"`
-	MarkerType  string          `json:"marker_type"`
-	CreatedAt   time.Time       `json:"created_at"`
-	User        MarkerUserInfo  `json:"user"`
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description *string        `json:"description,omitempty"`
+	Latitude    float64        `json:"latitude"`
+	Longitude   float64        `json:"longitude"`
+	Region      string         `json:"region"`
+	MarkerType  string         `json:"marker_type"`
+	CreatedAt   time.Time      `json:"created_at"`
+	User        MarkerUserInfo `json:"user"`
+	DistanceM   *float64       `json:"distance_m,omitempty"`
+
+	// LocationPrecision and AccuracyM describe how far Latitude/Longitude may be from the true point
+	LocationPrecision string  `json:"location_precision"`
+	AccuracyM         float64 `json:"accuracy_m"`
+	Visibility        string  `json:"visibility"`
+
+	Schedule *EventSchedule `json:"schedule,omitempty"`
+
+	// Shops only: "open", "closed" or "unknown" when no hours are set
+	OpenStatus  *string    `json:"open_status,omitempty"`
+	NextOpensAt *time.Time `json:"next_opens_at,omitempty"`
+	ClosesAt    *time.Time `json:"closes_at,omitempty"`
+
+	// Average star rating, absent until the marker has been reviewed
+	RatingAvg   *float64 `json:"rating_avg,omitempty"`
+	RatingCount int      `json:"rating_count"`
+
+	FavouriteCount int  `json:"favourite_count"`
+	FavouritedByMe bool `json:"favourited_by_me"`
 }
 
 // MarkerUserInfo holds the user details associated with the marker
 type MarkerUserInfo struct {
-	DisplayName string  `json:"display_name"`
-	StoreName   *string `json:"store_name,omitempty"`
-	FirstName   *string `json:"first_name,omitempty"`
-	LastName    *string `json:"last_name,omitempty"`
+	DisplayName  string  `json:"display_name"`
+	StoreName    *string `json:"store_name,omitempty"`
+	FirstName    *string `json:"first_name,omitempty"`
+	LastName     *string `json:"last_name,omitempty"`
 	ProfileImage *string `json:"profile_image,omitempty"`
 }
+
+// Regions lists the values accepted by the user_markers.region check constraint
+var Regions = map[string]bool{
+	"North East":               true,
+	"North West":               true,
+	"Yorkshire and the Humber": true,
+	"West Midlands":            true,
+	"East Midlands":            true,
+	"South West":               true,
+	"South East":               true,
+	"London":                   true,
+	"East of England":          true,
+}
+
+// MarkerTypes lists the values accepted by the user_markers.marker_type check constraint
+var MarkerTypes = map[string]bool{
+	"Shop":         true,
+	"Collector":    true,
+	"Event":        true,
+	"Trade Meetup": true,
+}
+
+// LocationPrecisions lists the supported marker location precisions, finest first
+var LocationPrecisions = map[string]bool{
+	"exact":         true,
+	"street":        true,
+	"neighbourhood": true,
+	"town":          true,
+}
+
+// MarkerVisibilities lists who may see a marker: anyone, signed-in members,
+// the owner's followers, or only the owner
+var MarkerVisibilities = map[string]bool{
+	"public":    true,
+	"members":   true,
+	"followers": true,
+	"private":   true,
+}
+
+// CreateMarkerRequest struct
+type CreateMarkerRequest struct {
+	Name              string  `json:"name" validate:"required,max=200"`
+	Description       *string `json:"description,omitempty"`
+	Latitude          float64 `json:"latitude" validate:"min=-90,max=90"`
+	Longitude         float64 `json:"longitude" validate:"min=-180,max=180"`
+	Region            string  `json:"region" validate:"required"`
+	MarkerType        string  `json:"marker_type" validate:"required"`
+	LocationPrecision *string `json:"location_precision,omitempty"`
+	Visibility        *string `json:"visibility,omitempty"`
+}
+
+// UpdateMarkerRequest struct
+type UpdateMarkerRequest struct {
+	Name              *string  `json:"name,omitempty" validate:"omitempty,min=1,max=200"`
+	Description       *string  `json:"description,omitempty"`
+	Latitude          *float64 `json:"latitude,omitempty" validate:"omitempty,min=-90,max=90"`
+	Longitude         *float64 `json:"longitude,omitempty" validate:"omitempty,min=-180,max=180"`
+	Region            *string  `json:"region,omitempty"`
+	LocationPrecision *string  `json:"location_precision,omitempty"`
+	Visibility        *string  `json:"visibility,omitempty"`
+}
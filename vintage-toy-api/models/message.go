@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// CreateConversationRequest starts a conversation with one or more other users, optionally
+// about a marker or a listing, with its first message
+type CreateConversationRequest struct {
+	ParticipantIDs []string `json:"participant_ids" validate:"required,min=1,max=20,dive,uuid"`
+	MarkerID       *string  `json:"marker_id,omitempty" validate:"omitempty,uuid,excluded_with=ListingID"`
+	ListingID      *string  `json:"listing_id,omitempty" validate:"omitempty,uuid"`
+	Body           string   `json:"body" validate:"required,max=5000"`
+}
+
+// SendMessageRequest adds a message to a conversation
+type SendMessageRequest struct {
+	Body string `json:"body" validate:"required,max=5000"`
+}
+
+// UpdateConversationRequest changes how a conversation appears to the authenticated user
+type UpdateConversationRequest struct {
+	Muted    *bool `json:"muted,omitempty"`
+	Archived *bool `json:"archived,omitempty"`
+}
+
+// ConversationParticipant is a member of a conversation. Deleted accounts keep their
+// place in the conversation but are shown as "Deleted user", with no ID.
+type ConversationParticipant struct {
+	ID           *string `json:"id"`
+	DisplayName  string  `json:"display_name"`
+	ProfileImage *string `json:"profile_image,omitempty"`
+	Deleted      bool    `json:"deleted,omitempty"`
+	// Every message up to this moment has been read
+	LastReadAt *time.Time `json:"last_read_at,omitempty"`
+}
+
+// ConversationSubject is the marker or listing a conversation is about
+type ConversationSubject struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Message represents a message returned by the API. SenderID is omitted for deleted accounts.
+type Message struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	SenderID       *string   `json:"sender_id,omitempty"`
+	SenderName     string    `json:"sender_name"`
+	Body           string    `json:"body"`
+	ReadBy         []string  `json:"read_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Conversation represents a conversation as seen by one participant
+type Conversation struct {
+	ID            string                    `json:"id"`
+	Participants  []ConversationParticipant `json:"participants"`
+	Subject       *ConversationSubject      `json:"subject,omitempty"`
+	LastMessage   *Message                  `json:"last_message,omitempty"`
+	UnreadCount   int                       `json:"unread_count"`
+	Muted         bool                      `json:"muted"`
+	Archived      bool                      `json:"archived"`
+	LastMessageAt time.Time                 `json:"last_message_at"`
+	CreatedAt     time.Time                 `json:"created_at"`
+}
+
+// UnreadMessages totals the authenticated user's unread messages outside muted conversations
+type UnreadMessages struct {
+	Messages      int `json:"messages"`
+	Conversations int `json:"conversations"`
+}
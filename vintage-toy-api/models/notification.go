@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Notification represents an in-app notification returned by the API
+type Notification struct {
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	Link      *string    `json:"link,omitempty"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NotificationPreference is how one type of notification reaches the user on one channel:
+// instant, daily (email only) or off
+type NotificationPreference struct {
+	Type     string `json:"type" validate:"required"`
+	Channel  string `json:"channel" validate:"required,oneof=in_app email push"`
+	Delivery string `json:"delivery" validate:"required,oneof=instant daily off"`
+}
+
+// UpdateNotificationPreferencesRequest changes some of the authenticated user's preferences
+type UpdateNotificationPreferencesRequest struct {
+	Preferences []NotificationPreference `json:"preferences" validate:"required,min=1,max=50,dive"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// PriceStats summarises recorded sale prices, with outliers removed. The quartiles are
+// omitted when there are no prices to go on.
+type PriceStats struct {
+	// Set when the stats cover a single condition grade
+	ConditionGrade     *int   `json:"condition_grade,omitempty"`
+	Count              int    `json:"count"`
+	Outliers           int    `json:"outliers"`
+	LowerQuartilePence *int   `json:"lower_quartile_pence,omitempty"`
+	MedianPence        *int   `json:"median_pence,omitempty"`
+	UpperQuartilePence *int   `json:"upper_quartile_pence,omitempty"`
+	Confidence         string `json:"confidence"`
+}
+
+// PricePoint is the median price over one period of a price history
+type PricePoint struct {
+	Period      time.Time `json:"period"`
+	Count       int       `json:"count"`
+	MedianPence int       `json:"median_pence"`
+}
+
+// PriceHistory is what a catalogue item has sold for since Since
+type PriceHistory struct {
+	CatalogueItemID string       `json:"catalogue_item_id"`
+	Since           time.Time    `json:"since"`
+	Interval        string       `json:"interval"`
+	Overall         PriceStats   `json:"overall"`
+	ByCondition     []PriceStats `json:"by_condition"`
+	Series          []PricePoint `json:"series"`
+}
+
+// CollectionValuationItem is the estimated value of one collection entry. The estimate is
+// for the entry's own condition grade when there are enough sales at that grade, and for
+// the catalogue item as a whole otherwise.
+type CollectionValuationItem struct {
+	CollectionItemID string  `json:"collection_item_id"`
+	CatalogueItemID  *string `json:"catalogue_item_id,omitempty"`
+	Name             string  `json:"name"`
+	ConditionGrade   *int    `json:"condition_grade,omitempty"`
+	Quantity         int     `json:"quantity"`
+	UnitPence        *int    `json:"unit_pence,omitempty"`
+	TotalPence       *int    `json:"total_pence,omitempty"`
+	MatchedCondition bool    `json:"matched_condition"`
+	SampleSize       int     `json:"sample_size"`
+	Confidence       string  `json:"confidence"`
+}
+
+// CollectionValuation estimates what a collection is worth. Entries without any recorded
+// sales count towards Unvalued and add nothing to TotalPence.
+type CollectionValuation struct {
+	TotalPence int `json:"total_pence"`
+	SpentPence int `json:"spent_pence"`
+	Valued     int `json:"valued"`
+	Unvalued   int `json:"unvalued"`
+	// Number of valued entries at each confidence level
+	Confidence map[string]int            `json:"confidence"`
+	Items      []CollectionValuationItem `json:"items"`
+}
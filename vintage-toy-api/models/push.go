@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// PushSubscriptionRequest registers a browser for push notifications. It is the JSON form
+// of the browser's PushSubscription.
+type PushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" validate:"required,url,startswith=https://,max=2048"`
+	Keys     struct {
+		P256dh string `json:"p256dh" validate:"required"`
+		Auth   string `json:"auth" validate:"required"`
+	} `json:"keys"`
+}
+
+// PushSubscription is one of the authenticated user's push-enabled browsers
+type PushSubscription struct {
+	ID         string     `json:"id"`
+	Endpoint   string     `json:"endpoint"`
+	UserAgent  *string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
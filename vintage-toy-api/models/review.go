@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// ReviewableMarkerTypes lists the marker types that accept reviews
+var ReviewableMarkerTypes = map[string]bool{
+	"Shop":         true,
+	"Trade Meetup": true,
+}
+
+// ReviewRequest struct
+type ReviewRequest struct {
+	Rating int    `json:"rating" validate:"required,min=1,max=5"`
+	Body   string `json:"body" validate:"max=5000"`
+}
+
+// ReviewReplyRequest struct
+type ReviewReplyRequest struct {
+	Body string `json:"body" validate:"required,max=2000"`
+}
+
+// ReviewReply is the marker owner's public response to a review
+type ReviewReply struct {
+	Body      string    `json:"body"`
+	RepliedAt time.Time `json:"replied_at"`
+}
+
+// ReviewResponse represents a review returned by the API
+type ReviewResponse struct {
+	ID        string         `json:"id"`
+	MarkerID  string         `json:"marker_id"`
+	Rating    int            `json:"rating"`
+	Body      string         `json:"body"`
+	User      MarkerUserInfo `json:"user"`
+	Reply     *ReviewReply   `json:"reply,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// MaxRouteStops caps how many markers a single route can visit
+const MaxRouteStops = 25
+
+// PlanRouteRequest struct. Stops come from MarkerIDs plus, when ListID is set, the markers of the caller's list.
+type PlanRouteRequest struct {
+	StartLatitude  float64    `json:"start_latitude" validate:"min=-90,max=90"`
+	StartLongitude float64    `json:"start_longitude" validate:"min=-180,max=180"`
+	MarkerIDs      []string   `json:"marker_ids,omitempty" validate:"omitempty,max=25,dive,uuid"`
+	ListID         *string    `json:"list_id,omitempty" validate:"omitempty,uuid"`
+	ReturnToStart  bool       `json:"return_to_start"`
+	DepartAt       *time.Time `json:"depart_at,omitempty"`
+	SpeedKmh       *float64   `json:"speed_kmh,omitempty" validate:"omitempty,min=5,max=130"`
+	DwellMinutes   *int       `json:"dwell_minutes,omitempty" validate:"omitempty,min=0,max=480"`
+}
+
+// RouteStop is one marker on a planned route
+type RouteStop struct {
+	Position     int       `json:"position"`
+	MarkerID     string    `json:"marker_id"`
+	Name         string    `json:"name"`
+	MarkerType   string    `json:"marker_type"`
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	LegDistanceM float64   `json:"leg_distance_m"`
+	ArriveAt     time.Time `json:"arrive_at"`
+	WaitMinutes  int       `json:"wait_minutes"`
+	DepartAt     time.Time `json:"depart_at"`
+	// False when the shop is closed or the event isn't on at any point the stop can be reached
+	WithinHours bool `json:"within_hours"`
+}
+
+// RoutePlan is the planned visiting order
+type RoutePlan struct {
+	StartLatitude      float64     `json:"start_latitude"`
+	StartLongitude     float64     `json:"start_longitude"`
+	ReturnToStart      bool        `json:"return_to_start"`
+	DepartAt           time.Time   `json:"depart_at"`
+	FinishAt           time.Time   `json:"finish_at"`
+	TotalDistanceM     float64     `json:"total_distance_m"`
+	ReturnLegDistanceM *float64    `json:"return_leg_distance_m,omitempty"`
+	Stops              []RouteStop `json:"stops"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// RSVPRequest struct. OccurrenceStart picks a date of a recurring event, in RFC 3339.
+type RSVPRequest struct {
+	Status          string  `json:"status" validate:"required,oneof=going interested not_going"`
+	OccurrenceStart *string `json:"occurrence_start,omitempty"`
+}
+
+// RSVPResponse reports the caller's resulting status, which is "waitlisted" when a full occurrence is joined
+type RSVPResponse struct {
+	Status          string    `json:"status"`
+	OccurrenceStart time.Time `json:"occurrence_start"`
+	GoingCount      int       `json:"going_count"`
+	Capacity        *int      `json:"capacity,omitempty"`
+}
+
+// Attendee is a single RSVP shown to an event's organiser
+type Attendee struct {
+	DisplayName  string    `json:"display_name"`
+	FirstName    *string   `json:"first_name,omitempty"`
+	LastName     *string   `json:"last_name,omitempty"`
+	ProfileImage *string   `json:"profile_image,omitempty"`
+	Status       string    `json:"status"`
+	RespondedAt  time.Time `json:"responded_at"`
+}
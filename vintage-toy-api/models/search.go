@@ -0,0 +1,17 @@
+package models
+
+// SearchResult is a single ranked hit from the unified /search endpoint
+type SearchResult struct {
+	Type     string  `json:"type"` // "user", "shop" or "marker"
+	ID       string  `json:"id"`
+	Title    string  `json:"title"`
+	Subtitle *string `json:"subtitle,omitempty"`
+	// Snippet is HTML: escaped text with matches wrapped in <mark>
+	Snippet      string   `json:"snippet"`
+	Rank         float64  `json:"rank"`
+	ProfileImage *string  `json:"profile_image,omitempty"`
+	MarkerType   *string  `json:"marker_type,omitempty"`
+	Region       *string  `json:"region,omitempty"`
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// SwapLeg is one item passing between two participants of a swap
+type SwapLeg struct {
+	Giver            PublicUserSummary `json:"giver"`
+	Receiver         PublicUserSummary `json:"receiver"`
+	CollectionItemID *string           `json:"collection_item_id,omitempty"`
+	Name             string            `json:"name"`
+	DistanceM        *float64          `json:"distance_m,omitempty"`
+}
+
+// SwapParticipant is a participant's response to a swap proposal
+type SwapParticipant struct {
+	UserID      string     `json:"user_id"`
+	Response    string     `json:"response"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+// SwapProposal is a trade cycle found by the swap matcher
+type SwapProposal struct {
+	ID           string            `json:"id"`
+	Status       string            `json:"status"`
+	Legs         []SwapLeg         `json:"legs"`
+	Participants []SwapParticipant `json:"participants"`
+	ExpiresAt    time.Time         `json:"expires_at"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// TradeItemRequest is a collection entry included in an offer. Quantity defaults to 1.
+type TradeItemRequest struct {
+	CollectionItemID string `json:"collection_item_id" validate:"required,uuid"`
+	Quantity         *int   `json:"quantity,omitempty" validate:"omitempty,min=1"`
+}
+
+// CounterTradeOfferRequest proposes different terms in reply to an offer. Give lists the
+// sender's own entries and Receive the other party's. CashPence is paid by the sender
+// when positive and by the other party when negative.
+type CounterTradeOfferRequest struct {
+	Give      []TradeItemRequest `json:"give" validate:"max=20,dive"`
+	Receive   []TradeItemRequest `json:"receive" validate:"max=20,dive"`
+	CashPence int                `json:"cash_pence"`
+	Message   *string            `json:"message,omitempty" validate:"omitempty,max=2000"`
+}
+
+// CreateTradeOfferRequest proposes a trade to another collector
+type CreateTradeOfferRequest struct {
+	RecipientID string `json:"recipient_id" validate:"required,uuid"`
+	CounterTradeOfferRequest
+}
+
+// TradeItem is a collection entry as it was when the offer was made
+type TradeItem struct {
+	CollectionItemID *string `json:"collection_item_id,omitempty"`
+	Name             string  `json:"name"`
+	ConditionGrade   *int    `json:"condition_grade,omitempty"`
+	Packaging        *string `json:"packaging,omitempty"`
+	Quantity         int     `json:"quantity"`
+}
+
+// TradeEvent is one entry in the history of an offer
+type TradeEvent struct {
+	Action     string    `json:"action"`
+	ActorID    string    `json:"actor_id"`
+	FromStatus *string   `json:"from_status,omitempty"`
+	ToStatus   string    `json:"to_status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TradeOffer represents a trade offer returned by the API. Give and Receive are from the
+// proposer's point of view.
+type TradeOffer struct {
+	ID            string            `json:"id"`
+	ParentOfferID *string           `json:"parent_offer_id,omitempty"`
+	Proposer      PublicUserSummary `json:"proposer"`
+	Recipient     PublicUserSummary `json:"recipient"`
+	Status        string            `json:"status"`
+	CashPence     int               `json:"cash_pence"`
+	Message       *string           `json:"message,omitempty"`
+	// When each party confirmed an accepted trade; it completes once both have
+	ProposerConfirmedAt  *time.Time   `json:"proposer_confirmed_at,omitempty"`
+	RecipientConfirmedAt *time.Time   `json:"recipient_confirmed_at,omitempty"`
+	Give                 []TradeItem  `json:"give"`
+	Receive              []TradeItem  `json:"receive"`
+	History              []TradeEvent `json:"history,omitempty"`
+	CreatedAt            time.Time    `json:"created_at"`
+	UpdatedAt            time.Time    `json:"updated_at"`
+}
@@ -8,10 +8,13 @@ var Validate = validator.New()
 
 // public list of users
 type PublicUserSummary struct {
+	ID             string  `json:"id"`
 	DisplayName    string  `json:"display_name"`
 	StoreName      *string `json:"store_name,omitempty"`
 	BioDescription *string `json:"bio_description,omitempty"`
 	ProfileImage   *string `json:"profile_image,omitempty"`
+	// Set when the user shows their collection on their profile
+	CollectionPublic bool `json:"collection_public"`
 }
 
 // CreateUserRequest struct
@@ -35,12 +38,13 @@ type UserResponse struct {
 
 // UserBioResponse struct
 type UserBioResponse struct {
-	DisplayName    string  `json:"display_name" validate:"required"`
-	StoreName      *string `json:"store_name,omitempty"`
-	BioDescription *string `json:"bio_description,omitempty"`
-	ProfileImage   *string `json:"profile_image,omitempty"`
-	ShowRealName   *bool   `json:"show_real_name,omitempty"`
-	UpdatedAt      string  `json:"updated_at"`
+	DisplayName      string  `json:"display_name" validate:"required"`
+	StoreName        *string `json:"store_name,omitempty"`
+	BioDescription   *string `json:"bio_description,omitempty"`
+	ProfileImage     *string `json:"profile_image,omitempty"`
+	ShowRealName     *bool   `json:"show_real_name,omitempty"`
+	CollectionPublic *bool   `json:"collection_public,omitempty"`
+	UpdatedAt        string  `json:"updated_at"`
 }
 
 // SearchUserRequest struct
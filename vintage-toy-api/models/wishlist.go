@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// WishlistItem is a catalogue item the user wants, with the offers they would accept
+type WishlistItem struct {
+	ID            string        `json:"id"`
+	CatalogueItem CatalogueItem `json:"catalogue_item"`
+	MaxPricePence *int          `json:"max_price_pence,omitempty"`
+	MinCondition  *int          `json:"min_condition,omitempty"`
+	Packagings    []string      `json:"packagings"`
+	Notes         *string       `json:"notes,omitempty"`
+	MatchCount    int           `json:"match_count"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// SetWishlistItemRequest replaces the user's wishlist entry for a catalogue item.
+// Omitted limits accept any price, condition or packaging.
+type SetWishlistItemRequest struct {
+	MaxPricePence *int     `json:"max_price_pence,omitempty" validate:"omitempty,min=0"`
+	MinCondition  *int     `json:"min_condition,omitempty" validate:"omitempty,min=1,max=10"`
+	Packagings    []string `json:"packagings,omitempty" validate:"omitempty,max=3,dive,oneof=loose MOC MIB"`
+	Notes         *string  `json:"notes,omitempty" validate:"omitempty,max=2000"`
+}
+
+// WishlistMatch is another user's offer that satisfies a wishlist entry
+type WishlistMatch struct {
+	WishlistItemID  string            `json:"wishlist_item_id"`
+	CatalogueItemID string            `json:"catalogue_item_id"`
+	Name            string            `json:"name"`
+	Source          string            `json:"source"`
+	SourceID        string            `json:"source_id"`
+	Seller          PublicUserSummary `json:"seller"`
+	ConditionGrade  *int              `json:"condition_grade,omitempty"`
+	Packaging       *string           `json:"packaging,omitempty"`
+	PricePence      *int              `json:"price_pence,omitempty"`
+	// Closest distance between the two users' markers; omitted when either has none
+	DistanceM *float64 `json:"distance_m,omitempty"`
+}
@@ -0,0 +1,366 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/mailer"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AppURL is the frontend that notification links are relative to
+var AppURL = "https://toy.josephbartram.co.uk"
+
+// APIURL is where this API is served, for unsubscribe links
+var APIURL = "https://blastfromthepastbackend.onrender.com"
+
+// DigestHour is the hour of the day, UK time, at which daily digests go out
+const DigestHour = 8
+
+// AllTypes in an unsubscribe token stands for every type of notification
+const AllTypes = "all"
+
+// unsubscribeKey signs unsubscribe links. It is set with SetUnsubscribeSecret.
+var unsubscribeKey []byte
+
+// ErrInvalidToken is returned for unsubscribe tokens that weren't signed by this API
+var ErrInvalidToken = errors.New("invalid unsubscribe link")
+
+// emailBatch caps how many instant emails one run sends
+const emailBatch = 100
+
+// maxEmailAttempts is how many times an email is tried before it is marked failed. Each
+// retry waits twice as long as the one before, starting from emailRetryDelay.
+const (
+	maxEmailAttempts = 5
+	emailRetryDelay  = 5 * time.Minute
+)
+
+// emailClaimTimeout is how long a run has to send the emails it claimed. Claims left by a
+// run that died are released once it has passed.
+const emailClaimTimeout = 15 * time.Minute
+
+// SetUnsubscribeSecret derives the key that signs unsubscribe links from secret, so the
+// links can't be used as, or forged from, tokens signed with the secret itself
+func SetUnsubscribeSecret(secret string) error {
+	if secret == "" {
+		return errors.New("no unsubscribe secret")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("unsubscribe-v1"))
+	unsubscribeKey = mac.Sum(nil)
+	return nil
+}
+
+// UnsubscribeToken signs a token that turns off email for a type of notification, or
+// AllTypes, without signing in
+func UnsubscribeToken(userID uuid.UUID, notificationType string) string {
+	payload := userID.String() + ":" + notificationType
+	mac := hmac.New(sha256.New, unsubscribeKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ParseUnsubscribeToken checks the signature of a token from UnsubscribeToken and returns
+// the user and notification type it is for
+func ParseUnsubscribeToken(token string) (uuid.UUID, string, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, unsubscribeKey)
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+
+	id, notificationType, ok := strings.Cut(string(payload), ":")
+	if !ok {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	userID, err := uuid.Parse(id)
+	if err != nil || (notificationType != AllTypes && !IsType(notificationType)) {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	return userID, notificationType, nil
+}
+
+// unsubscribeURL is the one-click unsubscribe link for a user and type
+func unsubscribeURL(userID uuid.UUID, notificationType string) string {
+	return APIURL + "/notifications/unsubscribe?token=" + url.QueryEscape(UnsubscribeToken(userID, notificationType))
+}
+
+// unsubscribeHeaders let mail clients offer one-click unsubscribe (RFC 8058)
+func unsubscribeHeaders(link string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + link + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+// dropUnwantedEmails stops queued emails the user has since turned off
+func dropUnwantedEmails(db *sql.DB) error {
+	_, err := db.Exec(`
+		UPDATE notifications n
+		SET email_status = NULL
+		FROM notification_preferences p
+		WHERE n.email_status = 'pending'
+			AND p.user_id = n.user_id AND p.type = n.type AND p.channel = 'email' AND p.delivery = 'off'
+	`)
+	return err
+}
+
+// releaseEmailClaims returns emails claimed by a run that never finished to the queue,
+// or marks them failed if they have used up their attempts
+func releaseEmailClaims(db *sql.DB) error {
+	_, err := db.Exec(`
+		UPDATE notifications
+		SET email_status = CASE WHEN email_attempts >= $1 THEN 'failed' ELSE 'pending' END
+		WHERE email_status = 'sending' AND email_retry_at <= NOW()
+	`, maxEmailAttempts)
+	return err
+}
+
+// prepareEmailRun tidies the queue before a run claims from it
+func prepareEmailRun(db *sql.DB) error {
+	if err := dropUnwantedEmails(db); err != nil {
+		return err
+	}
+	return releaseEmailClaims(db)
+}
+
+// finishEmails records the outcome of sending claimed emails. Failures go back in the
+// queue with a backoff until they run out of attempts.
+func finishEmails(db *sql.DB, ids []string, sendErr error) error {
+	if sendErr == nil {
+		_, err := db.Exec(`
+			UPDATE notifications SET email_status = 'sent', emailed_at = NOW(), email_retry_at = NULL
+			WHERE id = ANY($1::uuid[]) AND email_status = 'sending'
+		`, pq.Array(ids))
+		return err
+	}
+	_, err := db.Exec(`
+		UPDATE notifications
+		SET email_status = CASE WHEN email_attempts >= $2 THEN 'failed' ELSE 'pending' END,
+			email_retry_at = NOW() + $3 * power(2, email_attempts - 1) * INTERVAL '1 second'
+		WHERE id = ANY($1::uuid[]) AND email_status = 'sending'
+	`, pq.Array(ids), maxEmailAttempts, emailRetryDelay.Seconds())
+	return err
+}
+
+// SendPendingEmails emails notifications queued for instant delivery. Each is claimed
+// before sending, so concurrent runs on several instances never send one twice, and only
+// marked sent once the mailer has taken it.
+func SendPendingEmails(db *sql.DB, m mailer.Mailer) error {
+	if err := prepareEmailRun(db); err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`
+		UPDATE notifications n
+		SET email_status = CASE WHEN u.is_deleted THEN NULL ELSE 'sending' END,
+			email_attempts = n.email_attempts + 1, email_retry_at = NOW() + $2 * INTERVAL '1 second'
+		FROM users u
+		WHERE n.id IN (
+			SELECT id FROM notifications
+			WHERE email_status = 'pending' AND NOT email_digest AND (email_retry_at IS NULL OR email_retry_at <= NOW())
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		) AND u.id = n.user_id
+		RETURNING n.id, n.user_id, u.email, u.is_deleted, n.type, n.title, n.body, n.link
+	`, emailBatch, emailClaimTimeout.Seconds())
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		id, email, notificationType, title, body string
+		userID                                   uuid.UUID
+		link                                     sql.NullString
+	}
+	var emails []pending
+	for rows.Next() {
+		var p pending
+		var deleted bool
+		if err := rows.Scan(&p.id, &p.userID, &p.email, &deleted, &p.notificationType, &p.title, &p.body, &p.link); err != nil {
+			rows.Close()
+			return err
+		}
+		if !deleted {
+			emails = append(emails, p)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range emails {
+		unsubscribe := unsubscribeURL(p.userID, p.notificationType)
+		var body strings.Builder
+		if p.body != "" {
+			body.WriteString(p.body + "\n\n")
+		}
+		if p.link.Valid {
+			body.WriteString(AppURL + p.link.String + "\n\n")
+		}
+		fmt.Fprintf(&body, "--\nDon't want these emails? Unsubscribe: %s\nManage notifications: %s/settings/notifications\n",
+			unsubscribe, AppURL)
+
+		sendErr := m.Send(mailer.Message{To: p.email, Subject: p.title, Body: body.String(), Headers: unsubscribeHeaders(unsubscribe)})
+		if sendErr != nil {
+			log.Printf("Notification email %s failed: %v", p.id, sendErr)
+		}
+		if err := finishEmails(db, []string{p.id}, sendErr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DigestTime returns when the digest due on the day of now goes out
+func DigestTime(now time.Time) time.Time {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		london = time.UTC
+	}
+	local := now.In(london)
+	return time.Date(local.Year(), local.Month(), local.Day(), DigestHour, 0, 0, 0, london)
+}
+
+// SendDigests emails each user one digest of the notifications queued for it before cutoff.
+// A user's notifications are only marked sent once their digest has gone; a failed digest
+// is retried with the same backoff as instant emails.
+func SendDigests(db *sql.DB, m mailer.Mailer, cutoff time.Time) error {
+	if err := prepareEmailRun(db); err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`
+		SELECT DISTINCT n.user_id
+		FROM notifications n
+		JOIN users u ON n.user_id = u.id
+		WHERE n.email_status = 'pending' AND n.email_digest AND n.created_at < $1
+			AND (n.email_retry_at IS NULL OR n.email_retry_at <= NOW()) AND u.is_deleted = FALSE
+	`, cutoff)
+	if err != nil {
+		return err
+	}
+	var users []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		users = append(users, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, userID := range users {
+		if err := sendDigest(db, m, userID, cutoff); err != nil {
+			log.Printf("Digest for %s failed: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// sendDigest claims and emails one user's digest. The claim is committed before the
+// email is sent, so no transaction is held open while the mailer works.
+func sendDigest(db *sql.DB, m mailer.Mailer, userID uuid.UUID, cutoff time.Time) error {
+	var email string
+	if err := db.QueryRow("SELECT email FROM users WHERE id = $1", userID).Scan(&email); err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`
+		UPDATE notifications
+		SET email_status = 'sending', email_attempts = email_attempts + 1, email_retry_at = NOW() + $3 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM notifications
+			WHERE user_id = $1 AND email_status = 'pending' AND email_digest AND created_at < $2
+				AND (email_retry_at IS NULL OR email_retry_at <= NOW())
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, title, body, link, created_at
+	`, userID, cutoff, emailClaimTimeout.Seconds())
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		id, title, body string
+		link            sql.NullString
+		createdAt       time.Time
+	}
+	var entries []entry
+	var ids []string
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.title, &e.body, &e.link, &e.createdAt); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+		ids = append(ids, e.id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		// Another instance got there first
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].createdAt.Before(entries[j].createdAt) })
+
+	unsubscribe := unsubscribeURL(userID, AllTypes)
+	var body strings.Builder
+	body.WriteString("Here's what happened since your last digest:\n\n")
+	for _, e := range entries {
+		body.WriteString("• " + e.title + "\n")
+		if e.body != "" {
+			body.WriteString("  " + e.body + "\n")
+		}
+		if e.link.Valid {
+			body.WriteString("  " + AppURL + e.link.String + "\n")
+		}
+		body.WriteString("\n")
+	}
+	fmt.Fprintf(&body, "--\nDon't want notification emails? Unsubscribe: %s\nManage notifications: %s/settings/notifications\n",
+		unsubscribe, AppURL)
+
+	subject := fmt.Sprintf("Your daily digest: %d update", len(entries))
+	if len(entries) != 1 {
+		subject += "s"
+	}
+	sendErr := m.Send(mailer.Message{To: email, Subject: subject, Body: body.String(), Headers: unsubscribeHeaders(unsubscribe)})
+	if err := finishEmails(db, ids, sendErr); err != nil {
+		return err
+	}
+	return sendErr
+}
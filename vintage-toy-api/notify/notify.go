@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+// Notification types
+const (
+	TypeEventReminder    = "event_reminder"
+	TypeWaitlistPromoted = "waitlist_promoted"
+	TypeWishlistMatch    = "wishlist_match"
+	TypeTradeOffer       = "trade_offer"
+	TypeSwapProposal     = "swap_proposal"
+	TypeNewMessage       = "new_message"
+	TypeNewFollower      = "new_follower"
+)
+
+// Types lists every notification type, in the order preferences are shown
+var Types = []string{
+	TypeNewMessage,
+	TypeTradeOffer,
+	TypeSwapProposal,
+	TypeWishlistMatch,
+	TypeEventReminder,
+	TypeWaitlistPromoted,
+	TypeNewFollower,
+}
+
+// Delivery channels
+const (
+	ChannelInApp = "in_app"
+	ChannelEmail = "email"
+	ChannelPush  = "push"
+)
+
+// Channels lists every delivery channel
+var Channels = []string{ChannelInApp, ChannelEmail, ChannelPush}
+
+// How a type of notification is delivered on a channel. Only email has a daily digest.
+const (
+	DeliveryInstant = "instant"
+	DeliveryDaily   = "daily"
+	DeliveryOff     = "off"
+)
+
+// emailDefaults is how each type reaches users by email until they choose otherwise.
+// Anything not listed is sent at once; everything is shown in-app and pushed by default.
+var emailDefaults = map[string]string{
+	TypeWishlistMatch: DeliveryDaily,
+	TypeNewFollower:   DeliveryDaily,
+}
+
+// DefaultDelivery returns how a type of notification is delivered on a channel for users
+// without a preference of their own
+func DefaultDelivery(notificationType, channel string) string {
+	if channel == ChannelEmail {
+		if d, ok := emailDefaults[notificationType]; ok {
+			return d
+		}
+	}
+	return DeliveryInstant
+}
+
+// IsType reports whether t is a known notification type
+func IsType(t string) bool {
+	for _, known := range Types {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Execer is satisfied by both *sql.DB and *sql.Tx, so a notification can be
+// written in the same transaction as the change that caused it
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Notification is a message for a single user
+type Notification struct {
+	UserID uuid.UUID
+	Type   string
+	Title  string
+	Body   string
+	Link   string
+}
+
+// Send records a notification and queues it on each channel the user's preferences for
+// its type allow. Emails and pushes are sent by the background jobs once the transaction
+// commits; pushes are only queued for users with a push subscription.
+func Send(ex Execer, n Notification) error {
+	var link *string
+	if n.Link != "" {
+		link = &n.Link
+	}
+
+	_, err := ex.Exec(`
+		INSERT INTO notifications (user_id, type, title, body, link, in_app, email_status, email_digest, push_status)
+		SELECT $1, $2, $3, $4, $5, p.in_app <> 'off',
+			CASE WHEN p.email <> 'off' THEN 'pending' END, p.email = 'daily',
+			CASE WHEN p.push <> 'off' AND EXISTS (SELECT 1 FROM push_subscriptions WHERE user_id = $1)
+				THEN 'pending' END
+		FROM (
+			SELECT
+				COALESCE((SELECT delivery FROM notification_preferences
+					WHERE user_id = $1 AND type = $2 AND channel = 'in_app'), $6) AS in_app,
+				COALESCE((SELECT delivery FROM notification_preferences
+					WHERE user_id = $1 AND type = $2 AND channel = 'email'), $7) AS email,
+				COALESCE((SELECT delivery FROM notification_preferences
+					WHERE user_id = $1 AND type = $2 AND channel = 'push'), $8) AS push
+		) p
+	`, n.UserID, n.Type, n.Title, n.Body, link,
+		DefaultDelivery(n.Type, ChannelInApp), DefaultDelivery(n.Type, ChannelEmail), DefaultDelivery(n.Type, ChannelPush))
+	return err
+}
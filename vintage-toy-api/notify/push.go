@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/webpush"
+
+	"github.com/google/uuid"
+)
+
+// pushBatch caps how many notifications one run pushes
+const pushBatch = 100
+
+// pushPayload is what the service worker receives for a notification
+type pushPayload struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	URL   string `json:"url"`
+}
+
+// dropUnwantedPushes stops queued pushes the user has since turned off
+func dropUnwantedPushes(db *sql.DB) error {
+	_, err := db.Exec(`
+		UPDATE notifications n
+		SET push_status = NULL
+		FROM notification_preferences p
+		WHERE n.push_status = 'pending'
+			AND p.user_id = n.user_id AND p.type = n.type AND p.channel = 'push' AND p.delivery = 'off'
+	`)
+	return err
+}
+
+// SendPendingPushes pushes queued notifications to every browser the user has subscribed.
+// Each is claimed before sending, so concurrent runs on several instances never push one
+// twice. Subscriptions the push service reports gone are deleted.
+func SendPendingPushes(db *sql.DB, s *webpush.Sender) error {
+	if err := dropUnwantedPushes(db); err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`
+		UPDATE notifications
+		SET push_status = 'sent', pushed_at = NOW()
+		WHERE id IN (
+			SELECT id FROM notifications
+			WHERE push_status = 'pending'
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, type, title, body, link
+	`, pushBatch)
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		payload pushPayload
+		userID  uuid.UUID
+	}
+	var pushes []pending
+	for rows.Next() {
+		var p pending
+		var link sql.NullString
+		if err := rows.Scan(&p.payload.ID, &p.userID, &p.payload.Type, &p.payload.Title, &p.payload.Body, &link); err != nil {
+			rows.Close()
+			return err
+		}
+		p.payload.URL = AppURL + link.String
+		pushes = append(pushes, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range pushes {
+		if err := push(db, s, p.userID, p.payload); err != nil {
+			log.Printf("Push notification %s failed: %v", p.payload.ID, err)
+			if _, err := db.Exec("UPDATE notifications SET push_status = 'failed' WHERE id = $1", p.payload.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// push sends one notification to each of a user's subscriptions. It fails only when no
+// subscription could be reached.
+func push(db *sql.DB, s *webpush.Sender, userID uuid.UUID, payload pushPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query("SELECT id, endpoint, p256dh, auth FROM push_subscriptions WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+	type subscription struct {
+		id string
+		webpush.Subscription
+	}
+	var subs []subscription
+	for rows.Next() {
+		var sub subscription
+		if err := rows.Scan(&sub.id, &sub.Endpoint, &sub.P256dh, &sub.Auth); err != nil {
+			rows.Close()
+			return err
+		}
+		subs = append(subs, sub)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var lastErr error
+	delivered := 0
+	for _, sub := range subs {
+		err := s.Send(sub.Subscription, body)
+		switch {
+		case errors.Is(err, webpush.ErrSubscriptionGone):
+			if _, err := db.Exec("DELETE FROM push_subscriptions WHERE id = $1", sub.id); err != nil {
+				return err
+			}
+		case err != nil:
+			lastErr = err
+		default:
+			delivered++
+			if _, err := db.Exec("UPDATE push_subscriptions SET last_used_at = NOW() WHERE id = $1", sub.id); err != nil {
+				return err
+			}
+		}
+	}
+	if delivered == 0 && lastErr != nil {
+		return lastErr
+	}
+	return nil
+}
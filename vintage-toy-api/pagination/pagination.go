@@ -0,0 +1,178 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Options describes the sorts and limits a list endpoint accepts.
+// Sorts maps a public sort name (e.g. "created_at") to the SQL expression it orders by.
+type Options struct {
+	Sorts        map[string]string
+	DefaultSort  string
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Cursor is the keyset position of the last row on a page
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Params holds the parsed limit, sort and cursor for a list request
+type Params struct {
+	Limit    int
+	SortName string
+	SortExpr string
+	Desc     bool
+	After    *Cursor
+}
+
+// Page is the response envelope shared by every list endpoint
+type Page[T any] struct {
+	Data       []T     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// Encode turns a cursor into an opaque, URL-safe token
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode
+func DecodeCursor(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// FromRequest reads limit, sort and cursor from the query string.
+// A leading "-" on the sort name (e.g. sort=-created_at) orders descending.
+func FromRequest(r *http.Request, opts Options) (Params, error) {
+	q := r.URL.Query()
+
+	defaultLimit, maxLimit := opts.DefaultLimit, opts.MaxLimit
+	if defaultLimit <= 0 {
+		defaultLimit = DefaultLimit
+	}
+	if maxLimit <= 0 {
+		maxLimit = MaxLimit
+	}
+
+	p := Params{Limit: defaultLimit}
+
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return p, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		p.Limit = limit
+	}
+
+	sort := q.Get("sort")
+	if sort == "" {
+		sort = opts.DefaultSort
+	}
+	if strings.HasPrefix(sort, "-") {
+		p.Desc = true
+		sort = strings.TrimPrefix(sort, "-")
+	}
+
+	expr, ok := opts.Sorts[sort]
+	if !ok {
+		return p, fmt.Errorf("unsupported sort %q", sort)
+	}
+	p.SortName = sort
+	p.SortExpr = expr
+
+	if token := q.Get("cursor"); token != "" {
+		c, err := DecodeCursor(token)
+		if err != nil {
+			return p, err
+		}
+		// A cursor is only meaningful for the ordering it was issued under
+		if c.Sort != p.sortKey() {
+			return p, ErrInvalidCursor
+		}
+		p.After = c
+	}
+
+	return p, nil
+}
+
+func (p Params) sortKey() string {
+	if p.Desc {
+		return "-" + p.SortName
+	}
+	return p.SortName
+}
+
+// Where returns the keyset condition for rows after the cursor, using
+// placeholders starting at $argPos. It returns an empty clause on the first page.
+func (p Params) Where(idColumn string, argPos int) (string, []interface{}) {
+	if p.After == nil {
+		return "", nil
+	}
+
+	op := ">"
+	if p.Desc {
+		op = "<"
+	}
+	clause := fmt.Sprintf("(%s, %s) %s ($%d, $%d)", p.SortExpr, idColumn, op, argPos, argPos+1)
+	return clause, []interface{}{p.After.Value, p.After.ID}
+}
+
+// OrderBy returns the ORDER BY and LIMIT clauses, fetching one extra row so
+// NewPage can tell whether another page exists.
+func (p Params) OrderBy(idColumn string) string {
+	dir := "ASC"
+	if p.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, %s %s LIMIT %d", p.SortExpr, dir, idColumn, dir, p.Limit+1)
+}
+
+// SortValue returns the SQL that selects the sort key as text, for building the next cursor
+func (p Params) SortValue() string {
+	return fmt.Sprintf("(%s)::text", p.SortExpr)
+}
+
+// NewPage trims the extra row fetched by OrderBy and builds the next cursor from
+// the last row kept. keys[i] holds the (sort value, id) pair of items[i].
+func NewPage[T any](items []T, keys [][2]string, p Params) Page[T] {
+	page := Page[T]{Data: items}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+
+	if len(items) > p.Limit {
+		page.Data = items[:p.Limit]
+		last := keys[p.Limit-1]
+		next := Cursor{Sort: p.sortKey(), Value: last[0], ID: last[1]}.Encode()
+		page.NextCursor = &next
+	}
+
+	return page
+}
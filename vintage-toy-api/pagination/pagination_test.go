@@ -0,0 +1,148 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var testOptions = Options{
+	Sorts:       map[string]string{"created_at": "t.created_at", "name": "lower(t.name)"},
+	DefaultSort: "-created_at",
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := Cursor{Sort: "-created_at", Value: "2025-03-01 10:00:00", ID: "5f0c6b7e-4c1e-4a8e-9f5e-2d7a1f3b9c01"}
+	got, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != c {
+		t.Errorf("got %+v, want %+v", *got, c)
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"s":"name","v":"a","id":"1"}`))},
+		{"not JSON", encode("not json")},
+		{"wrong shape", encode(`["name","a","1"]`)},
+		{"missing ID", encode(`{"s":"name","v":"a"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.token); err != ErrInvalidCursor {
+				t.Errorf("error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	nameCursor := Cursor{Sort: "name", Value: "a", ID: "1"}.Encode()
+
+	tests := []struct {
+		name    string
+		query   string
+		want    Params
+		wantErr bool
+	}{
+		{"defaults", "", Params{Limit: DefaultLimit, SortName: "created_at", SortExpr: "t.created_at", Desc: true}, false},
+		{"ascending", "sort=name&limit=5", Params{Limit: 5, SortName: "name", SortExpr: "lower(t.name)"}, false},
+		{"cursor", "sort=name&cursor=" + nameCursor,
+			Params{Limit: DefaultLimit, SortName: "name", SortExpr: "lower(t.name)", After: &Cursor{Sort: "name", Value: "a", ID: "1"}}, false},
+		{"cursor from another sort", "sort=-name&cursor=" + nameCursor, Params{}, true},
+		{"tampered cursor", "sort=name&cursor=" + nameCursor[:len(nameCursor)-3], Params{}, true},
+		{"unsupported sort", "sort=password", Params{}, true},
+		{"limit too small", "limit=0", Params{}, true},
+		{"limit too large", "limit=101", Params{}, true},
+		{"limit not a number", "limit=ten", Params{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromRequest(httptest.NewRequest("GET", "/items?"+tt.query, nil), testOptions)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamsSQL(t *testing.T) {
+	after := &Cursor{Value: "2025-03-01", ID: "9"}
+	tests := []struct {
+		name      string
+		params    Params
+		wantWhere string
+		wantArgs  []interface{}
+		wantOrder string
+	}{
+		{"first page ascending", Params{Limit: 10, SortExpr: "t.created_at"},
+			"", nil, "ORDER BY t.created_at ASC, t.id ASC LIMIT 11"},
+		{"first page descending", Params{Limit: 10, SortExpr: "t.created_at", Desc: true},
+			"", nil, "ORDER BY t.created_at DESC, t.id DESC LIMIT 11"},
+		{"later page ascending", Params{Limit: 10, SortExpr: "t.created_at", After: after},
+			"(t.created_at, t.id) > ($3, $4)", []interface{}{"2025-03-01", "9"}, "ORDER BY t.created_at ASC, t.id ASC LIMIT 11"},
+		{"later page descending", Params{Limit: 10, SortExpr: "t.created_at", Desc: true, After: after},
+			"(t.created_at, t.id) < ($3, $4)", []interface{}{"2025-03-01", "9"}, "ORDER BY t.created_at DESC, t.id DESC LIMIT 11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := tt.params.Where("t.id", 3)
+			if where != tt.wantWhere || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("Where = %q %v, want %q %v", where, args, tt.wantWhere, tt.wantArgs)
+			}
+			if order := tt.params.OrderBy("t.id"); order != tt.wantOrder {
+				t.Errorf("OrderBy = %q, want %q", order, tt.wantOrder)
+			}
+		})
+	}
+
+	if got := (Params{SortExpr: "lower(t.name)"}).SortValue(); got != "(lower(t.name))::text" {
+		t.Errorf("SortValue = %q", got)
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	p := Params{Limit: 2, SortName: "name", Desc: true}
+	keys := [][2]string{{"c", "3"}, {"b", "2"}, {"a", "1"}}
+
+	page := NewPage([]string{"c", "b", "a"}, keys, p)
+	if !reflect.DeepEqual(page.Data, []string{"c", "b"}) {
+		t.Errorf("Data = %v", page.Data)
+	}
+	if page.NextCursor == nil {
+		t.Fatal("NextCursor is nil")
+	}
+	next, err := DecodeCursor(*page.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *next != (Cursor{Sort: "-name", Value: "b", ID: "2"}) {
+		t.Errorf("next cursor = %+v", *next)
+	}
+
+	last := NewPage([]string{"a"}, keys[2:], p)
+	if last.NextCursor != nil {
+		t.Errorf("NextCursor = %q on the last page", *last.NextCursor)
+	}
+	if empty := NewPage[string](nil, nil, p); empty.Data == nil {
+		t.Error("Data is nil for an empty page")
+	}
+}
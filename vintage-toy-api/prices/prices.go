@@ -0,0 +1,85 @@
+// Package prices turns observed sale prices into valuation estimates
+package prices
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// MinForOutliers is the fewest prices from which outliers are filtered. Below it there
+	// is too little to tell what an unusual price is.
+	MinForOutliers = 4
+	// fenceFactor is how many interquartile ranges beyond the quartiles a price may lie
+	fenceFactor = 1.5
+)
+
+// Confidence levels of an estimate, by how many prices it is based on
+const (
+	ConfidenceNone   = "none"
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
+)
+
+// Summary describes a set of prices, in pence
+type Summary struct {
+	Count         int
+	LowerQuartile float64
+	Median        float64
+	UpperQuartile float64
+	Confidence    string
+}
+
+// quantile interpolates between the closest ranks of sorted values, like percentile_cont
+func quantile(sorted []int, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	if lower+1 >= len(sorted) {
+		return float64(sorted[lower])
+	}
+	frac := pos - float64(lower)
+	return float64(sorted[lower]) + frac*float64(sorted[lower+1]-sorted[lower])
+}
+
+// Fences returns the range outside which a price is an outlier: more than 1.5
+// interquartile ranges beyond the quartiles. ok is false when there are fewer than
+// MinForOutliers prices, in which case no price is treated as an outlier.
+func Fences(values []int) (low, high float64, ok bool) {
+	if len(values) < MinForOutliers {
+		return 0, 0, false
+	}
+	sorted := append([]int{}, values...)
+	sort.Ints(sorted)
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	iqr := q3 - q1
+	return q1 - fenceFactor*iqr, q3 + fenceFactor*iqr, true
+}
+
+// Confidence rates an estimate based on n prices
+func Confidence(n int) string {
+	switch {
+	case n == 0:
+		return ConfidenceNone
+	case n < 5:
+		return ConfidenceLow
+	case n < 15:
+		return ConfidenceMedium
+	default:
+		return ConfidenceHigh
+	}
+}
+
+// Summarise returns the quartiles of values. Filter outliers with Fences first.
+func Summarise(values []int) Summary {
+	s := Summary{Count: len(values), Confidence: Confidence(len(values))}
+	if len(values) == 0 {
+		return s
+	}
+	sorted := append([]int{}, values...)
+	sort.Ints(sorted)
+	s.LowerQuartile = quantile(sorted, 0.25)
+	s.Median = quantile(sorted, 0.5)
+	s.UpperQuartile = quantile(sorted, 0.75)
+	return s
+}
@@ -0,0 +1,85 @@
+package prices
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFences(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []int
+		low, high float64
+		ok        bool
+	}{
+		{"none", nil, 0, 0, false},
+		{"fewer than four", []int{100, 200, 9000}, 0, 0, false},
+		{"all equal", []int{500, 500, 500, 500}, 500, 500, true},
+		{"interpolated quartiles", []int{4, 1, 3, 2}, -0.5, 5.5, true},
+		{"one high outlier", []int{300, 100, 5000, 200, 400}, -100, 700, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			low, high, ok := Fences(tt.values)
+			if low != tt.low || high != tt.high || ok != tt.ok {
+				t.Errorf("Fences = %v, %v, %v, want %v, %v, %v", low, high, ok, tt.low, tt.high, tt.ok)
+			}
+		})
+	}
+}
+
+func TestFencesFlagOutliers(t *testing.T) {
+	values := []int{1200, 1500, 1400, 1350, 1450, 50, 9900}
+	low, high, ok := Fences(values)
+	if !ok {
+		t.Fatal("Fences not ok")
+	}
+
+	var outliers []int
+	for _, v := range values {
+		if float64(v) < low || float64(v) > high {
+			outliers = append(outliers, v)
+		}
+	}
+	if !reflect.DeepEqual(outliers, []int{50, 9900}) {
+		t.Errorf("outliers = %v, want [50 9900]", outliers)
+	}
+}
+
+func TestSummarise(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int
+		want   Summary
+	}{
+		{"none", nil, Summary{Confidence: ConfidenceNone}},
+		{"one", []int{750}, Summary{Count: 1, LowerQuartile: 750, Median: 750, UpperQuartile: 750, Confidence: ConfidenceLow}},
+		{"all equal", []int{500, 500, 500, 500, 500},
+			Summary{Count: 5, LowerQuartile: 500, Median: 500, UpperQuartile: 500, Confidence: ConfidenceMedium}},
+		{"unsorted", []int{4, 1, 3, 2}, Summary{Count: 4, LowerQuartile: 1.75, Median: 2.5, UpperQuartile: 3.25, Confidence: ConfidenceLow}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Summarise(tt.values); got != tt.want {
+				t.Errorf("Summarise = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	values := []int{3, 1, 2}
+	Summarise(values)
+	if !reflect.DeepEqual(values, []int{3, 1, 2}) {
+		t.Errorf("Summarise reordered its input: %v", values)
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	for n, want := range map[int]string{
+		0: ConfidenceNone, 1: ConfidenceLow, 4: ConfidenceLow, 5: ConfidenceMedium,
+		14: ConfidenceMedium, 15: ConfidenceHigh, 100: ConfidenceHigh,
+	} {
+		if got := Confidence(n); got != want {
+			t.Errorf("Confidence(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+// Package realtime fans out changes announced by Postgres on the realtime channel to the
+// streaming clients of this instance. Every instance listens, so a change made through
+// one reaches clients connected to any other.
+package realtime
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres notification channel the schema's triggers announce changes on
+const Channel = "realtime"
+
+// Event types
+const (
+	TypeMarkerCreated = "marker_created"
+	TypeMarkerUpdated = "marker_updated"
+	TypeMarkerDeleted = "marker_deleted"
+	TypeMessage       = "message"
+	TypeNotification  = "notification"
+	// TypeResync tells clients that events may have been missed while the connection to
+	// the database was down, so they should fetch afresh
+	TypeResync = "resync"
+	// TypeRelationsChanged says the users in UserIDs have blocked, muted, followed or
+	// stopped following someone, or lifted a block or mute. It is handled by the hub and
+	// never sent to clients.
+	TypeRelationsChanged = "relations_changed"
+)
+
+// subscriberBuffer is how many events a client may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// Default is the process-wide hub, started from main
+var Default = NewHub()
+
+// MarkerChange is a marker as announced by the database, at its public location
+type MarkerChange struct {
+	ID         uuid.UUID `json:"id"`
+	OwnerID    uuid.UUID `json:"owner_id"`
+	Name       string    `json:"name,omitempty"`
+	MarkerType string    `json:"marker_type,omitempty"`
+	Region     string    `json:"region,omitempty"`
+	Visibility string    `json:"visibility,omitempty"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	// Where the marker was and who could see it before an update
+	PreviousLatitude   *float64 `json:"previous_latitude,omitempty"`
+	PreviousLongitude  *float64 `json:"previous_longitude,omitempty"`
+	PreviousVisibility *string  `json:"previous_visibility,omitempty"`
+}
+
+// Event is a change sent to clients. UserIDs are the users a message, notification or
+// resync is for, and are not passed on; a resync without them is for everyone.
+type Event struct {
+	Type           string          `json:"type"`
+	Marker         *MarkerChange   `json:"marker,omitempty"`
+	ConversationID string          `json:"conversation_id,omitempty"`
+	MessageID      string          `json:"message_id,omitempty"`
+	Notification   json.RawMessage `json:"notification,omitempty"`
+	UserIDs        []uuid.UUID     `json:"user_ids,omitempty"`
+}
+
+// BBox is an area of the map a client is watching
+type BBox struct {
+	West, South, East, North float64
+}
+
+// ParseBBox reads a "west,south,east,north" bounding box in degrees
+func ParseBBox(s string) (BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return BBox{}, errors.New("bbox must be west,south,east,north")
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return BBox{}, errors.New("bbox must be west,south,east,north")
+		}
+		v[i] = f
+	}
+	b := BBox{West: v[0], South: v[1], East: v[2], North: v[3]}
+	if b.West < -180 || b.East > 180 || b.South < -90 || b.North > 90 || b.West > b.East || b.South > b.North {
+		return BBox{}, errors.New("bbox is out of range")
+	}
+	return b, nil
+}
+
+// Contains reports whether a point lies in the box
+func (b BBox) Contains(lat, lng float64) bool {
+	return lat >= b.South && lat <= b.North && lng >= b.West && lng <= b.East
+}
+
+// Subscriber is one streaming client. Events is closed when the hub drops it.
+type Subscriber struct {
+	UserID uuid.UUID
+	// BBox limits marker events to an area; none are sent without one
+	BBox   *BBox
+	Events chan Event
+	// hidden are the users whose markers are kept from this client: those it has blocked
+	// or muted
+	hidden map[uuid.UUID]bool
+	// follows are the users it follows, whose followers-only markers it may see
+	follows map[uuid.UUID]bool
+}
+
+// Hub tracks the streaming clients of this instance
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[*Subscriber]struct{}
+	db   *sql.DB
+}
+
+// NewHub returns a hub with no clients
+func NewHub() *Hub {
+	return &Hub{subs: map[*Subscriber]struct{}{}}
+}
+
+// Subscribe registers a client
+func (h *Hub) Subscribe(userID uuid.UUID, bbox *BBox) *Subscriber {
+	s := &Subscriber{UserID: userID, BBox: bbox, Events: make(chan Event, subscriberBuffer)}
+	s.hidden, s.follows = h.relationsOf(userID)
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Unsubscribe removes a client, if the hub hasn't already dropped it
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.Events)
+	}
+}
+
+// Listen starts relaying notifications on Channel to subscribers in the background until
+// the process exits, reconnecting to the database at dbURL whenever the connection drops.
+// Subscribers load their relations from db as soon as Listen returns.
+func (h *Hub) Listen(db *sql.DB, dbURL string) {
+	h.mu.Lock()
+	h.db = db
+	h.mu.Unlock()
+
+	go h.relay(dbURL)
+}
+
+// relay is the listening loop behind Listen
+func (h *Hub) relay(dbURL string) {
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("Realtime listener error:", err)
+		}
+	})
+	if err := listener.Listen(Channel); err != nil {
+		log.Println("⚠️ Warning: Failed to listen for realtime updates:", err)
+		return
+	}
+
+	for {
+		select {
+		case n := <-listener.Notify:
+			if n == nil {
+				// Reconnected; anything announced meanwhile is lost
+				h.reloadRelations(h.subscribedUsers())
+				h.Dispatch(Event{Type: TypeResync})
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				log.Println("Realtime payload error:", err)
+				continue
+			}
+			if e.Type == TypeRelationsChanged {
+				h.reloadRelations(e.UserIDs)
+				h.Dispatch(Event{Type: TypeResync, UserIDs: e.UserIDs})
+				continue
+			}
+			h.Dispatch(e)
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
+
+// Dispatch sends an event to every subscriber it concerns. Clients too far behind to
+// take it are dropped, and reconnect to catch up.
+func (h *Hub) Dispatch(e Event) {
+	h.mu.RLock()
+	var slow []*Subscriber
+	for s := range h.subs {
+		out, ok := h.eventFor(s, e)
+		if !ok {
+			continue
+		}
+		select {
+		case s.Events <- out:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range slow {
+		h.Unsubscribe(s)
+	}
+}
+
+// eventFor returns the event as subscriber s should receive it, if at all
+func (h *Hub) eventFor(s *Subscriber, e Event) (Event, bool) {
+	switch {
+	case e.Type == TypeResync && e.UserIDs == nil:
+		return e, true
+	case e.Marker != nil:
+		return h.markerEventFor(s, e)
+	default:
+		for _, id := range e.UserIDs {
+			if id == s.UserID {
+				out := e
+				out.UserIDs = nil
+				return out, true
+			}
+		}
+		return e, false
+	}
+}
+
+// markerEventFor sends a marker change to clients watching where it is and allowed to see
+// it. Clients that could see it before an update but can't now are told it was deleted.
+func (h *Hub) markerEventFor(s *Subscriber, e Event) (Event, bool) {
+	if s.BBox == nil {
+		return e, false
+	}
+	m := *e.Marker
+
+	visibleNow := e.Type != TypeMarkerDeleted && s.BBox.Contains(m.Latitude, m.Longitude) &&
+		s.canSee(m.OwnerID, m.Visibility)
+	// Where the client last saw it, so nothing is given away about where it went
+	before := MarkerChange{ID: m.ID, OwnerID: m.OwnerID, Latitude: m.Latitude, Longitude: m.Longitude}
+	visibleBefore := false
+	switch {
+	case e.Type == TypeMarkerDeleted:
+		visibleBefore = s.BBox.Contains(m.Latitude, m.Longitude) && s.canSee(m.OwnerID, m.Visibility)
+	case m.PreviousLatitude != nil && m.PreviousLongitude != nil && m.PreviousVisibility != nil:
+		before.Latitude, before.Longitude = *m.PreviousLatitude, *m.PreviousLongitude
+		visibleBefore = s.BBox.Contains(before.Latitude, before.Longitude) &&
+			s.canSee(m.OwnerID, *m.PreviousVisibility)
+	}
+
+	m.PreviousLatitude, m.PreviousLongitude, m.PreviousVisibility = nil, nil, nil
+	switch {
+	case visibleNow:
+		return Event{Type: e.Type, Marker: &m}, true
+	case visibleBefore:
+		return Event{Type: TypeMarkerDeleted, Marker: &before}, true
+	default:
+		return e, false
+	}
+}
+
+// relationsOf loads the users whose markers are kept from a viewer, and those it follows
+func (h *Hub) relationsOf(viewerID uuid.UUID) (hidden, follows map[uuid.UUID]bool) {
+	hidden, follows = map[uuid.UUID]bool{}, map[uuid.UUID]bool{}
+	h.mu.RLock()
+	db := h.db
+	h.mu.RUnlock()
+	if db == nil {
+		return hidden, follows
+	}
+	rows, err := db.Query(`
+		SELECT blocked_id, FALSE FROM user_blocks WHERE blocker_id = $1
+		UNION
+		SELECT muted_id, FALSE FROM user_mutes WHERE muter_id = $1
+		UNION
+		SELECT followee_id, TRUE FROM user_follows WHERE follower_id = $1
+	`, viewerID)
+	if err != nil {
+		log.Println("Realtime relations error:", err)
+		return hidden, follows
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uuid.UUID
+		var followed bool
+		if err := rows.Scan(&id, &followed); err != nil {
+			log.Println("Realtime relations error:", err)
+			return hidden, follows
+		}
+		if followed {
+			follows[id] = true
+		} else {
+			hidden[id] = true
+		}
+	}
+	return hidden, follows
+}
+
+// subscribedUsers lists the users with clients on this instance
+func (h *Hub) subscribedUsers() []uuid.UUID {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	seen := map[uuid.UUID]bool{}
+	var userIDs []uuid.UUID
+	for s := range h.subs {
+		if !seen[s.UserID] {
+			seen[s.UserID] = true
+			userIDs = append(userIDs, s.UserID)
+		}
+	}
+	return userIDs
+}
+
+// reloadRelations refreshes the hidden and followed users of the given users' clients.
+// The database is queried before the lock is taken.
+func (h *Hub) reloadRelations(userIDs []uuid.UUID) {
+	for _, userID := range userIDs {
+		hidden, follows := h.relationsOf(userID)
+		h.mu.Lock()
+		for s := range h.subs {
+			if s.UserID == userID {
+				s.hidden, s.follows = hidden, follows
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+// canSee applies marker visibility for a subscriber, matching the API's own checks.
+// Markers of users it has blocked or muted are never shown.
+func (s *Subscriber) canSee(ownerID uuid.UUID, visibility string) bool {
+	switch {
+	case s.hidden[ownerID]:
+		return false
+	case s.UserID == ownerID || visibility == "public" || visibility == "members":
+		return true
+	case visibility == "followers":
+		return s.follows[ownerID]
+	default:
+		return false
+	}
+}
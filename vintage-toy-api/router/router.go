@@ -22,10 +22,42 @@ func SetupRouter(db *sql.DB) *chi.Mux {
 	// Public Routes
 	r.Post("/login", handlers.LoginHandler(db))
 	r.Post("/users", handlers.CreateUserHandler(db))
-	r.Get("/users", handlers.GetUsersHandler(db))
 	r.Post("/logout", handlers.LogoutHandler())
-	r.Get("/markers", handlers.GetAllMarkersHandler(db))
-	r.Get("/users/search", handlers.SearchUsersHandler(db))
+	r.Get("/events/feeds/regions/{region}.ics", handlers.GetEventFeedHandler(db, "region"))
+	r.Get("/events/feeds/types/{type}.ics", handlers.GetEventFeedHandler(db, "type"))
+	r.Get("/events/feeds/users/{user}.ics", handlers.GetEventFeedHandler(db, "user"))
+	r.Get("/catalogue/franchises", handlers.GetFranchisesHandler(db))
+	r.Get("/catalogue/manufacturers", handlers.GetManufacturersHandler(db))
+	r.Get("/catalogue/lines", handlers.GetProductLinesHandler(db))
+	r.Get("/catalogue/items", handlers.GetCatalogueItemsHandler(db))
+	r.Get("/catalogue/items/{id}", handlers.GetCatalogueItemHandler(db))
+	r.Get("/catalogue/items/{id}/prices", handlers.GetCatalogueItemPricesHandler(db))
+	r.Get("/catalogue/lookup", handlers.LookupBarcodeHandler(db))
+	r.Get("/notifications/unsubscribe", handlers.UnsubscribeHandler(db))
+	r.Post("/notifications/unsubscribe", handlers.UnsubscribeHandler(db))
+	r.Get("/push/vapid-public-key", handlers.GetVAPIDPublicKeyHandler())
+
+	// Public Routes that tailor results to the caller when signed in
+	r.Group(func(opt chi.Router) {
+		opt.Use(middleware.OptionalAuthMiddleware)
+
+		opt.Get("/users", handlers.GetUsersHandler(db))
+		opt.Get("/users/search", handlers.SearchUsersHandler(db))
+		opt.Get("/users/{id}/collection", handlers.GetUserCollectionHandler(db))
+		opt.Get("/users/{id}/collection/stats", handlers.GetUserCollectionStatsHandler(db))
+		opt.Get("/users/{id}/followers", handlers.GetFollowersHandler(db))
+		opt.Get("/users/{id}/following", handlers.GetFollowingHandler(db))
+		opt.Get("/markers", handlers.GetAllMarkersHandler(db))
+		opt.Get("/markers/tiles/{z}/{x}/{y}", handlers.GetMarkerTileHandler(db))
+		opt.Get("/search", handlers.SearchHandler(db))
+		opt.Get("/search/suggest", handlers.SuggestHandler(db))
+		opt.Get("/events", handlers.GetEventsHandler(db))
+		opt.Get("/markers/{id}/hours", handlers.GetOpeningHoursHandler(db))
+		opt.Get("/markers/{id}/reviews", handlers.GetMarkerReviewsHandler(db))
+		opt.Get("/lists/shared/{token}", handlers.GetSharedListHandler(db))
+		opt.Get("/listings", handlers.GetListingsHandler(db))
+		opt.Get("/listings/{id}", handlers.GetListingHandler(db))
+	})
 
 	// Protected Routes
 	r.Route("/api", func(api chi.Router) {
@@ -34,6 +66,118 @@ func SetupRouter(db *sql.DB) *chi.Mux {
 		api.Get("/user", handlers.GetCurrentUserHandler(db))
 		api.Patch("/user", handlers.UpdateUserHandler(db))
 		api.Delete("/user", handlers.DeleteUserHandler(db))
+		api.Post("/users/{id}/follow", handlers.FollowUserHandler(db))
+		api.Delete("/users/{id}/follow", handlers.UnfollowUserHandler(db))
+		api.Get("/feed", handlers.GetFeedHandler(db))
+		api.Get("/blocks", handlers.GetBlockedUsersHandler(db))
+		api.Post("/users/{id}/block", handlers.BlockUserHandler(db))
+		api.Delete("/users/{id}/block", handlers.UnblockUserHandler(db))
+		api.Get("/mutes", handlers.GetMutedUsersHandler(db))
+		api.Post("/users/{id}/mute", handlers.MuteUserHandler(db))
+		api.Delete("/users/{id}/mute", handlers.UnmuteUserHandler(db))
+		api.Get("/stream", handlers.StreamHandler())
+
+		api.Get("/notifications", handlers.GetNotificationsHandler(db))
+		api.Get("/notifications/unread", handlers.GetUnreadNotificationCountHandler(db))
+		api.Post("/notifications/read", handlers.MarkAllNotificationsReadHandler(db))
+		api.Post("/notifications/{id}/read", handlers.MarkNotificationReadHandler(db))
+		api.Get("/notifications/preferences", handlers.GetNotificationPreferencesHandler(db))
+		api.Put("/notifications/preferences", handlers.UpdateNotificationPreferencesHandler(db))
+		api.Get("/push/subscriptions", handlers.GetPushSubscriptionsHandler(db))
+		api.Post("/push/subscriptions", handlers.CreatePushSubscriptionHandler(db))
+		api.Delete("/push/subscriptions/{id}", handlers.DeletePushSubscriptionHandler(db))
+
+		api.Get("/markers", handlers.GetMyMarkersHandler(db))
+		api.Post("/markers", handlers.CreateMarkerHandler(db))
+		api.Patch("/markers/{id}", handlers.UpdateMarkerHandler(db))
+		api.Delete("/markers/{id}", handlers.DeleteMarkerHandler(db))
+		api.Put("/markers/{id}/schedule", handlers.SetEventScheduleHandler(db))
+		api.Delete("/markers/{id}/schedule", handlers.DeleteEventScheduleHandler(db))
+		api.Put("/markers/{id}/rsvp", handlers.RSVPHandler(db))
+		api.Delete("/markers/{id}/rsvp", handlers.CancelRSVPHandler(db))
+		api.Get("/markers/{id}/attendees", handlers.GetAttendeesHandler(db))
+		api.Put("/markers/{id}/hours", handlers.SetOpeningHoursHandler(db))
+		api.Put("/markers/{id}/review", handlers.ReviewMarkerHandler(db))
+		api.Delete("/markers/{id}/review", handlers.DeleteReviewHandler(db))
+		api.Put("/reviews/{id}/reply", handlers.ReplyToReviewHandler(db))
+		api.Delete("/reviews/{id}/reply", handlers.DeleteReviewReplyHandler(db))
+		api.Post("/markers/{id}/favourite", handlers.FavouriteMarkerHandler(db))
+		api.Delete("/markers/{id}/favourite", handlers.UnfavouriteMarkerHandler(db))
+		api.Get("/favourites", handlers.GetFavouritesHandler(db))
+
+		api.Get("/lists", handlers.GetMyListsHandler(db))
+		api.Post("/lists", handlers.CreateListHandler(db))
+		api.Get("/lists/{id}", handlers.GetListHandler(db))
+		api.Patch("/lists/{id}", handlers.UpdateListHandler(db))
+		api.Delete("/lists/{id}", handlers.DeleteListHandler(db))
+		api.Put("/lists/{id}/order", handlers.ReorderListHandler(db))
+		api.Put("/lists/{id}/markers/{markerID}", handlers.SetListItemHandler(db))
+		api.Delete("/lists/{id}/markers/{markerID}", handlers.RemoveListItemHandler(db))
+
+		api.Post("/routes/plan", handlers.PlanRouteHandler(db))
+
+		api.Get("/collection", handlers.GetMyCollectionHandler(db))
+		api.Post("/collection", handlers.AddCollectionItemHandler(db))
+		api.Get("/collection/stats", handlers.GetMyCollectionStatsHandler(db))
+		api.Get("/collection/valuation", handlers.GetCollectionValuationHandler(db))
+		api.Post("/collection/scan", handlers.ScanCollectionItemHandler(db))
+		api.Patch("/collection/{id}", handlers.UpdateCollectionItemHandler(db))
+		api.Delete("/collection/{id}", handlers.DeleteCollectionItemHandler(db))
+
+		api.Get("/wishlist", handlers.GetWishlistHandler(db))
+		api.Get("/wishlist/matches", handlers.GetWishlistMatchesHandler(db))
+		api.Put("/wishlist/{id}", handlers.SetWishlistItemHandler(db))
+		api.Delete("/wishlist/{id}", handlers.DeleteWishlistItemHandler(db))
+
+		api.Get("/listings", handlers.GetMyListingsHandler(db))
+		api.Post("/listings", handlers.CreateListingHandler(db))
+		api.Patch("/listings/{id}", handlers.UpdateListingHandler(db))
+		api.Delete("/listings/{id}", handlers.DeleteListingHandler(db))
+
+		api.Get("/trades", handlers.GetTradesHandler(db))
+		api.Post("/trades", handlers.CreateTradeOfferHandler(db))
+		api.Get("/trades/{id}", handlers.GetTradeHandler(db))
+		api.Post("/trades/{id}/counter", handlers.CounterTradeOfferHandler(db))
+		api.Post("/trades/{id}/accept", handlers.TradeActionHandler(db, "accept"))
+		api.Post("/trades/{id}/decline", handlers.TradeActionHandler(db, "decline"))
+		api.Post("/trades/{id}/withdraw", handlers.TradeActionHandler(db, "withdraw"))
+		api.Post("/trades/{id}/complete", handlers.TradeActionHandler(db, "complete"))
+		api.Get("/swaps", handlers.GetSwapsHandler(db))
+		api.Get("/swaps/{id}", handlers.GetSwapHandler(db))
+		api.Post("/swaps/{id}/accept", handlers.SwapActionHandler(db, "accept"))
+		api.Post("/swaps/{id}/decline", handlers.SwapActionHandler(db, "decline"))
+		api.Post("/swaps/{id}/complete", handlers.SwapActionHandler(db, "complete"))
+
+		api.Get("/conversations", handlers.GetConversationsHandler(db))
+		api.Post("/conversations", handlers.CreateConversationHandler(db))
+		api.Get("/conversations/unread", handlers.GetUnreadMessagesHandler(db))
+		api.Get("/conversations/{id}", handlers.GetConversationHandler(db))
+		api.Patch("/conversations/{id}", handlers.UpdateConversationHandler(db))
+		api.Get("/conversations/{id}/messages", handlers.GetMessagesHandler(db))
+		api.Post("/conversations/{id}/messages", handlers.SendMessageHandler(db))
+		api.Post("/conversations/{id}/read", handlers.MarkConversationReadHandler(db))
+
+		// Admin Routes
+		api.Route("/admin", func(admin chi.Router) {
+			admin.Use(middleware.AdminMiddleware(db))
+
+			admin.Post("/catalogue/franchises", handlers.CreateFranchiseHandler(db))
+			admin.Patch("/catalogue/franchises/{id}", handlers.UpdateFranchiseHandler(db))
+			admin.Delete("/catalogue/franchises/{id}", handlers.DeleteFranchiseHandler(db))
+			admin.Post("/catalogue/manufacturers", handlers.CreateManufacturerHandler(db))
+			admin.Patch("/catalogue/manufacturers/{id}", handlers.UpdateManufacturerHandler(db))
+			admin.Delete("/catalogue/manufacturers/{id}", handlers.DeleteManufacturerHandler(db))
+			admin.Post("/catalogue/lines", handlers.CreateProductLineHandler(db))
+			admin.Patch("/catalogue/lines/{id}", handlers.UpdateProductLineHandler(db))
+			admin.Delete("/catalogue/lines/{id}", handlers.DeleteProductLineHandler(db))
+			admin.Post("/catalogue/items", handlers.CreateCatalogueItemHandler(db))
+			admin.Patch("/catalogue/items/{id}", handlers.UpdateCatalogueItemHandler(db))
+			admin.Delete("/catalogue/items/{id}", handlers.DeleteCatalogueItemHandler(db))
+			admin.Post("/catalogue/import", handlers.ImportCatalogueHandler(db))
+			admin.Get("/catalogue/barcodes", handlers.GetUnknownBarcodesHandler(db))
+			admin.Post("/catalogue/barcodes/{barcode}/resolve", handlers.ResolveUnknownBarcodeHandler(db))
+			admin.Delete("/catalogue/barcodes/{barcode}", handlers.DismissUnknownBarcodeHandler(db))
+		})
 	})
 
 	return r
@@ -0,0 +1,178 @@
+package routing
+
+import (
+	"time"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/utils"
+)
+
+// missedPenalty is added to a route's cost for each stop reached outside its time windows.
+// It outweighs any realistic driving distance, so keeping to windows always wins.
+const missedPenalty = 1e8
+
+// Point is a latitude/longitude pair
+type Point struct {
+	Lat, Lng float64
+}
+
+// Window is a period during which a stop can be visited
+type Window struct {
+	Start, End time.Time
+}
+
+// Stop is a place to visit. Windows must be sorted by Start. A nil Windows means it can be
+// visited at any time; an empty, non-nil Windows means it has no opening within the planning horizon.
+type Stop struct {
+	Point
+	Windows []Window
+}
+
+// Options controls how a route is timed
+type Options struct {
+	Closed   bool // return to the start point after the last stop
+	Depart   time.Time
+	SpeedKmh float64       // average straight-line travel speed
+	Dwell    time.Duration // time spent at each stop
+}
+
+// Visit is one stop on a planned route
+type Visit struct {
+	Stop   int // index into the stops passed to Solve
+	LegM   float64
+	Arrive time.Time
+	Start  time.Time // later than Arrive when waiting for the stop to open
+	Leave  time.Time
+	OnTime bool // false when the stop can't be visited inside any of its windows
+}
+
+// Plan is a timed visiting order
+type Plan struct {
+	Visits     []Visit
+	ReturnLegM float64 // zero for open routes
+	TotalM     float64
+	Finish     time.Time
+	Missed     int
+}
+
+// Solve orders stops starting from start using nearest-neighbour construction
+// followed by 2-opt improvement. Distances are great-circle, and the cost of
+// visiting a stop outside its windows is heavily penalised, so time windows are
+// honoured whenever some order allows it. The result is deterministic.
+func Solve(start Point, stops []Stop, opts Options) Plan {
+	n := len(stops)
+	if n == 0 {
+		return Plan{Visits: []Visit{}, Finish: opts.Depart}
+	}
+
+	// dist[i][j] with index n standing for the start point
+	points := make([]Point, n+1)
+	for i, s := range stops {
+		points[i] = s.Point
+	}
+	points[n] = start
+	dist := make([][]float64, n+1)
+	for i := range dist {
+		dist[i] = make([]float64, n+1)
+		for j := range dist[i] {
+			dist[i][j] = utils.Haversine(points[i].Lat, points[i].Lng, points[j].Lat, points[j].Lng)
+		}
+	}
+
+	order := nearestNeighbour(dist, n)
+	best := evaluate(order, stops, dist, opts)
+
+	for improved := true; improved; {
+		improved = false
+		for i := 0; i < n-1; i++ {
+			for j := i + 1; j < n; j++ {
+				candidate := twoOptSwap(order, i, j)
+				plan := evaluate(candidate, stops, dist, opts)
+				if cost(plan) < cost(best)-1e-6 {
+					order, best, improved = candidate, plan, true
+				}
+			}
+		}
+	}
+
+	return best
+}
+
+// nearestNeighbour builds a tour from the start point (index n) by always moving to the closest unvisited stop
+func nearestNeighbour(dist [][]float64, n int) []int {
+	order := make([]int, 0, n)
+	visited := make([]bool, n)
+	at := n
+	for len(order) < n {
+		next := -1
+		for j := 0; j < n; j++ {
+			if !visited[j] && (next < 0 || dist[at][j] < dist[at][next]) {
+				next = j
+			}
+		}
+		visited[next] = true
+		order = append(order, next)
+		at = next
+	}
+	return order
+}
+
+// twoOptSwap returns a copy of order with the segment i..j reversed
+func twoOptSwap(order []int, i, j int) []int {
+	out := make([]int, len(order))
+	copy(out, order)
+	for ; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+func cost(p Plan) float64 {
+	return p.TotalM + float64(p.Missed)*missedPenalty
+}
+
+// evaluate times a visiting order, waiting at stops that haven't opened yet
+func evaluate(order []int, stops []Stop, dist [][]float64, opts Options) Plan {
+	n := len(stops)
+	metresPerSecond := opts.SpeedKmh * 1000 / 3600
+
+	plan := Plan{Visits: make([]Visit, 0, len(order))}
+	t, at := opts.Depart, n
+	for _, idx := range order {
+		leg := dist[at][idx]
+		v := Visit{Stop: idx, LegM: leg, Arrive: t.Add(travelTime(leg, metresPerSecond))}
+		v.Start, v.OnTime = v.Arrive, true
+
+		if windows := stops[idx].Windows; windows != nil {
+			v.OnTime = false
+			for _, w := range windows {
+				if w.End.After(v.Arrive) {
+					if w.Start.After(v.Arrive) {
+						v.Start = w.Start
+					}
+					v.OnTime = true
+					break
+				}
+			}
+		}
+		if !v.OnTime {
+			plan.Missed++
+		}
+
+		v.Leave = v.Start.Add(opts.Dwell)
+		plan.TotalM += leg
+		plan.Visits = append(plan.Visits, v)
+		t, at = v.Leave, idx
+	}
+
+	if opts.Closed {
+		plan.ReturnLegM = dist[at][n]
+		plan.TotalM += plan.ReturnLegM
+		t = t.Add(travelTime(plan.ReturnLegM, metresPerSecond))
+	}
+	plan.Finish = t
+	return plan
+}
+
+func travelTime(metres, metresPerSecond float64) time.Duration {
+	return time.Duration(metres / metresPerSecond * float64(time.Second))
+}
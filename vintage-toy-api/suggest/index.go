@@ -0,0 +1,259 @@
+package suggest
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/models"
+)
+
+// Default is the process-wide index, loaded at startup and kept current by the handlers
+var Default = NewIndex()
+
+// scanBudget caps how many candidate keys a single lookup inspects
+const scanBudget = 500
+
+// Suggestion is a single autocomplete hit
+type Suggestion struct {
+	Text string `json:"text"`
+	Type string `json:"type"` // "user", "shop", "marker" or "region"
+	ID   string `json:"id,omitempty"`
+}
+
+type indexKey struct {
+	key   string
+	owner string
+	s     Suggestion
+}
+
+// Index is an in-memory prefix index over names. Every word boundary of a
+// name is indexed, so "toy" matches "Retro Toy Collectors".
+type Index struct {
+	mu          sync.RWMutex
+	keys        []indexKey
+	markerOwner map[string]string
+	// owned lists the keys indexed for each owner, so they can be found again to remove
+	owned map[string][]string
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{markerOwner: make(map[string]string), owned: make(map[string][]string)}
+}
+
+func normalize(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// keysFor returns one key per word boundary of the suggestion text
+func keysFor(owner string, s Suggestion) []indexKey {
+	words := strings.Fields(normalize(s.Text))
+	keys := make([]indexKey, 0, len(words))
+	for i := range words {
+		keys = append(keys, indexKey{key: strings.Join(words[i:], " "), owner: owner, s: s})
+	}
+	return keys
+}
+
+// replace swaps every key belonging to the given owners for the new keys. Each key is
+// found or placed by binary search, so the rest of the index is shifted rather than
+// rebuilt. The caller must hold idx.mu for writing.
+func (idx *Index) replace(owners map[string]bool, add []indexKey) {
+	for owner := range owners {
+		for _, key := range idx.owned[owner] {
+			i := sort.Search(len(idx.keys), func(i int) bool { return idx.keys[i].key >= key })
+			for ; i < len(idx.keys) && idx.keys[i].key == key; i++ {
+				if idx.keys[i].owner == owner {
+					idx.keys = append(idx.keys[:i], idx.keys[i+1:]...)
+					break
+				}
+			}
+		}
+		delete(idx.owned, owner)
+	}
+
+	for _, k := range add {
+		i := sort.Search(len(idx.keys), func(i int) bool { return idx.keys[i].key > k.key })
+		idx.keys = append(idx.keys, indexKey{})
+		copy(idx.keys[i+1:], idx.keys[i:])
+		idx.keys[i] = k
+		idx.owned[k.owner] = append(idx.owned[k.owner], k.key)
+	}
+}
+
+// SetUser indexes a user's display name and, if set, their store name
+func (idx *Index) SetUser(userID, displayName string, storeName *string) {
+	owner := "user:" + userID
+	add := keysFor(owner, Suggestion{Text: displayName, Type: "user", ID: userID})
+	if storeName != nil && *storeName != "" {
+		add = append(add, keysFor(owner, Suggestion{Text: *storeName, Type: "shop", ID: userID})...)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.replace(map[string]bool{owner: true}, add)
+}
+
+// RemoveUser drops a user and every marker they own from the index
+func (idx *Index) RemoveUser(userID string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	owners := map[string]bool{"user:" + userID: true}
+	for markerID, ownerID := range idx.markerOwner {
+		if ownerID == userID {
+			owners["marker:"+markerID] = true
+			delete(idx.markerOwner, markerID)
+		}
+	}
+	idx.replace(owners, nil)
+}
+
+// SetMarker indexes a marker's name
+func (idx *Index) SetMarker(markerID, userID, name string) {
+	owner := "marker:" + markerID
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.markerOwner[markerID] = userID
+	idx.replace(map[string]bool{owner: true}, keysFor(owner, Suggestion{Text: name, Type: "marker", ID: markerID}))
+}
+
+// RemoveMarker drops a marker from the index
+func (idx *Index) RemoveMarker(markerID string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.markerOwner, markerID)
+	idx.replace(map[string]bool{"marker:" + markerID: true}, nil)
+}
+
+// userOf returns the user a suggestion belongs to. The caller must hold idx.mu.
+func (idx *Index) userOf(s Suggestion) string {
+	if s.Type == "marker" {
+		return idx.markerOwner[s.ID]
+	}
+	return s.ID
+}
+
+// Lookup returns up to limit suggestions whose words start with prefix,
+// shortest first. Users in hidden, and their markers, are left out. It stops early once
+// ctx is done, returning what it has.
+func (idx *Index) Lookup(ctx context.Context, prefix string, limit int, hidden map[string]bool) []Suggestion {
+	prefix = normalize(prefix)
+	if prefix == "" {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	seen := make(map[Suggestion]bool)
+	var matches []Suggestion
+
+	start := sort.Search(len(idx.keys), func(i int) bool { return idx.keys[i].key >= prefix })
+	for i := start; i < len(idx.keys) && i-start < scanBudget; i++ {
+		if !strings.HasPrefix(idx.keys[i].key, prefix) {
+			break
+		}
+		if i%64 == 0 && ctx.Err() != nil {
+			break
+		}
+
+		s := idx.keys[i].s
+		if len(hidden) > 0 && hidden[idx.userOf(s)] {
+			continue
+		}
+		if !seen[s] {
+			seen[s] = true
+			matches = append(matches, s)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if len(matches[i].Text) != len(matches[j].Text) {
+			return len(matches[i].Text) < len(matches[j].Text)
+		}
+		return matches[i].Text < matches[j].Text
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Load rebuilds the index from the database
+func (idx *Index) Load(db *sql.DB) error {
+	var keys []indexKey
+	markerOwner := make(map[string]string)
+
+	for region := range models.Regions {
+		keys = append(keys, keysFor("region", Suggestion{Text: region, Type: "region"})...)
+	}
+
+	rows, err := db.Query(`
+		SELECT u.id, ub.display_name, ub.store_name
+		FROM users u
+		JOIN user_bios ub ON u.id = ub.user_id
+		WHERE u.is_deleted = FALSE
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, displayName string
+		var storeName sql.NullString
+		if err := rows.Scan(&userID, &displayName, &storeName); err != nil {
+			return err
+		}
+
+		owner := "user:" + userID
+		keys = append(keys, keysFor(owner, Suggestion{Text: displayName, Type: "user", ID: userID})...)
+		if storeName.Valid && storeName.String != "" {
+			keys = append(keys, keysFor(owner, Suggestion{Text: storeName.String, Type: "shop", ID: userID})...)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	markerRows, err := db.Query(`
+		SELECT um.id, um.user_id, um.name
+		FROM user_markers um
+		JOIN users u ON um.user_id = u.id
+		WHERE u.is_deleted = FALSE AND um.visibility = 'public'
+	`)
+	if err != nil {
+		return err
+	}
+	defer markerRows.Close()
+
+	for markerRows.Next() {
+		var markerID, userID, name string
+		if err := markerRows.Scan(&markerID, &userID, &name); err != nil {
+			return err
+		}
+		markerOwner[markerID] = userID
+		keys = append(keys, keysFor("marker:"+markerID, Suggestion{Text: name, Type: "marker", ID: markerID})...)
+	}
+	if err := markerRows.Err(); err != nil {
+		return err
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key < keys[j].key })
+	owned := make(map[string][]string)
+	for _, k := range keys {
+		owned[k.owner] = append(owned[k.owner], k.key)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.keys = keys
+	idx.markerOwner = markerOwner
+	idx.owned = owned
+	return nil
+}
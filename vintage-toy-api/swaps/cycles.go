@@ -0,0 +1,212 @@
+// Package swaps finds multi-party trade cycles, where each collector gives one item to
+// the next and receives one from the previous, so that everyone gets something they want.
+package swaps
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Joseph_Bartram8/vintage-toy-api/utils"
+)
+
+// Point is a location of one of a user's markers
+type Point struct {
+	Lat, Lng float64
+}
+
+// Want says that User would take Item from Owner, to satisfy their wishlist entry Wishlist
+type Want struct {
+	User     string
+	Owner    string
+	Item     string
+	Wishlist string
+}
+
+// Options tunes Find. Zero values take the defaults.
+type Options struct {
+	MinLength int // fewest participants in a cycle, default 2
+	MaxLength int // most participants in a cycle, default 4
+	// UnknownDistanceM is the distance assumed for a leg when either user has no markers
+	UnknownDistanceM float64
+}
+
+// Leg is one item passing from Giver to Receiver. DistanceM is the closest distance between
+// their markers, or Options.UnknownDistanceM when Located is false.
+type Leg struct {
+	Giver     string
+	Receiver  string
+	Item      string
+	Wishlist  string
+	DistanceM float64
+	Located   bool
+}
+
+// Cycle is a set of legs where every participant gives exactly once and receives exactly once
+type Cycle struct {
+	Legs      []Leg
+	DistanceM float64
+}
+
+// Key identifies the cycle by its items, so the same swap isn't proposed twice
+func (c Cycle) Key() string {
+	items := make([]string, len(c.Legs))
+	for i, l := range c.Legs {
+		items[i] = l.Item
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+const (
+	defaultMinLength        = 2
+	defaultMaxLength        = 4
+	defaultUnknownDistanceM = 1000000
+)
+
+// candidate is a cycle of users before items are assigned to its legs
+type candidate struct {
+	users     []string // users[i] gives to users[i+1], and the last gives to the first
+	distances []float64
+	located   []bool
+	total     float64
+	key       string
+}
+
+// Find returns non-overlapping trade cycles, nearest first. Each item and each wishlist
+// entry is used at most once. The result depends only on the input, not its order.
+func Find(wants []Want, locations map[string][]Point, opts Options) []Cycle {
+	if opts.MinLength < 2 {
+		opts.MinLength = defaultMinLength
+	}
+	if opts.MaxLength == 0 {
+		opts.MaxLength = defaultMaxLength
+	}
+	if opts.UnknownDistanceM == 0 {
+		opts.UnknownDistanceM = defaultUnknownDistanceM
+	}
+
+	// gives[a][b] lists the items a could give to b, in a fixed order
+	gives := map[string]map[string][]Want{}
+	for _, w := range wants {
+		if w.User == w.Owner {
+			continue
+		}
+		if gives[w.Owner] == nil {
+			gives[w.Owner] = map[string][]Want{}
+		}
+		gives[w.Owner][w.User] = append(gives[w.Owner][w.User], w)
+	}
+
+	users := make([]string, 0, len(gives))
+	next := map[string][]string{}
+	for giver, receivers := range gives {
+		users = append(users, giver)
+		for receiver, items := range receivers {
+			next[giver] = append(next[giver], receiver)
+			sort.Slice(items, func(i, j int) bool {
+				if items[i].Item != items[j].Item {
+					return items[i].Item < items[j].Item
+				}
+				return items[i].Wishlist < items[j].Wishlist
+			})
+		}
+		sort.Strings(next[giver])
+	}
+	sort.Strings(users)
+
+	distance := func(a, b string) (float64, bool) {
+		best := math.Inf(1)
+		for _, p := range locations[a] {
+			for _, q := range locations[b] {
+				best = math.Min(best, utils.Haversine(p.Lat, p.Lng, q.Lat, q.Lng))
+			}
+		}
+		if math.IsInf(best, 1) {
+			return opts.UnknownDistanceM, false
+		}
+		return best, true
+	}
+
+	// Each cycle is found once, from its lowest user, by only visiting higher users after the start
+	var candidates []candidate
+	var path []string
+	onPath := map[string]bool{}
+	var walk func(start, at string)
+	walk = func(start, at string) {
+		for _, to := range next[at] {
+			if to == start && len(path) >= opts.MinLength {
+				c := candidate{users: append([]string{}, path...)}
+				for i, u := range c.users {
+					d, ok := distance(u, c.users[(i+1)%len(c.users)])
+					c.distances = append(c.distances, d)
+					c.located = append(c.located, ok)
+					c.total += d
+				}
+				c.key = strings.Join(c.users, ",")
+				candidates = append(candidates, c)
+				continue
+			}
+			if to <= start || onPath[to] || len(path) == opts.MaxLength {
+				continue
+			}
+			path = append(path, to)
+			onPath[to] = true
+			walk(start, to)
+			onPath[to] = false
+			path = path[:len(path)-1]
+		}
+	}
+	for _, start := range users {
+		path = []string{start}
+		onPath = map[string]bool{start: true}
+		walk(start, start)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.total != b.total {
+			return a.total < b.total
+		}
+		if len(a.users) != len(b.users) {
+			return len(a.users) < len(b.users)
+		}
+		return a.key < b.key
+	})
+
+	usedItems := map[string]bool{}
+	usedWishlists := map[string]bool{}
+	var cycles []Cycle
+	for _, c := range candidates {
+		legs := make([]Leg, 0, len(c.users))
+		for i, giver := range c.users {
+			receiver := c.users[(i+1)%len(c.users)]
+			for _, w := range gives[giver][receiver] {
+				if !usedItems[w.Item] && !usedWishlists[w.Wishlist] {
+					legs = append(legs, Leg{
+						Giver:     giver,
+						Receiver:  receiver,
+						Item:      w.Item,
+						Wishlist:  w.Wishlist,
+						DistanceM: c.distances[i],
+						Located:   c.located[i],
+					})
+					break
+				}
+			}
+			if len(legs) != i+1 {
+				break
+			}
+		}
+		if len(legs) != len(c.users) {
+			continue
+		}
+
+		for _, l := range legs {
+			usedItems[l.Item] = true
+			usedWishlists[l.Wishlist] = true
+		}
+		cycles = append(cycles, Cycle{Legs: legs, DistanceM: c.total})
+	}
+	return cycles
+}
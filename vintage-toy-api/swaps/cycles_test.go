@@ -0,0 +1,192 @@
+package swaps
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// want is shorthand for user wanting item from owner through a wishlist entry of the same name
+func want(user, owner, item string) Want {
+	return Want{User: user, Owner: owner, Item: item, Wishlist: user + ":" + item}
+}
+
+// givers lists who gives in each leg of a cycle, in order
+func givers(c Cycle) []string {
+	var out []string
+	for _, l := range c.Legs {
+		out = append(out, l.Giver)
+	}
+	return out
+}
+
+func TestFindTwoWaySwap(t *testing.T) {
+	cycles := Find([]Want{
+		want("a", "b", "b1"),
+		want("b", "a", "a1"),
+	}, nil, Options{})
+
+	if len(cycles) != 1 {
+		t.Fatalf("got %d cycles, want 1", len(cycles))
+	}
+	expected := []Leg{
+		{Giver: "a", Receiver: "b", Item: "a1", Wishlist: "b:a1", DistanceM: defaultUnknownDistanceM},
+		{Giver: "b", Receiver: "a", Item: "b1", Wishlist: "a:b1", DistanceM: defaultUnknownDistanceM},
+	}
+	if !reflect.DeepEqual(cycles[0].Legs, expected) {
+		t.Errorf("legs = %+v, want %+v", cycles[0].Legs, expected)
+	}
+}
+
+func TestFindThreeWaySwap(t *testing.T) {
+	// Nobody wants what the person they want from has, until the loop closes
+	cycles := Find([]Want{
+		want("a", "b", "b1"),
+		want("b", "c", "c1"),
+		want("c", "a", "a1"),
+	}, nil, Options{})
+
+	if len(cycles) != 1 {
+		t.Fatalf("got %d cycles, want 1", len(cycles))
+	}
+	if got := givers(cycles[0]); !reflect.DeepEqual(got, []string{"a", "c", "b"}) {
+		t.Errorf("givers = %v, want [a c b]", got)
+	}
+	if key := cycles[0].Key(); key != "a1,b1,c1" {
+		t.Errorf("key = %q, want a1,b1,c1", key)
+	}
+}
+
+func TestFindRespectsMaxLength(t *testing.T) {
+	// A five-person loop
+	wants := []Want{
+		want("a", "b", "b1"),
+		want("b", "c", "c1"),
+		want("c", "d", "d1"),
+		want("d", "e", "e1"),
+		want("e", "a", "a1"),
+	}
+
+	if cycles := Find(wants, nil, Options{}); len(cycles) != 0 {
+		t.Errorf("got %d cycles with the default limit, want 0", len(cycles))
+	}
+	if cycles := Find(wants, nil, Options{MaxLength: 5}); len(cycles) != 1 || len(cycles[0].Legs) != 5 {
+		t.Errorf("got %v with MaxLength 5, want one five-leg cycle", cycles)
+	}
+}
+
+func TestFindRespectsMinLength(t *testing.T) {
+	cycles := Find([]Want{
+		want("a", "b", "b1"),
+		want("b", "a", "a1"),
+	}, nil, Options{MinLength: 3})
+
+	if len(cycles) != 0 {
+		t.Errorf("got %d cycles, want 0", len(cycles))
+	}
+}
+
+func TestFindPrefersNearbyUsers(t *testing.T) {
+	// a can swap a1 with either b (Manchester) or c (Plymouth), but only has one a1
+	locations := map[string][]Point{
+		"a": {{Lat: 53.4808, Lng: -2.2426}}, // Manchester
+		"b": {{Lat: 53.4084, Lng: -2.9916}}, // Liverpool
+		"c": {{Lat: 50.3755, Lng: -4.1427}}, // Plymouth
+	}
+	cycles := Find([]Want{
+		want("a", "c", "c1"),
+		want("c", "a", "a1"),
+		want("a", "b", "b1"),
+		want("b", "a", "a1"),
+	}, locations, Options{})
+
+	if len(cycles) != 1 {
+		t.Fatalf("got %d cycles, want 1", len(cycles))
+	}
+	if got := givers(cycles[0]); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("givers = %v, want [a b]", got)
+	}
+	if d := cycles[0].DistanceM; d < 90000 || d > 110000 {
+		t.Errorf("distance = %.0f m, want about 100 km for the round trip", d)
+	}
+	for _, l := range cycles[0].Legs {
+		if !l.Located {
+			t.Errorf("leg %s -> %s not located", l.Giver, l.Receiver)
+		}
+	}
+}
+
+func TestFindUsesEachItemOnce(t *testing.T) {
+	// Two users want b1 from b; only one swap can use it, the other falls back to b2
+	cycles := Find([]Want{
+		want("a", "b", "b1"),
+		want("b", "a", "a1"),
+		want("c", "b", "b1"),
+		want("c", "b", "b2"),
+		want("b", "c", "c1"),
+	}, nil, Options{})
+
+	if len(cycles) != 2 {
+		t.Fatalf("got %d cycles, want 2", len(cycles))
+	}
+	seen := map[string]bool{}
+	for _, c := range cycles {
+		for _, l := range c.Legs {
+			if seen[l.Item] {
+				t.Errorf("item %s used twice", l.Item)
+			}
+			seen[l.Item] = true
+		}
+	}
+	if !seen["b1"] || !seen["b2"] {
+		t.Errorf("items used = %v, want both b1 and b2", seen)
+	}
+}
+
+func TestFindSkipsOwnItems(t *testing.T) {
+	cycles := Find([]Want{want("a", "a", "a1")}, nil, Options{})
+	if len(cycles) != 0 {
+		t.Errorf("got %d cycles, want 0", len(cycles))
+	}
+}
+
+func TestFindIsDeterministic(t *testing.T) {
+	// A dense graph of six users who each want something from everyone else
+	users := []string{"u1", "u2", "u3", "u4", "u5", "u6"}
+	var wants []Want
+	for _, u := range users {
+		for _, o := range users {
+			if u != o {
+				wants = append(wants, want(u, o, o+"-item-for-"+u))
+			}
+		}
+	}
+	locations := map[string][]Point{
+		"u1": {{Lat: 51.5, Lng: -0.1}},
+		"u2": {{Lat: 51.6, Lng: -0.2}},
+		"u3": {{Lat: 52.5, Lng: -1.9}},
+		"u4": {{Lat: 53.5, Lng: -2.2}, {Lat: 51.45, Lng: -2.6}},
+		"u5": {{Lat: 54.9, Lng: -1.6}},
+	}
+
+	expected := Find(wants, locations, Options{})
+	if len(expected) == 0 {
+		t.Fatal("found no cycles in a complete graph")
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		shuffled := append([]Want{}, wants...)
+		rng.Shuffle(len(shuffled), func(a, b int) { shuffled[a], shuffled[b] = shuffled[b], shuffled[a] })
+		if got := Find(shuffled, locations, Options{}); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("shuffle %d gave a different result:\n%+v\nwant\n%+v", i, got, expected)
+		}
+	}
+
+	// Nearest first
+	for i := 1; i < len(expected); i++ {
+		if expected[i].DistanceM < expected[i-1].DistanceM {
+			t.Errorf("cycle %d is nearer than cycle %d", i, i-1)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const earthRadiusMeters = 6371000.0
+
+// Haversine returns the great-circle distance in meters between two points
+func Haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLng := (lng2 - lng1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
+
+// DistanceSQL returns a SQL expression for the haversine distance in meters between
+// the given columns and the point bound to placeholders $latArg and $lngArg
+func DistanceSQL(latCol, lngCol string, latArg, lngArg int) string {
+	return fmt.Sprintf(
+		"(%f * 2 * ASIN(SQRT(POWER(SIN(RADIANS(%s - $%d::float8) / 2), 2) + "+
+			"COS(RADIANS($%d::float8)) * COS(RADIANS(%s)) * POWER(SIN(RADIANS(%s - $%d::float8) / 2), 2))))",
+		earthRadiusMeters, latCol, latArg, latArg, latCol, lngCol, lngArg,
+	)
+}
+
+// DistanceBetweenSQL returns a SQL expression for the haversine distance in meters
+// between two pairs of lat/lng columns
+func DistanceBetweenSQL(lat1, lng1, lat2, lng2 string) string {
+	return fmt.Sprintf(
+		"(%f * 2 * ASIN(SQRT(POWER(SIN(RADIANS(%s - %s) / 2), 2) + "+
+			"COS(RADIANS(%s)) * COS(RADIANS(%s)) * POWER(SIN(RADIANS(%s - %s) / 2), 2))))",
+		earthRadiusMeters, lat1, lat2, lat2, lat1, lng1, lng2,
+	)
+}
+
+// ParsePoint reads a lat/lng pair from the query string. ok is false when neither is set.
+func ParsePoint(r *http.Request, latParam, lngParam string) (lat, lng float64, ok bool, err error) {
+	q := r.URL.Query()
+	latStr, lngStr := q.Get(latParam), q.Get(lngParam)
+	if latStr == "" && lngStr == "" {
+		return 0, 0, false, nil
+	}
+
+	lat, errLat := strconv.ParseFloat(latStr, 64)
+	lng, errLng := strconv.ParseFloat(lngStr, 64)
+	if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return 0, 0, false, errors.New("invalid coordinates")
+	}
+	return lat, lng, true, nil
+}
@@ -0,0 +1,142 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// recordSize is the aes128gcm record size announced in the header. Payloads are sent as a
+// single record, which push services accept up to 4096 bytes.
+const recordSize = 4096
+
+// MaxPayload is the largest payload that fits in one record: the record holds the payload,
+// its delimiter byte and the 16-byte AES-GCM tag
+const MaxPayload = recordSize - 16 - 1 - headerSize
+
+// headerSize is the salt, record size, key ID length and the 65-byte key ID
+const headerSize = 16 + 4 + 1 + 65
+
+// ErrPayloadTooLarge is returned for payloads over MaxPayload
+var ErrPayloadTooLarge = errors.New("push payload too large")
+
+// ErrInvalidKeys is returned for subscriptions whose p256dh or auth keys are malformed
+var ErrInvalidKeys = errors.New("invalid push subscription keys")
+
+// decodeKey reads a base64url key as browsers report them, padded or not
+func decodeKey(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// ValidateKeys checks a subscription's p256dh and auth keys, so malformed subscriptions
+// can be refused before anything is sent to them
+func ValidateKeys(p256dh, auth string) error {
+	_, _, err := parseKeys(p256dh, auth)
+	return err
+}
+
+// parseKeys decodes the browser's public key, a point on P-256, and its 16-byte auth secret
+func parseKeys(p256dh, auth string) (*ecdh.PublicKey, []byte, error) {
+	uaPublicBytes, err := decodeKey(p256dh)
+	if err != nil {
+		return nil, nil, ErrInvalidKeys
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, nil, ErrInvalidKeys
+	}
+	authSecret, err := decodeKey(auth)
+	if err != nil || len(authSecret) != 16 {
+		return nil, nil, ErrInvalidKeys
+	}
+	return uaPublic, authSecret, nil
+}
+
+// Encrypt encrypts a payload for a subscription as RFC 8291 requires: an ECDH agreement
+// between a fresh key pair and the browser's p256dh key, mixed with its auth secret,
+// yields the AES-128-GCM key and nonce of an RFC 8188 aes128gcm body.
+func Encrypt(payload []byte, p256dh, auth string) ([]byte, error) {
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return encrypt(asPrivate, salt, payload, p256dh, auth)
+}
+
+// encrypt is Encrypt with the sender's key pair and the salt supplied
+func encrypt(asPrivate *ecdh.PrivateKey, salt, payload []byte, p256dh, auth string) ([]byte, error) {
+	if len(payload) > MaxPayload {
+		return nil, ErrPayloadTooLarge
+	}
+
+	uaPublic, authSecret, err := parseKeys(p256dh, auth)
+	if err != nil {
+		return nil, err
+	}
+	uaPublicBytes := uaPublic.Bytes()
+	asPublicBytes := asPrivate.PublicKey().Bytes()
+
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, nonce, err := contentKeys(sharedSecret, authSecret, salt, uaPublicBytes, asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single, final record: the payload followed by the 0x02 delimiter
+	plaintext := append(append([]byte{}, payload...), 0x02)
+
+	body := make([]byte, 0, headerSize+len(plaintext)+gcm.Overhead())
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, recordSize)
+	body = append(body, byte(len(asPublicBytes)))
+	body = append(body, asPublicBytes...)
+	return gcm.Seal(body, nonce, plaintext, nil), nil
+}
+
+// contentKeys derives the content encryption key and nonce from the ECDH shared secret
+// (RFC 8291 section 3.4 and RFC 8188 section 2.2)
+func contentKeys(sharedSecret, authSecret, salt, uaPublic, asPublic []byte) (cek, nonce []byte, err error) {
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, sharedSecret, authSecret, keyInfo), ikm); err != nil {
+		return nil, nil, err
+	}
+
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	cek = make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: aes128gcm\x00")), cek); err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, 12)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: nonce\x00")), nonce); err != nil {
+		return nil, nil, err
+	}
+	return cek, nonce, nil
+}
@@ -0,0 +1,73 @@
+package webpush
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrSubscriptionGone is returned when the push service reports that a subscription has
+// expired or been removed (404 or 410), so it should be deleted
+var ErrSubscriptionGone = errors.New("push subscription is gone")
+
+// Subscription is where and how to reach one browser, from its PushSubscription
+type Subscription struct {
+	Endpoint string
+	// P256dh and Auth are the base64url encoded keys from PushSubscription.getKey
+	P256dh string
+	Auth   string
+}
+
+// Sender delivers push messages to push services
+type Sender struct {
+	Keys Keys
+	// Subject is a mailto: or https: contact for the push service operator
+	Subject string
+	// TTL is how long a push service keeps a message for a browser that is offline
+	TTL    time.Duration
+	Client *http.Client
+}
+
+// Send encrypts a payload and posts it to the subscription's push service
+func (s *Sender) Send(sub Subscription, payload []byte) error {
+	body, err := Encrypt(payload, sub.P256dh, sub.Auth)
+	if err != nil {
+		return err
+	}
+	authorization, err := s.Keys.authorization(sub.Endpoint, s.Subject, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(s.TTL.Seconds())))
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrSubscriptionGone
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("push service returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
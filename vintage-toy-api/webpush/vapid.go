@@ -0,0 +1,122 @@
+// Package webpush sends Web Push messages: VAPID identification (RFC 8292) and payload
+// encryption (RFC 8291)
+package webpush
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// vapidLifetime is how long a VAPID token stays valid; push services reject over 24 hours
+const vapidLifetime = 12 * time.Hour
+
+// ErrInvalidVAPIDKey is returned for VAPID private keys that aren't a P-256 scalar
+var ErrInvalidVAPIDKey = errors.New("invalid VAPID private key")
+
+// Keys is the application server's VAPID key pair. Browsers bind subscriptions to the
+// public key, so it must stay the same for as long as they are in use.
+type Keys struct {
+	Private *ecdsa.PrivateKey
+	// PublicKey is the uncompressed public key, base64url encoded, as the browser's
+	// pushManager.subscribe expects it for applicationServerKey
+	PublicKey string
+}
+
+// GenerateKeys creates a new VAPID key pair
+func GenerateKeys() (Keys, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return Keys{}, err
+	}
+	return keysFrom(private)
+}
+
+// ParseKeys reads a VAPID key pair from its base64url encoded 32-byte private key
+func ParseKeys(privateKey string) (Keys, error) {
+	d, err := decodeKey(privateKey)
+	if err != nil {
+		return Keys{}, ErrInvalidVAPIDKey
+	}
+	// ecdh validates the scalar and derives the public point
+	ecdhKey, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return Keys{}, ErrInvalidVAPIDKey
+	}
+	public := ecdhKey.PublicKey().Bytes()
+	private := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}
+	return keysFrom(private)
+}
+
+// keysFrom fills in the encoded public key
+func keysFrom(private *ecdsa.PrivateKey) (Keys, error) {
+	ecdhKey, err := private.ECDH()
+	if err != nil {
+		return Keys{}, err
+	}
+	return Keys{Private: private, PublicKey: base64.RawURLEncoding.EncodeToString(ecdhKey.PublicKey().Bytes())}, nil
+}
+
+// EncodePrivateKey returns the base64url encoded 32-byte private key, as read by ParseKeys
+func (k Keys) EncodePrivateKey() string {
+	return base64.RawURLEncoding.EncodeToString(k.Private.D.FillBytes(make([]byte, 32)))
+}
+
+// LoadOrCreateKeys returns the key pair kept in the database, creating it on first use.
+// Every instance sharing the database gets the same pair.
+func LoadOrCreateKeys(db *sql.DB) (Keys, error) {
+	generated, err := GenerateKeys()
+	if err != nil {
+		return Keys{}, err
+	}
+	// Keep whichever pair was stored first, should two instances start together
+	_, err = db.Exec(`
+		INSERT INTO vapid_keys (id, private_key) VALUES (1, $1)
+		ON CONFLICT (id) DO NOTHING
+	`, generated.EncodePrivateKey())
+	if err != nil {
+		return Keys{}, err
+	}
+
+	var stored string
+	if err := db.QueryRow("SELECT private_key FROM vapid_keys WHERE id = 1").Scan(&stored); err != nil {
+		return Keys{}, err
+	}
+	return ParseKeys(stored)
+}
+
+// authorization returns the VAPID Authorization header for a push service endpoint: a
+// token signed with the private key, for the endpoint's origin, and the public key
+func (k Keys) authorization(endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid push endpoint %q", endpoint)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidLifetime).Unix(),
+		"sub": subject,
+	}).SignedString(k.Private)
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + token + ", k=" + k.PublicKey, nil
+}
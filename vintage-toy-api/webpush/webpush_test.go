@@ -0,0 +1,249 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// b64 decodes a base64url test value
+func b64(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// browser is the receiving end of a subscription: its key pair and auth secret
+type browser struct {
+	private *ecdh.PrivateKey
+	auth    []byte
+}
+
+func newBrowser(t *testing.T) browser {
+	t.Helper()
+	private, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, auth); err != nil {
+		t.Fatal(err)
+	}
+	return browser{private: private, auth: auth}
+}
+
+func (b browser) subscription(endpoint string) Subscription {
+	return Subscription{
+		Endpoint: endpoint,
+		P256dh:   base64.RawURLEncoding.EncodeToString(b.private.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(b.auth),
+	}
+}
+
+// decrypt reverses Encrypt as a browser would
+func (b browser) decrypt(t *testing.T, body []byte) []byte {
+	t.Helper()
+	if len(body) < headerSize {
+		t.Fatalf("body of %d bytes is shorter than the header", len(body))
+	}
+	salt := body[:16]
+	if rs := binary.BigEndian.Uint32(body[16:20]); rs != recordSize {
+		t.Fatalf("record size %d, want %d", rs, recordSize)
+	}
+	if idlen := body[20]; idlen != 65 {
+		t.Fatalf("key ID length %d, want 65", idlen)
+	}
+	asPublicBytes := body[21:86]
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := b.private.ECDH(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cek, nonce, err := contentKeys(shared, b.auth, salt, b.private.PublicKey().Bytes(), asPublicBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, body[headerSize:], nil)
+	if err != nil {
+		t.Fatalf("decrypting: %v", err)
+	}
+	if plaintext[len(plaintext)-1] != 0x02 {
+		t.Fatalf("record ends with %#x, want the final record delimiter", plaintext[len(plaintext)-1])
+	}
+	return plaintext[:len(plaintext)-1]
+}
+
+// TestEncryptRFC8291Example checks the worked example in RFC 8291 appendix A
+func TestEncryptRFC8291Example(t *testing.T) {
+	asPrivate, err := ecdh.P256().NewPrivateKey(b64(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := encrypt(asPrivate, b64(t, "DGv6ra1nlYgDCS1FRnbzlw"),
+		[]byte("When I grow up, I want to be a watermelon"),
+		"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4",
+		"BTBZMqHH6r4Tts7J_aSIgg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if enc := base64.RawURLEncoding.EncodeToString(got); enc != want {
+		t.Errorf("got  %s\nwant %s", enc, want)
+	}
+}
+
+func TestEncryptRejectsBadInput(t *testing.T) {
+	b := newBrowser(t)
+	sub := b.subscription("https://push.example")
+
+	if _, err := Encrypt(make([]byte, MaxPayload+1), sub.P256dh, sub.Auth); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("oversized payload: got %v, want ErrPayloadTooLarge", err)
+	}
+	if _, err := Encrypt([]byte("hi"), "not-a-key", sub.Auth); !errors.Is(err, ErrInvalidKeys) {
+		t.Errorf("bad p256dh: got %v, want ErrInvalidKeys", err)
+	}
+	if _, err := Encrypt([]byte("hi"), sub.P256dh, "c2hvcnQ"); !errors.Is(err, ErrInvalidKeys) {
+		t.Errorf("short auth: got %v, want ErrInvalidKeys", err)
+	}
+}
+
+func TestParseKeysRoundTrip(t *testing.T) {
+	keys, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseKeys(keys.EncodePrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.PublicKey != keys.PublicKey {
+		t.Errorf("public key %s after parsing, want %s", parsed.PublicKey, keys.PublicKey)
+	}
+	if _, err := ParseKeys("AAAA"); !errors.Is(err, ErrInvalidVAPIDKey) {
+		t.Errorf("short key: got %v, want ErrInvalidVAPIDKey", err)
+	}
+}
+
+// pushStub is a local push service. It checks the VAPID token and content encoding of
+// each message and answers with status.
+type pushStub struct {
+	t        *testing.T
+	keys     Keys
+	status   int
+	received [][]byte
+}
+
+func (p *pushStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Encoding") != "aes128gcm" {
+		p.t.Errorf("Content-Encoding %q, want aes128gcm", r.Header.Get("Content-Encoding"))
+	}
+	if r.Header.Get("TTL") == "" {
+		p.t.Error("missing TTL header")
+	}
+
+	token, key, ok := strings.Cut(strings.TrimPrefix(r.Header.Get("Authorization"), "vapid t="), ", k=")
+	if !ok {
+		p.t.Fatalf("Authorization %q is not a VAPID header", r.Header.Get("Authorization"))
+	}
+	if key != p.keys.PublicKey {
+		p.t.Errorf("VAPID key %s, want %s", key, p.keys.PublicKey)
+	}
+	point := b64(p.t, key)
+	public := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(point[1:33]),
+		Y:     new(big.Int).SetBytes(point[33:]),
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(tok *jwt.Token) (interface{}, error) {
+		if tok.Method != jwt.SigningMethodES256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return public, nil
+	})
+	if err != nil {
+		p.t.Errorf("VAPID token: %v", err)
+	}
+	if aud := "http://" + r.Host; claims["aud"] != aud {
+		p.t.Errorf("aud %v, want %s", claims["aud"], aud)
+	}
+	if claims["sub"] != "mailto:admin@example.com" {
+		p.t.Errorf("sub %v, want the sender's subject", claims["sub"])
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	p.received = append(p.received, body)
+	w.WriteHeader(p.status)
+}
+
+func newPushStub(t *testing.T, status int) (*pushStub, *httptest.Server, *Sender) {
+	t.Helper()
+	keys, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &pushStub{t: t, keys: keys, status: status}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	sender := &Sender{Keys: keys, Subject: "mailto:admin@example.com", TTL: time.Hour, Client: server.Client()}
+	return stub, server, sender
+}
+
+func TestSendDeliversDecryptablePayload(t *testing.T) {
+	stub, server, sender := newPushStub(t, http.StatusCreated)
+	b := newBrowser(t)
+
+	payload := []byte(`{"title":"New message from Sam"}`)
+	if err := sender.Send(b.subscription(server.URL+"/push/abc"), payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stub.received) != 1 {
+		t.Fatalf("push service got %d messages, want 1", len(stub.received))
+	}
+	if got := b.decrypt(t, stub.received[0]); string(got) != string(payload) {
+		t.Errorf("browser decrypted %q, want %q", got, payload)
+	}
+}
+
+func TestSendReportsGoneSubscriptions(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusGone} {
+		_, server, sender := newPushStub(t, status)
+		err := sender.Send(newBrowser(t).subscription(server.URL+"/push/abc"), []byte("{}"))
+		if !errors.Is(err, ErrSubscriptionGone) {
+			t.Errorf("status %d: got %v, want ErrSubscriptionGone", status, err)
+		}
+	}
+}
+
+func TestSendReportsOtherFailures(t *testing.T) {
+	_, server, sender := newPushStub(t, http.StatusTooManyRequests)
+	err := sender.Send(newBrowser(t).subscription(server.URL+"/push/abc"), []byte("{}"))
+	if err == nil || errors.Is(err, ErrSubscriptionGone) {
+		t.Errorf("got %v, want a delivery error that keeps the subscription", err)
+	}
+}